@@ -4,22 +4,24 @@ import (
 	"flag"
 	"os"
 	"runtime"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
 	"k8s.io/client-go/tools/clientcmd"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+	custommetricsclient "k8s.io/metrics/pkg/client/custom_metrics"
+	externalmetricsclient "k8s.io/metrics/pkg/client/external_metrics"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
-	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
-	"github.com/containership/cerebral/pkg/autoscalingengine"
-	"github.com/containership/cerebral/pkg/autoscalingengine/containership"
 	"github.com/containership/cerebral/pkg/buildinfo"
 	cerebral "github.com/containership/cerebral/pkg/client/clientset/versioned"
 	cerebralscheme "github.com/containership/cerebral/pkg/client/clientset/versioned/scheme"
@@ -29,6 +31,10 @@ import (
 	"github.com/containership/cluster-manager/pkg/log"
 )
 
+// discoveryResetInterval is how often cached API discovery information is
+// discarded so that resources registered after startup can be resolved
+const discoveryResetInterval = 30 * time.Second
+
 func main() {
 	log.Info("Starting Cerebral...")
 	log.Infof("Version: %s", buildinfo.String())
@@ -54,15 +60,36 @@ func main() {
 		log.Fatalf("Failed to create Cerebral clientset: %+v", err)
 	}
 
+	dynamicclientset, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to create dynamic clientset: %+v", err)
+	}
+
+	stopCh := make(chan struct{})
+
+	metricsclient, err := metricsclientset.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to create metrics clientset: %+v", err)
+	}
+
+	customMetricsClient := newCustomMetricsClient(config, kubeclientset, stopCh)
+
+	externalMetricsClient, err := externalmetricsclient.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to create external metrics client: %+v", err)
+	}
+
+	scaleclient, err := newScaleClient(config, kubeclientset, stopCh)
+	if err != nil {
+		log.Fatalf("Failed to create scale client: %+v", err)
+	}
+
 	// Add cerebral scheme so we can record events
 	cerebralscheme.AddToScheme(scheme.Scheme)
 
 	kubeInformerFactory := informers.NewSharedInformerFactory(kubeclientset, 30*time.Second)
 	cerebralInformerFactory := cinformers.NewSharedInformerFactory(cerebralclientset, 30*time.Second)
 
-	registerContainershipEngineOrDie(cerebralclientset)
-
-	stopCh := make(chan struct{})
 	scaleMgr := controller.NewScaleManager(
 		kubeclientset, kubeInformerFactory, cerebralclientset, cerebralInformerFactory)
 
@@ -75,7 +102,16 @@ func main() {
 		scaleMgr.ScaleRequestChan())
 
 	metricsBackendController := controller.NewMetricsBackend(
-		kubeclientset, kubeInformerFactory, cerebralclientset, cerebralInformerFactory)
+		kubeclientset, kubeInformerFactory, cerebralclientset, cerebralInformerFactory,
+		metricsclient, customMetricsClient, externalMetricsClient)
+
+	autoscalingEngineController := controller.NewAutoscalingEngine(
+		kubeclientset, kubeInformerFactory, cerebralclientset, cerebralInformerFactory,
+		dynamicclientset, scaleclient)
+
+	scheduledScalingActionController := controller.NewScheduledScalingAction(
+		kubeclientset, kubeInformerFactory, cerebralclientset, cerebralInformerFactory,
+		scaleMgr.ScaleRequestChan())
 
 	kubeInformerFactory.Start(stopCh)
 	cerebralInformerFactory.Start(stopCh)
@@ -98,12 +134,24 @@ func main() {
 		}
 	}()
 
+	go func() {
+		if err := autoscalingEngineController.Run(1, stopCh); err != nil {
+			log.Fatalf("Error running AutoscalingEngineController: %s", err.Error())
+		}
+	}()
+
 	go func() {
 		if err := metricsController.Run(1, stopCh); err != nil {
 			log.Fatalf("Error running MetricsController: %s", err.Error())
 		}
 	}()
 
+	go func() {
+		if err := scheduledScalingActionController.Run(stopCh); err != nil {
+			log.Fatalf("Error running ScheduledScalingActionController: %s", err.Error())
+		}
+	}()
+
 	<-stopCh
 	log.Fatal("There was an error while running the scale manager and controllers")
 }
@@ -134,45 +182,32 @@ func determineConfig() (*rest.Config, error) {
 	return config, nil
 }
 
-// registerContainershipEngineOrDie assumes a few things...
-// 1. It assumes that the containership autoscaling engine CR exists, if not cerebral fails
-// 2. Only engines of type containership will be registered
-// 3. Finally if the containership autoscaling engine is unable to be created it
-//    assumes cerebral can't/shouldn't be used and fails.
-func registerContainershipEngineOrDie(cerebralclientset *cerebral.Clientset) {
-	engineCreated := false
+// newScaleClient returns a client for the scale subresource of arbitrary
+// resources, resolving resources using discovery. Discovery information is
+// reset periodically until stopCh is closed.
+func newScaleClient(config *rest.Config, kubeclientset kubernetes.Interface, stopCh <-chan struct{}) (scale.ScalesGetter, error) {
+	discoveryClient := kubeclientset.Discovery()
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cached.NewMemCacheClient(discoveryClient))
+	go wait.Until(mapper.Reset, discoveryResetInterval, stopCh)
+	resolver := scale.NewDiscoveryScaleKindResolver(discoveryClient)
 
-	enginecrs, err := cerebralclientset.Cerebral().AutoscalingEngines().List(metav1.ListOptions{})
+	scaleclient, err := scale.NewForConfig(config, mapper, dynamic.LegacyAPIPathResolverFunc, resolver)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	for _, enginecr := range enginecrs.Items {
-		// only register engines of type containership
-		switch strings.ToLower(enginecr.Spec.Type) {
-		case "containership":
-			engineCreated, err = initializeAndRegisterContainershipEngine(enginecr)
-			if err != nil {
-				log.Fatalf("Failed to create Containership autoscaling engine: %+v", err)
-			}
-
-		default:
-			log.Infof("Autoscaling Engine of type '%s' is unable to be registered", enginecr.Spec.Type)
-		}
-
+		return nil, errors.Wrap(err, "creating scale client")
 	}
 
-	if !engineCreated {
-		log.Fatalf("No AutoscalingEngine of type containership found. Failed to create an autoscaling engine")
-	}
+	return scaleclient, nil
 }
 
-func initializeAndRegisterContainershipEngine(enginecr cerebralv1alpha1.AutoscalingEngine) (bool, error) {
-	engine, err := containership.NewAutoscalingEngine(enginecr)
-	if err != nil {
-		return false, err
-	}
-
-	autoscalingengine.Registry().Put(engine)
-	return true, nil
+// newCustomMetricsClient returns a client for the custom metrics API,
+// resolving resources and the served API version using discovery. Discovery
+// information is reset periodically until stopCh is closed.
+func newCustomMetricsClient(config *rest.Config, kubeclientset kubernetes.Interface, stopCh <-chan struct{}) custommetricsclient.CustomMetricsClient {
+	discoveryClient := kubeclientset.Discovery()
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cached.NewMemCacheClient(discoveryClient))
+	go wait.Until(mapper.Reset, discoveryResetInterval, stopCh)
+	apiVersionsGetter := custommetricsclient.NewAvailableAPIsGetter(discoveryClient)
+	go custommetricsclient.PeriodicallyInvalidate(apiVersionsGetter, discoveryResetInterval, stopCh)
+
+	return custommetricsclient.NewForConfig(config, mapper, apiVersionsGetter)
 }
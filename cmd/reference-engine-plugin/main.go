@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"net"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	"github.com/containership/cerebral/pkg/autoscalingengine/plugin"
+	"github.com/containership/cerebral/pkg/autoscalingengine/plugin/reference"
+)
+
+// This is a reference AutoscalingEngine plugin which serves an in-memory
+// engine. It is useful for testing the plugin protocol end to end and as a
+// starting point for plugin authors.
+func main() {
+	network := flag.String("network", "unix", "network to listen on (unix or tcp)")
+	address := flag.String("address", "/var/run/cerebral/engine.sock", "socket path or host:port to listen on")
+	name := flag.String("name", "reference", "name reported by the engine")
+	flag.Parse()
+
+	lis, err := net.Listen(*network, *address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s %s: %s", *network, *address, err)
+	}
+
+	log.Infof("Serving reference engine plugin %q on %s %s", *name, *network, *address)
+
+	server := plugin.NewServer(reference.NewEngine(*name))
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("Plugin server failed: %s", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"net"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin"
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin/sample"
+)
+
+// This is a sample MetricsBackend plugin which serves static metrics. It is
+// useful for testing the plugin protocol end to end and as a starting point
+// for plugin authors.
+func main() {
+	network := flag.String("network", "unix", "network to listen on (unix or tcp)")
+	address := flag.String("address", "/var/run/cerebral/metrics.sock", "socket path or host:port to listen on")
+	flag.Parse()
+
+	lis, err := net.Listen(*network, *address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s %s: %s", *network, *address, err)
+	}
+
+	log.Infof("Serving sample metrics plugin on %s %s", *network, *address)
+
+	server := plugin.NewServer(sample.Backend{})
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("Plugin server failed: %s", err)
+	}
+}
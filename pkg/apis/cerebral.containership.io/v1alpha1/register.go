@@ -42,6 +42,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&AutoscalingGroupList{},
 		&AutoscalingPolicy{},
 		&AutoscalingPolicyList{},
+		&ScheduledScalingAction{},
+		&ScheduledScalingActionList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -5,7 +5,6 @@ import (
 )
 
 // +genclient
-// +genclient:noStatus
 // +genclient:nonNamespaced
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -14,7 +13,8 @@ type MetricsBackend struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec MetricsBackendSpec `json:"spec"`
+	Spec   MetricsBackendSpec   `json:"spec"`
+	Status MetricsBackendStatus `json:"status"`
 }
 
 // MetricsBackendSpec is the spec for a metrics backend
@@ -23,6 +23,15 @@ type MetricsBackendSpec struct {
 	Configuration map[string]string `json:"configuration"`
 }
 
+// MetricsBackendStatus is the status for a metrics backend
+type MetricsBackendStatus struct {
+	// Details is backend-specific information about the backend, e.g. the
+	// metric names detected by the Prometheus backend
+	Details map[string]string `json:"details,omitempty"`
+	// LastUpdatedAt is when the status was last updated
+	LastUpdatedAt metav1.Time `json:"lastUpdatedAt"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // MetricsBackendList is a list of MetricsBackends.
@@ -56,6 +65,14 @@ type AutoscalingGroupSpec struct {
 	MinNodes        int               `json:"minNodes"`
 	MaxNodes        int               `json:"maxNodes"`
 	ScalingStrategy *ScalingStrategy  `json:"scalingStrategy,omitempty"`
+	// ConflictResolution is how alerts from the policies that occur within
+	// AlertEvaluationWindow of each other are merged into a single scale
+	// request: "scaleUpWins", "largestAdjustment" or "vote". If empty, every
+	// alert results in a scale request in the order they occur.
+	ConflictResolution string `json:"conflictResolution,omitempty"`
+	// AlertEvaluationWindow is the number of seconds alerts are collected
+	// for before being resolved. Defaults to 30.
+	AlertEvaluationWindow int `json:"alertEvaluationWindow,omitempty"`
 }
 
 // AutoscalingGroupStatus is the status for a autoscaling group
@@ -107,6 +124,20 @@ type AutoscalingPolicySpec struct {
 type ScalingPolicy struct {
 	ScaleUp   *ScalingPolicyConfiguration `json:"scaleUp,omitempty"`
 	ScaleDown *ScalingPolicyConfiguration `json:"scaleDown,omitempty"`
+	// TargetTracking scales the node count to keep the metric near a target
+	// value. If specified, ScaleUp and ScaleDown are ignored.
+	TargetTracking *TargetTrackingConfiguration `json:"targetTracking,omitempty"`
+}
+
+// A TargetTrackingConfiguration defines the metric value to maintain. The
+// target node count is calculated proportionally to the metric value, so the
+// metric should be a per-node value such as average utilization.
+type TargetTrackingConfiguration struct {
+	TargetValue float64 `json:"targetValue"`
+	// Tolerance is the ratio by which the metric value may differ from
+	// TargetValue before scaling, e.g. 0.1 for 10%. Defaults to 0.1 if
+	// unset; 0 scales on any difference.
+	Tolerance *float64 `json:"tolerance,omitempty"`
 }
 
 // A ScalingPolicyConfiguration defines the criterion for triggering a scale event
@@ -115,6 +146,34 @@ type ScalingPolicyConfiguration struct {
 	ComparisonOperator string  `json:"comparisonOperator"`
 	AdjustmentType     string  `json:"adjustmentType"`
 	AdjustmentValue    float64 `json:"adjustmentValue"`
+	// StepAdjustments are additional thresholds beyond Threshold, compared
+	// using the same ComparisonOperator. When a scale event is triggered, the
+	// adjustment of the breached threshold furthest from Threshold is used.
+	// Steps whose threshold is not beyond Threshold are ignored.
+	StepAdjustments []StepAdjustment `json:"stepAdjustments,omitempty"`
+	// Conditions are additional metric conditions that are combined with
+	// Threshold using ConditionOperator to determine whether to scale
+	Conditions []MetricCondition `json:"conditions,omitempty"`
+	// ConditionOperator is how Threshold and Conditions are combined: "and"
+	// or "or". Defaults to "and".
+	ConditionOperator string `json:"conditionOperator,omitempty"`
+}
+
+// A MetricCondition compares the value of a metric to a threshold
+type MetricCondition struct {
+	// MetricsBackend defaults to the MetricsBackend of the policy
+	MetricsBackend      string            `json:"metricsBackend,omitempty"`
+	Metric              string            `json:"metric"`
+	MetricConfiguration map[string]string `json:"metricConfiguration,omitempty"`
+	Threshold           float64           `json:"threshold"`
+	ComparisonOperator  string            `json:"comparisonOperator"`
+}
+
+// A StepAdjustment defines the adjustment to make when a threshold is breached
+type StepAdjustment struct {
+	Threshold       float64 `json:"threshold"`
+	AdjustmentType  string  `json:"adjustmentType"`
+	AdjustmentValue float64 `json:"adjustmentValue"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -155,3 +214,54 @@ type AutoscalingEngineList struct {
 
 	Items []AutoscalingEngine `json:"items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ScheduledScalingAction describes a scaling action for an AutoscalingGroup
+// that occurs on a schedule
+type ScheduledScalingAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledScalingActionSpec   `json:"spec"`
+	Status ScheduledScalingActionStatus `json:"status"`
+}
+
+// ScheduledScalingActionSpec is the spec for a scheduled scaling action
+type ScheduledScalingActionSpec struct {
+	AutoscalingGroup string `json:"autoscalingGroup"`
+	// Schedule is a standard cron expression, e.g. "0 8 * * 1-5"
+	Schedule string `json:"schedule"`
+	// TimeZone is the IANA time zone name the schedule is evaluated in, e.g.
+	// "America/New_York". Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// TargetNodeCount is the node count to scale to at the scheduled times.
+	// It is fit within the bounds of the AutoscalingGroup, including any
+	// overrides.
+	TargetNodeCount *int `json:"targetNodeCount,omitempty"`
+	// MinNodes and MaxNodes override the bounds of the AutoscalingGroup for
+	// Duration seconds after each scheduled time
+	MinNodes  *int `json:"minNodes,omitempty"`
+	MaxNodes  *int `json:"maxNodes,omitempty"`
+	Duration  int  `json:"duration,omitempty"`
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// ScheduledScalingActionStatus is the status for a scheduled scaling action
+type ScheduledScalingActionStatus struct {
+	// LastScheduleTime is the time up to which scheduled times of the action
+	// have been handled, either by triggering or by being missed
+	LastScheduleTime metav1.Time `json:"lastScheduleTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ScheduledScalingActionList is a list of ScheduledScalingActions
+type ScheduledScalingActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ScheduledScalingAction `json:"items"`
+}
@@ -302,12 +302,36 @@ func (in *AutoscalingPolicySpec) DeepCopy() *AutoscalingPolicySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricCondition) DeepCopyInto(out *MetricCondition) {
+	*out = *in
+	if in.MetricConfiguration != nil {
+		in, out := &in.MetricConfiguration, &out.MetricConfiguration
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricCondition.
+func (in *MetricCondition) DeepCopy() *MetricCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsBackend) DeepCopyInto(out *MetricsBackend) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -385,18 +409,47 @@ func (in *MetricsBackendSpec) DeepCopy() *MetricsBackendSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsBackendStatus) DeepCopyInto(out *MetricsBackendStatus) {
+	*out = *in
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.LastUpdatedAt.DeepCopyInto(&out.LastUpdatedAt)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsBackendStatus.
+func (in *MetricsBackendStatus) DeepCopy() *MetricsBackendStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsBackendStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScalingPolicy) DeepCopyInto(out *ScalingPolicy) {
 	*out = *in
 	if in.ScaleUp != nil {
 		in, out := &in.ScaleUp, &out.ScaleUp
 		*out = new(ScalingPolicyConfiguration)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.ScaleDown != nil {
 		in, out := &in.ScaleDown, &out.ScaleDown
 		*out = new(ScalingPolicyConfiguration)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetTracking != nil {
+		in, out := &in.TargetTracking, &out.TargetTracking
+		*out = new(TargetTrackingConfiguration)
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -414,6 +467,18 @@ func (in *ScalingPolicy) DeepCopy() *ScalingPolicy {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScalingPolicyConfiguration) DeepCopyInto(out *ScalingPolicyConfiguration) {
 	*out = *in
+	if in.StepAdjustments != nil {
+		in, out := &in.StepAdjustments, &out.StepAdjustments
+		*out = make([]StepAdjustment, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MetricCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -427,6 +492,22 @@ func (in *ScalingPolicyConfiguration) DeepCopy() *ScalingPolicyConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepAdjustment) DeepCopyInto(out *StepAdjustment) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepAdjustment.
+func (in *StepAdjustment) DeepCopy() *StepAdjustment {
+	if in == nil {
+		return nil
+	}
+	out := new(StepAdjustment)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScalingStrategy) DeepCopyInto(out *ScalingStrategy) {
 	*out = *in
@@ -442,3 +523,133 @@ func (in *ScalingStrategy) DeepCopy() *ScalingStrategy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingAction) DeepCopyInto(out *ScheduledScalingAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingAction.
+func (in *ScheduledScalingAction) DeepCopy() *ScheduledScalingAction {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledScalingAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingActionList) DeepCopyInto(out *ScheduledScalingActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ScheduledScalingAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingActionList.
+func (in *ScheduledScalingActionList) DeepCopy() *ScheduledScalingActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledScalingActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingActionSpec) DeepCopyInto(out *ScheduledScalingActionSpec) {
+	*out = *in
+	if in.TargetNodeCount != nil {
+		in, out := &in.TargetNodeCount, &out.TargetNodeCount
+		*out = new(int)
+		**out = **in
+	}
+	if in.MinNodes != nil {
+		in, out := &in.MinNodes, &out.MinNodes
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxNodes != nil {
+		in, out := &in.MaxNodes, &out.MaxNodes
+		*out = new(int)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingActionSpec.
+func (in *ScheduledScalingActionSpec) DeepCopy() *ScheduledScalingActionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingActionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledScalingActionStatus) DeepCopyInto(out *ScheduledScalingActionStatus) {
+	*out = *in
+	in.LastScheduleTime.DeepCopyInto(&out.LastScheduleTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledScalingActionStatus.
+func (in *ScheduledScalingActionStatus) DeepCopy() *ScheduledScalingActionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledScalingActionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetTrackingConfiguration) DeepCopyInto(out *TargetTrackingConfiguration) {
+	*out = *in
+	if in.Tolerance != nil {
+		in, out := &in.Tolerance, &out.Tolerance
+		*out = new(float64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetTrackingConfiguration.
+func (in *TargetTrackingConfiguration) DeepCopy() *TargetTrackingConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetTrackingConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -5,3 +5,13 @@ type AutoscalingEngine interface {
 	Name() string
 	SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error)
 }
+
+// An AutoscalingGroupScaler is an AutoscalingEngine that needs to know the
+// name of the AutoscalingGroup being scaled, for example to pass it on to an
+// external service. Node selectors may be shared between AutoscalingGroups, so
+// the name can't be reliably derived from the node selector.
+type AutoscalingGroupScaler interface {
+	// SetAutoscalingGroupTargetNodeCount is called instead of
+	// SetTargetNodeCount with the name of the AutoscalingGroup being scaled
+	SetAutoscalingGroupTargetNodeCount(asgName string, nodeSelector map[string]string, numNodes int, strategy string) (bool, error)
+}
@@ -0,0 +1,320 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/nodeutil"
+	"github.com/containership/cerebral/pkg/secretutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultAutoscalingGroupLabelKey is the node label used to determine the
+	// name of the AWS Auto Scaling Group if one is not configured
+	defaultAutoscalingGroupLabelKey = "cerebral.containership.io/aws-autoscaling-group"
+
+	// Keys of the credentials Secret data
+	accessKeyIDSecretKey     = "AWS_ACCESS_KEY_ID"
+	secretAccessKeySecretKey = "AWS_SECRET_ACCESS_KEY"
+	sessionTokenSecretKey    = "AWS_SESSION_TOKEN"
+)
+
+// Engine is an autoscaling engine for AWS EC2 Auto Scaling Groups
+type Engine struct {
+	name       string
+	config     *awsConfig
+	client     autoscalingiface.AutoScalingAPI
+	nodeLister corelistersv1.NodeLister
+}
+
+type awsConfig struct {
+	Region                     string
+	Endpoint                   string
+	AutoscalingGroupLabelKey   string
+	CredentialsSecretName      string
+	CredentialsSecretNamespace string
+}
+
+// NewAutoscalingEngine creates a new instance of the AWS autoscaling engine.
+// If a credentials Secret is configured, credentials are read from it using
+// the given clientset; otherwise the default AWS credential chain is used.
+func NewAutoscalingEngine(e cerebralv1alpha1.AutoscalingEngine,
+	kubeclientset kubernetes.Interface, nodeLister corelistersv1.NodeLister) (*Engine, error) {
+	configmap := e.Spec.Configuration
+	engine := &Engine{
+		name: e.Name,
+		config: &awsConfig{
+			Region:                     configmap["region"],
+			Endpoint:                   configmap["endpoint"],
+			AutoscalingGroupLabelKey:   configmap["autoscalingGroupLabelKey"],
+			CredentialsSecretName:      configmap["credentialsSecretName"],
+			CredentialsSecretNamespace: configmap["credentialsSecretNamespace"],
+		},
+		nodeLister: nodeLister,
+	}
+
+	if engine.config.Region == "" {
+		return nil, errors.New("AWS engine requires region in configuration")
+	}
+
+	if engine.config.AutoscalingGroupLabelKey == "" {
+		engine.config.AutoscalingGroupLabelKey = defaultAutoscalingGroupLabelKey
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	awsCfg := awssdk.NewConfig().WithRegion(engine.config.Region)
+	if engine.config.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(engine.config.Endpoint)
+	}
+
+	if engine.config.CredentialsSecretName != "" {
+		creds, err := getCredentialsFromSecret(kubeclientset,
+			engine.config.CredentialsSecretNamespace, engine.config.CredentialsSecretName)
+		if err != nil {
+			return nil, err
+		}
+
+		awsCfg = awsCfg.WithCredentials(creds)
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating AWS session")
+	}
+
+	engine.client = autoscaling.New(sess)
+
+	return engine, nil
+}
+
+// secretCredentialsProvider provides static AWS credentials from a Secret.
+// The Secret is read again as needed so that rotated credentials are used.
+type secretCredentialsProvider struct {
+	secret *secretutil.Reference
+}
+
+// getCredentialsFromSecret returns AWS credentials from the Secret with the
+// given namespace and name
+func getCredentialsFromSecret(kubeclientset kubernetes.Interface, namespace, name string) (*credentials.Credentials, error) {
+	secret, err := secretutil.NewReference(kubeclientset, namespace, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading credentials Secret")
+	}
+
+	provider := &secretCredentialsProvider{secret: secret}
+	if _, err := provider.Retrieve(); err != nil {
+		return nil, err
+	}
+
+	return credentials.NewCredentials(provider), nil
+}
+
+// Retrieve returns the credentials from the Secret
+func (p *secretCredentialsProvider) Retrieve() (credentials.Value, error) {
+	id, err := p.secret.Value(accessKeyIDSecretKey)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	key, err := p.secret.Value(secretAccessKeySecretKey)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	data, err := p.secret.Data()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	return credentials.Value{
+		AccessKeyID:     id,
+		SecretAccessKey: key,
+		SessionToken:    string(data[sessionTokenSecretKey]),
+		ProviderName:    "SecretCredentialsProvider",
+	}, nil
+}
+
+// IsExpired always returns true so that credentials are retrieved for each
+// request; the Secret itself is only read again periodically
+func (p *secretCredentialsProvider) IsExpired() bool {
+	return true
+}
+
+// SetTargetNodeCount takes action to scale the Auto Scaling Group selected by
+// the node selector to the given number of instances. Scaling up simply raises
+// the desired capacity. Scaling down selects registered nodes to remove using
+// the given strategy and terminates their instances, decrementing the desired
+// capacity, so that AWS never chooses which instances are removed. Instances
+// that are still booting have no node and so cannot be selected for removal.
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	asgName, found := nodeSelector[e.config.AutoscalingGroupLabelKey]
+	if !found {
+		return false, errors.Errorf("node selector does not contain AWS Auto Scaling Group label %q", e.config.AutoscalingGroupLabelKey)
+	}
+
+	asg, err := e.describeAutoScalingGroup(asgName)
+	if err != nil {
+		return false, err
+	}
+
+	desired := int(awssdk.Int64Value(asg.DesiredCapacity))
+	if numNodes == desired {
+		log.Infof("AutoscalingEngine %s: desired capacity of Auto Scaling Group %s is already %d", e.Name(), asgName, numNodes)
+		return false, nil
+	}
+
+	if numNodes > desired {
+		log.Infof("AutoscalingEngine %s is requesting AWS to set desired capacity of Auto Scaling Group %s to %d", e.Name(), asgName, numNodes)
+		return e.setDesiredCapacity(asgName, numNodes)
+	}
+
+	nodes, err := e.listASGNodes(asg, nodeSelector)
+	if err != nil {
+		return false, err
+	}
+
+	count := desired - numNodes
+	if count > len(nodes) {
+		// Terminate what can be selected now; the remaining instances can
+		// be removed once they register as nodes
+		var scaled bool
+		if len(nodes) > 0 {
+			scaled, err = e.terminateNodes(asgName, nodes, len(nodes), strategy)
+			if err != nil {
+				return scaled, err
+			}
+		}
+
+		return scaled, errors.Errorf("cannot remove %d instances from AWS Auto Scaling Group %s that are not yet registered as nodes",
+			count-len(nodes), asgName)
+	}
+
+	return e.terminateNodes(asgName, nodes, count, strategy)
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+func (e *Engine) describeAutoScalingGroup(name string) (*autoscaling.Group, error) {
+	out, err := e.client.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{awssdk.String(name)},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "describing AWS Auto Scaling Group %s", name)
+	}
+
+	if len(out.AutoScalingGroups) != 1 {
+		return nil, errors.Errorf("expected to find a single AWS Auto Scaling Group %s but found %d", name, len(out.AutoScalingGroups))
+	}
+
+	return out.AutoScalingGroups[0], nil
+}
+
+func (e *Engine) setDesiredCapacity(asgName string, numNodes int) (bool, error) {
+	_, err := e.client.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{
+		AutoScalingGroupName: awssdk.String(asgName),
+		DesiredCapacity:      awssdk.Int64(int64(numNodes)),
+		HonorCooldown:        awssdk.Bool(false),
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "setting desired capacity of AWS Auto Scaling Group %s", asgName)
+	}
+
+	return true, nil
+}
+
+// listASGNodes returns the nodes matching the node selector whose instances
+// are members of the Auto Scaling Group
+func (e *Engine) listASGNodes(asg *autoscaling.Group, nodeSelector map[string]string) ([]*corev1.Node, error) {
+	instanceIDs := make(map[string]bool)
+	for _, instance := range asg.Instances {
+		instanceIDs[awssdk.StringValue(instance.InstanceId)] = true
+	}
+
+	nodes, err := e.nodeLister.List(nodeutil.GetNodesLabelSelector(nodeSelector))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing nodes")
+	}
+
+	var asgNodes []*corev1.Node
+	for _, node := range nodes {
+		if instanceIDs[instanceIDFromProviderID(node.Spec.ProviderID)] {
+			asgNodes = append(asgNodes, node)
+		}
+	}
+
+	return asgNodes, nil
+}
+
+// terminateNodes selects count of the given nodes and terminates their
+// instances. All selected instances are attempted even if some fail, so that
+// a single failure doesn't leave the group in an unknown state; the returned
+// error names the instances that were terminated and those that failed.
+func (e *Engine) terminateNodes(asgName string, nodes []*corev1.Node, count int, strategy string) (bool, error) {
+	toRemove, err := nodeutil.SelectNodesForRemoval(nodes, count, strategy)
+	if err != nil {
+		return false, errors.Wrapf(err, "selecting nodes to remove from AWS Auto Scaling Group %s", asgName)
+	}
+
+	var terminated, failed []string
+	for _, node := range toRemove {
+		instanceID := instanceIDFromProviderID(node.Spec.ProviderID)
+		log.Infof("AutoscalingEngine %s is requesting AWS to terminate instance %s (node %s) in Auto Scaling Group %s",
+			e.Name(), instanceID, node.Name, asgName)
+
+		_, err := e.client.TerminateInstanceInAutoScalingGroup(&autoscaling.TerminateInstanceInAutoScalingGroupInput{
+			InstanceId:                     awssdk.String(instanceID),
+			ShouldDecrementDesiredCapacity: awssdk.Bool(true),
+		})
+		if err != nil {
+			log.Errorf("AutoscalingEngine %s failed to terminate instance %s in Auto Scaling Group %s: %s",
+				e.Name(), instanceID, asgName, err)
+			failed = append(failed, fmt.Sprintf("%s (%s)", instanceID, err))
+			continue
+		}
+
+		terminated = append(terminated, instanceID)
+	}
+
+	if len(failed) > 0 {
+		return len(terminated) > 0, errors.Errorf("terminated %d of %d instances in AWS Auto Scaling Group %s: terminated [%s], failed [%s]",
+			len(terminated), len(toRemove), asgName, strings.Join(terminated, ", "), strings.Join(failed, "; "))
+	}
+
+	return true, nil
+}
+
+// instanceIDFromProviderID returns the EC2 instance ID from a node provider ID
+// of the form aws:///<availability-zone>/<instance-id>
+func instanceIDFromProviderID(providerID string) string {
+	if !strings.HasPrefix(providerID, "aws://") {
+		return ""
+	}
+
+	parts := strings.Split(providerID, "/")
+	return parts[len(parts)-1]
+}
@@ -0,0 +1,367 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+const testASGName = "test-asg"
+
+var (
+	asgSelector = map[string]string{
+		defaultAutoscalingGroupLabelKey: testASGName,
+	}
+
+	credentialsSecret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-credentials",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			accessKeyIDSecretKey:     []byte("access-key-id"),
+			secretAccessKeySecretKey: []byte("secret-access-key"),
+		},
+	}
+)
+
+// fakeAutoScalingClient implements the subset of the AWS Auto Scaling API used
+// by the engine and records the calls made to it
+type fakeAutoScalingClient struct {
+	autoscalingiface.AutoScalingAPI
+
+	group *autoscaling.Group
+
+	desiredCapacity *int64
+	terminated      []string
+
+	// Instances that fail to terminate
+	failInstances map[string]bool
+}
+
+func (f *fakeAutoScalingClient) DescribeAutoScalingGroups(in *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	if f.group == nil {
+		return &autoscaling.DescribeAutoScalingGroupsOutput{}, nil
+	}
+
+	return &autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: []*autoscaling.Group{f.group},
+	}, nil
+}
+
+func (f *fakeAutoScalingClient) SetDesiredCapacity(in *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	f.desiredCapacity = in.DesiredCapacity
+	f.group.DesiredCapacity = in.DesiredCapacity
+	return &autoscaling.SetDesiredCapacityOutput{}, nil
+}
+
+func (f *fakeAutoScalingClient) TerminateInstanceInAutoScalingGroup(in *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	if !awssdk.BoolValue(in.ShouldDecrementDesiredCapacity) {
+		return nil, fmt.Errorf("expected desired capacity to be decremented")
+	}
+
+	if f.failInstances[awssdk.StringValue(in.InstanceId)] {
+		return nil, fmt.Errorf("instance is protected from scale in")
+	}
+
+	f.terminated = append(f.terminated, awssdk.StringValue(in.InstanceId))
+	f.group.DesiredCapacity = awssdk.Int64(awssdk.Int64Value(f.group.DesiredCapacity) - 1)
+	return &autoscaling.TerminateInstanceInAutoScalingGroupOutput{}, nil
+}
+
+func buildGroup(desired int64, instanceIDs ...string) *autoscaling.Group {
+	group := &autoscaling.Group{
+		AutoScalingGroupName: awssdk.String(testASGName),
+		DesiredCapacity:      awssdk.Int64(desired),
+	}
+
+	for _, id := range instanceIDs {
+		group.Instances = append(group.Instances, &autoscaling.Instance{
+			InstanceId: awssdk.String(id),
+		})
+	}
+
+	return group
+}
+
+func buildASGNode(name, instanceID string, created int64) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            asgSelector,
+			CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+		},
+		Spec: corev1.NodeSpec{
+			ProviderID: fmt.Sprintf("aws:///us-east-1a/%s", instanceID),
+		},
+	}
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
+
+func fakeAutoscalingEngine(client *fakeAutoScalingClient, nodes []corev1.Node) *Engine {
+	return &Engine{
+		name: "aws",
+		config: &awsConfig{
+			Region:                   "us-east-1",
+			AutoscalingGroupLabelKey: defaultAutoscalingGroupLabelKey,
+		},
+		client:     client,
+		nodeLister: buildNodeLister(nodes),
+	}
+}
+
+func TestNewAutoscalingEngine(t *testing.T) {
+	nodeLister := buildNodeLister(nil)
+
+	_, err := NewAutoscalingEngine(cerebralv1alpha1.AutoscalingEngine{}, nil, nodeLister)
+	assert.Error(t, err, "region is required")
+
+	e := cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "aws",
+		},
+		Spec: cerebralv1alpha1.AutoscalingEngineSpec{
+			Type: "aws",
+			Configuration: map[string]string{
+				"region": "us-east-1",
+			},
+		},
+	}
+
+	_, err = NewAutoscalingEngine(e, nil, nil)
+	assert.Error(t, err, "node lister is required")
+
+	engine, err := NewAutoscalingEngine(e, nil, nodeLister)
+	assert.NoError(t, err, "default credential chain is ok")
+	assert.Equal(t, "aws", engine.Name())
+	assert.Equal(t, defaultAutoscalingGroupLabelKey, engine.config.AutoscalingGroupLabelKey, "label key defaulted")
+
+	e.Spec.Configuration["credentialsSecretName"] = credentialsSecret.Name
+	_, err = NewAutoscalingEngine(e, fake.NewSimpleClientset(), nodeLister)
+	assert.Error(t, err, "secret namespace is required")
+
+	e.Spec.Configuration["credentialsSecretNamespace"] = credentialsSecret.Namespace
+	_, err = NewAutoscalingEngine(e, fake.NewSimpleClientset(), nodeLister)
+	assert.Error(t, err, "secret must exist")
+
+	incompleteSecret := credentialsSecret.DeepCopy()
+	delete(incompleteSecret.Data, secretAccessKeySecretKey)
+	_, err = NewAutoscalingEngine(e, fake.NewSimpleClientset(incompleteSecret), nodeLister)
+	assert.Error(t, err, "secret must contain secret access key")
+
+	_, err = NewAutoscalingEngine(e, fake.NewSimpleClientset(&credentialsSecret), nodeLister)
+	assert.NoError(t, err, "credentials from secret")
+}
+
+func TestGetCredentialsFromSecret(t *testing.T) {
+	secret := credentialsSecret.DeepCopy()
+	secret.Data[sessionTokenSecretKey] = []byte("session-token")
+
+	creds, err := getCredentialsFromSecret(fake.NewSimpleClientset(secret), secret.Namespace, secret.Name)
+	assert.NoError(t, err)
+
+	value, err := creds.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "access-key-id", value.AccessKeyID)
+	assert.Equal(t, "secret-access-key", value.SecretAccessKey)
+	assert.Equal(t, "session-token", value.SessionToken)
+
+	_, err = getCredentialsFromSecret(nil, secret.Namespace, secret.Name)
+	assert.Error(t, err, "clientset is required")
+}
+
+func TestSetTargetNodeCount(t *testing.T) {
+	client := &fakeAutoScalingClient{
+		group: buildGroup(2, "i-1", "i-2"),
+	}
+	nodes := []corev1.Node{
+		buildASGNode("node-1", "i-1", 100),
+		buildASGNode("node-2", "i-2", 200),
+	}
+	e := fakeAutoscalingEngine(client, nodes)
+
+	_, err := e.SetTargetNodeCount(asgSelector, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	_, err = e.SetTargetNodeCount(map[string]string{}, 1, "")
+	assert.Error(t, err, "node selector must contain ASG label")
+
+	scaled, err := e.SetTargetNodeCount(asgSelector, 4, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+	assert.Equal(t, int64(4), awssdk.Int64Value(client.desiredCapacity), "scale up sets desired capacity")
+	assert.Empty(t, client.terminated, "scale up terminates nothing")
+
+	// Back to the two registered nodes
+	client.group = buildGroup(2, "i-1", "i-2")
+	client.desiredCapacity = nil
+	scaled, err = e.SetTargetNodeCount(asgSelector, 1, "oldest")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+	assert.Nil(t, client.desiredCapacity, "scale down does not set desired capacity directly")
+	assert.Equal(t, []string{"i-1"}, client.terminated, "oldest node terminated")
+
+	client.group = buildGroup(2, "i-1", "i-2")
+	client.terminated = nil
+	_, err = e.SetTargetNodeCount(asgSelector, 1, "not-a-strategy")
+	assert.Error(t, err, "unknown strategy errors")
+	assert.Empty(t, client.terminated)
+
+	_, err = e.SetTargetNodeCount(asgSelector, 0, "newest")
+	assert.NoError(t, err)
+	assert.Len(t, client.terminated, 2, "scale to zero terminates all instances")
+
+	client.group = nil
+	_, err = e.SetTargetNodeCount(asgSelector, 1, "")
+	assert.Error(t, err, "ASG must exist")
+}
+
+func TestSetTargetNodeCountInstancesBooting(t *testing.T) {
+	// Two instances are still booting and have not registered as nodes
+	client := &fakeAutoScalingClient{
+		group: buildGroup(5, "i-1", "i-2", "i-3", "i-4", "i-5"),
+	}
+	nodes := []corev1.Node{
+		buildASGNode("node-1", "i-1", 100),
+		buildASGNode("node-2", "i-2", 200),
+		buildASGNode("node-3", "i-3", 300),
+	}
+	e := fakeAutoscalingEngine(client, nodes)
+
+	scaled, err := e.SetTargetNodeCount(asgSelector, 5, "")
+	assert.NoError(t, err)
+	assert.False(t, scaled, "desired capacity already matches")
+	assert.Nil(t, client.desiredCapacity)
+
+	scaled, err = e.SetTargetNodeCount(asgSelector, 4, "oldest")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+	assert.Nil(t, client.desiredCapacity, "scale down below desired capacity does not set it directly")
+	assert.Equal(t, []string{"i-1"}, client.terminated, "removal count is based on desired capacity")
+	assert.Equal(t, int64(4), awssdk.Int64Value(client.group.DesiredCapacity))
+
+	client.terminated = nil
+	scaled, err = e.SetTargetNodeCount(asgSelector, 6, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+	assert.Equal(t, int64(6), awssdk.Int64Value(client.desiredCapacity), "scale up sets desired capacity")
+	assert.Empty(t, client.terminated)
+
+	// Only the registered nodes can be selected for removal
+	client.group = buildGroup(5, "i-1", "i-2", "i-3", "i-4", "i-5")
+	client.desiredCapacity = nil
+	scaled, err = e.SetTargetNodeCount(asgSelector, 1, "oldest")
+	assert.Error(t, err, "booting instances cannot be removed")
+	assert.True(t, scaled)
+	assert.Nil(t, client.desiredCapacity)
+	assert.Equal(t, []string{"i-1", "i-2", "i-3"}, client.terminated)
+}
+
+func TestSetTargetNodeCountPartialFailure(t *testing.T) {
+	client := &fakeAutoScalingClient{
+		group:         buildGroup(3, "i-1", "i-2", "i-3"),
+		failInstances: map[string]bool{"i-2": true},
+	}
+	nodes := []corev1.Node{
+		buildASGNode("node-1", "i-1", 100),
+		buildASGNode("node-2", "i-2", 200),
+		buildASGNode("node-3", "i-3", 300),
+	}
+	e := fakeAutoscalingEngine(client, nodes)
+
+	scaled, err := e.SetTargetNodeCount(asgSelector, 0, "oldest")
+	assert.Error(t, err)
+	assert.True(t, scaled, "some instances were terminated")
+	assert.Equal(t, []string{"i-1", "i-3"}, client.terminated, "remaining instances attempted after a failure")
+	assert.Contains(t, err.Error(), "i-2")
+}
+
+func TestSetTargetNodeCountEndpoint(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("Action")
+		actions = append(actions, action)
+
+		switch action {
+		case "DescribeAutoScalingGroups":
+			fmt.Fprintf(w, `<DescribeAutoScalingGroupsResponse>
+  <DescribeAutoScalingGroupsResult>
+    <AutoScalingGroups>
+      <member>
+        <AutoScalingGroupName>%s</AutoScalingGroupName>
+        <DesiredCapacity>1</DesiredCapacity>
+      </member>
+    </AutoScalingGroups>
+  </DescribeAutoScalingGroupsResult>
+</DescribeAutoScalingGroupsResponse>`, r.Form.Get("AutoScalingGroupNames.member.1"))
+
+		case "SetDesiredCapacity":
+			fmt.Fprint(w, `<SetDesiredCapacityResponse></SetDesiredCapacityResponse>`)
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	e := cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "aws",
+		},
+		Spec: cerebralv1alpha1.AutoscalingEngineSpec{
+			Type: "aws",
+			Configuration: map[string]string{
+				"region":                     "us-east-1",
+				"endpoint":                   server.URL,
+				"credentialsSecretName":      credentialsSecret.Name,
+				"credentialsSecretNamespace": credentialsSecret.Namespace,
+			},
+		},
+	}
+
+	engine, err := NewAutoscalingEngine(e, fake.NewSimpleClientset(&credentialsSecret), buildNodeLister(nil))
+	assert.NoError(t, err)
+
+	scaled, err := engine.SetTargetNodeCount(asgSelector, 3, "")
+	assert.NoError(t, err, "scale against stand-in API")
+	assert.True(t, scaled)
+	assert.Equal(t, []string{"DescribeAutoScalingGroups", "SetDesiredCapacity"}, actions)
+}
+
+func TestInstanceIDFromProviderID(t *testing.T) {
+	assert.Equal(t, "i-0123456789", instanceIDFromProviderID("aws:///us-east-1a/i-0123456789"))
+	assert.Empty(t, instanceIDFromProviderID(""), "empty provider ID")
+	assert.Empty(t, instanceIDFromProviderID("gce://project/zone/name"), "non-AWS provider ID")
+}
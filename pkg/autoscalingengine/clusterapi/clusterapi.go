@@ -0,0 +1,365 @@
+package clusterapi
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/nodeutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultGroup   = "cluster.x-k8s.io"
+	defaultVersion = "v1alpha3"
+
+	kindMachine           = "Machine"
+	kindMachineSet        = "MachineSet"
+	kindMachineDeployment = "MachineDeployment"
+)
+
+// Engine is an autoscaling engine for Cluster API managed clusters. It scales
+// the MachineDeployment (or MachineSet, if not owned by a MachineDeployment)
+// that owns the Machines backing the nodes of an AutoscalingGroup.
+type Engine struct {
+	name       string
+	config     *clusterAPIConfig
+	client     dynamic.Interface
+	nodeLister corelistersv1.NodeLister
+}
+
+type clusterAPIConfig struct {
+	Group     string
+	Version   string
+	Namespace string
+}
+
+// scalable is a reference to the Cluster API object that will be scaled
+type scalable struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// NewAutoscalingEngine creates a new instance of the Cluster API autoscaling
+// engine
+func NewAutoscalingEngine(e cerebralv1alpha1.AutoscalingEngine,
+	client dynamic.Interface, nodeLister corelistersv1.NodeLister) (*Engine, error) {
+	configmap := e.Spec.Configuration
+	engine := &Engine{
+		name: e.Name,
+		config: &clusterAPIConfig{
+			Group:     configmap["group"],
+			Version:   configmap["version"],
+			Namespace: configmap["namespace"],
+		},
+		client:     client,
+		nodeLister: nodeLister,
+	}
+
+	if client == nil {
+		return nil, errors.New("dynamic client must be provided")
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	if engine.config.Group == "" {
+		engine.config.Group = defaultGroup
+	}
+
+	if engine.config.Version == "" {
+		engine.config.Version = defaultVersion
+	}
+
+	if engine.config.Namespace == "" {
+		engine.config.Namespace = metav1.NamespaceDefault
+	}
+
+	return engine, nil
+}
+
+// SetTargetNodeCount takes action to scale the MachineDeployment owning the
+// nodes selected by the node selector. When scaling down, the Machines backing
+// the nodes chosen by the strategy are marked for deletion first so that they
+// are the ones removed.
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	nodes, err := e.nodeLister.List(nodeutil.GetNodesLabelSelector(nodeSelector))
+	if err != nil {
+		return false, errors.Wrap(err, "listing nodes")
+	}
+
+	if len(nodes) == 0 {
+		return false, errors.New("no nodes found to resolve owning Cluster API resource")
+	}
+
+	// Key is node name
+	machines, err := e.getMachinesForNodes(nodes)
+	if err != nil {
+		return false, err
+	}
+
+	target, err := e.getScalableForMachines(machines)
+	if err != nil {
+		return false, err
+	}
+
+	obj, current, err := e.getReplicas(target)
+	if err != nil {
+		return false, err
+	}
+
+	if numNodes < current {
+		toRemove, err := nodeutil.SelectNodesForRemoval(nodes, current-numNodes, strategy)
+		if err != nil {
+			return false, errors.Wrap(err, "selecting nodes to remove")
+		}
+
+		for _, node := range toRemove {
+			if err := e.markMachineForDeletion(machines[node.Name]); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	log.Infof("AutoscalingEngine %s is requesting Cluster API to set replicas of %s %s/%s to %d",
+		e.Name(), target.kind, target.namespace, target.name, numNodes)
+
+	// Updating the object that was read ensures that a concurrent change to
+	// the replicas is not overwritten
+	if err := unstructured.SetNestedField(obj.Object, int64(numNodes), "spec", "replicas"); err != nil {
+		return false, errors.Wrapf(err, "setting replicas of %s %s/%s", target.kind, target.namespace, target.name)
+	}
+
+	_, err = e.resource(target.kind).Namespace(target.namespace).Update(obj, metav1.UpdateOptions{})
+	if err != nil {
+		return false, errors.Wrapf(err, "updating replicas of %s %s/%s", target.kind, target.namespace, target.name)
+	}
+
+	return true, nil
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// resource returns a dynamic client for the given Cluster API kind
+func (e *Engine) resource(kind string) dynamic.NamespaceableResourceInterface {
+	var resource string
+	switch kind {
+	case kindMachineDeployment:
+		resource = "machinedeployments"
+	case kindMachineSet:
+		resource = "machinesets"
+	case kindMachine:
+		resource = "machines"
+	}
+
+	return e.client.Resource(schema.GroupVersionResource{
+		Group:    e.config.Group,
+		Version:  e.config.Version,
+		Resource: resource,
+	})
+}
+
+// machineAnnotation returns the fully qualified key of a Cluster API annotation
+func (e *Engine) machineAnnotation(key string) string {
+	return fmt.Sprintf("%s/%s", e.config.Group, key)
+}
+
+// getMachinesForNodes returns the Machine backing each node, keyed by node
+// name, using the annotations set on the nodes by Cluster API. Machines are
+// listed once per namespace rather than fetched once per node.
+func (e *Engine) getMachinesForNodes(nodes []*corev1.Node) (map[string]*unstructured.Unstructured, error) {
+	// Key is namespace, then Machine name
+	byNamespace := make(map[string]map[string]*unstructured.Unstructured)
+
+	machines := make(map[string]*unstructured.Unstructured)
+	for _, node := range nodes {
+		namespace, name, err := e.machineRefForNode(node)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaced, ok := byNamespace[namespace]
+		if !ok {
+			namespaced, err = e.listMachines(namespace)
+			if err != nil {
+				return nil, err
+			}
+
+			byNamespace[namespace] = namespaced
+		}
+
+		machine, ok := namespaced[name]
+		if !ok {
+			return nil, errors.Errorf("Machine %s/%s for node %s not found", namespace, name, node.Name)
+		}
+
+		machines[node.Name] = machine
+	}
+
+	return machines, nil
+}
+
+// machineRefForNode returns the namespace and name of the Machine backing the
+// node
+func (e *Engine) machineRefForNode(node *corev1.Node) (string, string, error) {
+	name, ok := node.Annotations[e.machineAnnotation("machine")]
+	if !ok || name == "" {
+		return "", "", errors.Errorf("node %s is not annotated with a Cluster API Machine", node.Name)
+	}
+
+	namespace, ok := node.Annotations[e.machineAnnotation("cluster-namespace")]
+	if !ok || namespace == "" {
+		namespace = e.config.Namespace
+	}
+
+	return namespace, name, nil
+}
+
+// listMachines returns the Machines in the namespace keyed by name
+func (e *Engine) listMachines(namespace string) (map[string]*unstructured.Unstructured, error) {
+	list, err := e.resource(kindMachine).Namespace(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing Machines in namespace %s", namespace)
+	}
+
+	machines := make(map[string]*unstructured.Unstructured)
+	for i := range list.Items {
+		machine := &list.Items[i]
+		machines[machine.GetName()] = machine
+	}
+
+	return machines, nil
+}
+
+// getScalableForMachines returns the single Cluster API resource to scale for
+// the Machines. Each distinct owning MachineSet is fetched only once.
+func (e *Engine) getScalableForMachines(machines map[string]*unstructured.Unstructured) (*scalable, error) {
+	// Key is namespace/name of the MachineSet
+	owners := make(map[string]*scalable)
+
+	var target *scalable
+	for _, machine := range machines {
+		namespace := machine.GetNamespace()
+
+		machineSetName, ok := ownerNameOfKind(machine, kindMachineSet)
+		if !ok {
+			return nil, errors.Errorf("Machine %s/%s is not owned by a MachineSet", namespace, machine.GetName())
+		}
+
+		key := namespace + "/" + machineSetName
+		owner, ok := owners[key]
+		if !ok {
+			var err error
+			owner, err = e.getScalableForMachineSet(namespace, machineSetName)
+			if err != nil {
+				return nil, err
+			}
+
+			owners[key] = owner
+		}
+
+		if target == nil {
+			target = owner
+		} else if *target != *owner {
+			return nil, errors.Errorf("nodes belong to multiple Cluster API resources (%s %s/%s and %s %s/%s)",
+				target.kind, target.namespace, target.name, owner.kind, owner.namespace, owner.name)
+		}
+	}
+
+	return target, nil
+}
+
+// getScalableForMachineSet returns the MachineDeployment owning the
+// MachineSet, or the MachineSet itself if it is not owned by a
+// MachineDeployment
+func (e *Engine) getScalableForMachineSet(namespace, name string) (*scalable, error) {
+	machineSet, err := e.resource(kindMachineSet).Namespace(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting MachineSet %s/%s", namespace, name)
+	}
+
+	if deploymentName, ok := ownerNameOfKind(machineSet, kindMachineDeployment); ok {
+		return &scalable{
+			kind:      kindMachineDeployment,
+			namespace: namespace,
+			name:      deploymentName,
+		}, nil
+	}
+
+	return &scalable{
+		kind:      kindMachineSet,
+		namespace: namespace,
+		name:      name,
+	}, nil
+}
+
+// getReplicas returns the target object along with its replicas
+func (e *Engine) getReplicas(target *scalable) (*unstructured.Unstructured, int, error) {
+	obj, err := e.resource(target.kind).Namespace(target.namespace).Get(target.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "getting %s %s/%s", target.kind, target.namespace, target.name)
+	}
+
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "getting replicas of %s %s/%s", target.kind, target.namespace, target.name)
+	}
+
+	if !found {
+		// Replicas is defaulted to 1 by Cluster API
+		replicas = 1
+	}
+
+	return obj, int(replicas), nil
+}
+
+// markMachineForDeletion annotates the Machine such that the owning MachineSet
+// will prioritize it for deletion on scale down
+func (e *Engine) markMachineForDeletion(machine *unstructured.Unstructured) error {
+	machine = machine.DeepCopy()
+
+	annotations := machine.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[e.machineAnnotation("delete-machine")] = "yes"
+	machine.SetAnnotations(annotations)
+
+	log.Infof("AutoscalingEngine %s is marking Machine %s/%s for deletion", e.Name(), machine.GetNamespace(), machine.GetName())
+
+	_, err := e.resource(kindMachine).Namespace(machine.GetNamespace()).Update(machine, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "marking Machine %s/%s for deletion", machine.GetNamespace(), machine.GetName())
+	}
+
+	return nil
+}
+
+// ownerNameOfKind returns the name of the first owner of the given kind
+func ownerNameOfKind(obj *unstructured.Unstructured, kind string) (string, bool) {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == kind {
+			return ref.Name, true
+		}
+	}
+
+	return "", false
+}
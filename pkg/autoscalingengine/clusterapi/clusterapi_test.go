@@ -0,0 +1,241 @@
+package clusterapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+const testNamespace = "capi"
+
+var poolSelector = map[string]string{
+	"pool": "workers",
+}
+
+func buildObject(kind, name string, ownerKind, ownerName string, replicas int64) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(defaultGroup + "/" + defaultVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(testNamespace)
+	obj.SetName(name)
+
+	if ownerKind != "" {
+		obj.SetOwnerReferences([]metav1.OwnerReference{
+			{
+				APIVersion: defaultGroup + "/" + defaultVersion,
+				Kind:       ownerKind,
+				Name:       ownerName,
+			},
+		})
+	}
+
+	if replicas >= 0 {
+		unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas")
+	}
+
+	return obj
+}
+
+func buildMachineNode(name, machineName string, created int64) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            poolSelector,
+			CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+			Annotations: map[string]string{
+				defaultGroup + "/machine":           machineName,
+				defaultGroup + "/cluster-namespace": testNamespace,
+			},
+		},
+	}
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
+
+func fakeAutoscalingEngine(nodes []corev1.Node, objects ...runtime.Object) (*Engine, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	return &Engine{
+		name: "clusterapi",
+		config: &clusterAPIConfig{
+			Group:     defaultGroup,
+			Version:   defaultVersion,
+			Namespace: metav1.NamespaceDefault,
+		},
+		client:     client,
+		nodeLister: buildNodeLister(nodes),
+	}, client
+}
+
+func TestNewAutoscalingEngine(t *testing.T) {
+	e := cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "clusterapi",
+		},
+	}
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	_, err := NewAutoscalingEngine(e, nil, buildNodeLister(nil))
+	assert.Error(t, err, "dynamic client is required")
+
+	_, err = NewAutoscalingEngine(e, client, nil)
+	assert.Error(t, err, "node lister is required")
+
+	engine, err := NewAutoscalingEngine(e, client, buildNodeLister(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "clusterapi", engine.Name())
+	assert.Equal(t, defaultGroup, engine.config.Group, "group defaulted")
+	assert.Equal(t, defaultVersion, engine.config.Version, "version defaulted")
+	assert.Equal(t, metav1.NamespaceDefault, engine.config.Namespace, "namespace defaulted")
+}
+
+func TestSetTargetNodeCount(t *testing.T) {
+	nodes := []corev1.Node{
+		buildMachineNode("node-0", "machine-0", 100),
+		buildMachineNode("node-1", "machine-1", 200),
+	}
+
+	e, client := fakeAutoscalingEngine(nodes,
+		buildObject(kindMachineDeployment, "workers", "", "", 2),
+		buildObject(kindMachineSet, "workers-abcde", kindMachineDeployment, "workers", 2),
+		buildObject(kindMachine, "machine-0", kindMachineSet, "workers-abcde", -1),
+		buildObject(kindMachine, "machine-1", kindMachineSet, "workers-abcde", -1),
+	)
+
+	_, err := e.SetTargetNodeCount(poolSelector, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	_, err = e.SetTargetNodeCount(map[string]string{"pool": "nonexistent"}, 1, "")
+	assert.Error(t, err, "no nodes to resolve owner")
+
+	scaled, err := e.SetTargetNodeCount(poolSelector, 3, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	md, _ := e.resource(kindMachineDeployment).Namespace(testNamespace).Get("workers", metav1.GetOptions{})
+	replicas, _, _ := unstructured.NestedInt64(md.Object, "spec", "replicas")
+	assert.Equal(t, int64(3), replicas, "MachineDeployment scaled up")
+
+	scaled, err = e.SetTargetNodeCount(poolSelector, 2, "newest")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	md, _ = e.resource(kindMachineDeployment).Namespace(testNamespace).Get("workers", metav1.GetOptions{})
+	replicas, _, _ = unstructured.NestedInt64(md.Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas, "MachineDeployment scaled down")
+
+	m1, _ := e.resource(kindMachine).Namespace(testNamespace).Get("machine-1", metav1.GetOptions{})
+	assert.Contains(t, m1.GetAnnotations(), e.machineAnnotation("delete-machine"), "newest machine marked for deletion")
+
+	m0, _ := e.resource(kindMachine).Namespace(testNamespace).Get("machine-0", metav1.GetOptions{})
+	assert.NotContains(t, m0.GetAnnotations(), e.machineAnnotation("delete-machine"), "oldest machine not marked for deletion")
+
+	assert.NotEmpty(t, client.Actions())
+}
+
+func TestSetTargetNodeCountRequests(t *testing.T) {
+	var nodes []corev1.Node
+	objects := []runtime.Object{
+		buildObject(kindMachineDeployment, "workers", "", "", 10),
+		buildObject(kindMachineSet, "workers-abcde", kindMachineDeployment, "workers", 10),
+	}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("machine-%d", i)
+		nodes = append(nodes, buildMachineNode(fmt.Sprintf("node-%d", i), name, int64(i)))
+		objects = append(objects, buildObject(kindMachine, name, kindMachineSet, "workers-abcde", -1))
+	}
+
+	e, client := fakeAutoscalingEngine(nodes, objects...)
+
+	_, err := e.SetTargetNodeCount(poolSelector, 12, "")
+	assert.NoError(t, err)
+
+	counts := make(map[string]int)
+	for _, action := range client.Actions() {
+		counts[action.GetVerb()+" "+action.GetResource().Resource]++
+	}
+
+	assert.Equal(t, map[string]int{
+		"list machines":             1,
+		"get machinesets":           1,
+		"get machinedeployments":    1,
+		"update machinedeployments": 1,
+	}, counts, "requests do not grow with the number of nodes")
+}
+
+func TestSetTargetNodeCountMachineSetOnly(t *testing.T) {
+	nodes := []corev1.Node{
+		buildMachineNode("node-0", "machine-0", 100),
+	}
+
+	e, _ := fakeAutoscalingEngine(nodes,
+		buildObject(kindMachineSet, "workers", "", "", 1),
+		buildObject(kindMachine, "machine-0", kindMachineSet, "workers", -1),
+	)
+
+	scaled, err := e.SetTargetNodeCount(poolSelector, 4, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	ms, _ := e.resource(kindMachineSet).Namespace(testNamespace).Get("workers", metav1.GetOptions{})
+	replicas, _, _ := unstructured.NestedInt64(ms.Object, "spec", "replicas")
+	assert.Equal(t, int64(4), replicas, "MachineSet without owner scaled directly")
+}
+
+func TestSetTargetNodeCountErrors(t *testing.T) {
+	unannotated := buildMachineNode("node-0", "machine-0", 100)
+	unannotated.Annotations = nil
+
+	e, _ := fakeAutoscalingEngine([]corev1.Node{unannotated})
+	_, err := e.SetTargetNodeCount(poolSelector, 1, "")
+	assert.Error(t, err, "node without machine annotation")
+
+	e, _ = fakeAutoscalingEngine([]corev1.Node{buildMachineNode("node-0", "machine-0", 100)})
+	_, err = e.SetTargetNodeCount(poolSelector, 1, "")
+	assert.Error(t, err, "machine does not exist")
+
+	e, _ = fakeAutoscalingEngine([]corev1.Node{buildMachineNode("node-0", "machine-0", 100)},
+		buildObject(kindMachine, "machine-0", "", "", -1))
+	_, err = e.SetTargetNodeCount(poolSelector, 1, "")
+	assert.Error(t, err, "machine not owned by a MachineSet")
+
+	e, _ = fakeAutoscalingEngine([]corev1.Node{
+		buildMachineNode("node-0", "machine-0", 100),
+		buildMachineNode("node-1", "machine-1", 100),
+	},
+		buildObject(kindMachineSet, "workers-a", "", "", 1),
+		buildObject(kindMachineSet, "workers-b", "", "", 1),
+		buildObject(kindMachine, "machine-0", kindMachineSet, "workers-a", -1),
+		buildObject(kindMachine, "machine-1", kindMachineSet, "workers-b", -1),
+	)
+	_, err = e.SetTargetNodeCount(poolSelector, 1, "")
+	assert.Error(t, err, "nodes span multiple MachineSets")
+}
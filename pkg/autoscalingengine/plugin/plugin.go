@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/pluginutil"
+
+	"github.com/pkg/errors"
+)
+
+// Engine is an autoscaling engine that delegates to an out-of-process plugin
+// implementing the AutoscalingEngine gRPC service defined in engine.proto
+type Engine struct {
+	name   string
+	config *pluginutil.Config
+	conn   *grpc.ClientConn
+}
+
+// NewAutoscalingEngine creates a new instance of the plugin autoscaling engine.
+// It returns an error if the plugin is not reachable and healthy.
+func NewAutoscalingEngine(e cerebralv1alpha1.AutoscalingEngine) (*Engine, error) {
+	config, err := pluginutil.ConfigFromMap(e.Spec.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := config.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		name:   e.Name,
+		config: config,
+		conn:   conn,
+	}
+
+	if err := pluginutil.CheckHealth(conn, ServiceName, config.Timeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	pluginName, err := engine.pluginName()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	log.Infof("AutoscalingEngine %s connected to plugin %q", engine.Name(), pluginName)
+
+	return engine, nil
+}
+
+// SetTargetNodeCount requests that the plugin scale the nodes selected by the
+// node selector to the given count
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	resp := &SetTargetNodeCountResponse{}
+	err := e.conn.Invoke(ctx, fullMethod("SetTargetNodeCount"), &SetTargetNodeCountRequest{
+		NodeSelector: nodeSelector,
+		NumNodes:     int32(numNodes),
+		Strategy:     strategy,
+	}, resp)
+	if err != nil {
+		return false, errors.Wrap(err, "plugin failed to set target node count")
+	}
+
+	return resp.Scaled, nil
+}
+
+// GetTargetNodeCount returns the target node count reported by the plugin for
+// the nodes selected by the node selector. Plugins are not required to
+// implement this.
+func (e *Engine) GetTargetNodeCount(nodeSelector map[string]string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	resp := &GetTargetNodeCountResponse{}
+	err := e.conn.Invoke(ctx, fullMethod("GetTargetNodeCount"), &GetTargetNodeCountRequest{
+		NodeSelector: nodeSelector,
+	}, resp)
+	if err != nil {
+		return 0, errors.Wrap(err, "plugin failed to get target node count")
+	}
+
+	return int(resp.NumNodes), nil
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Close closes the connection to the plugin
+func (e *Engine) Close() error {
+	return e.conn.Close()
+}
+
+// pluginName returns the name reported by the plugin itself
+func (e *Engine) pluginName() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.config.Timeout)
+	defer cancel()
+
+	resp := &NameResponse{}
+	if err := e.conn.Invoke(ctx, fullMethod("Name"), &NameRequest{}, resp); err != nil {
+		return "", errors.Wrap(err, "getting plugin name")
+	}
+
+	return resp.Name, nil
+}
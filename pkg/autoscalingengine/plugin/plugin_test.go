@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/autoscalingengine"
+	"github.com/containership/cerebral/pkg/autoscalingengine/plugin/reference"
+)
+
+var selector = map[string]string{
+	"pool": "plugin",
+}
+
+// errorEngine is an engine that always errors and does not implement
+// TargetNodeCountGetter
+type errorEngine struct{}
+
+func (errorEngine) Name() string {
+	return "error"
+}
+
+func (errorEngine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	return false, fmt.Errorf("some plugin error")
+}
+
+func buildEngineCR(socket string) cerebralv1alpha1.AutoscalingEngine {
+	return cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "plugin",
+		},
+		Spec: cerebralv1alpha1.AutoscalingEngineSpec{
+			Type: "grpc",
+			Configuration: map[string]string{
+				"socket":         socket,
+				"timeoutSeconds": "5",
+			},
+		},
+	}
+}
+
+// startPlugin serves the given engine on a Unix socket in a temporary
+// directory, returning the socket path and a cleanup function
+func startPlugin(t *testing.T, engine autoscalingengine.AutoscalingEngine) (string, func()) {
+	dir, err := ioutil.TempDir("", "engine-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(engine)
+	go server.Serve(lis)
+
+	return socket, func() {
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewAutoscalingEngine(t *testing.T) {
+	_, err := NewAutoscalingEngine(cerebralv1alpha1.AutoscalingEngine{})
+	assert.Error(t, err, "address or socket is required")
+
+	_, err = NewAutoscalingEngine(buildEngineCR("/does/not/exist.sock"))
+	assert.Error(t, err, "plugin must be reachable")
+
+	socket, cleanup := startPlugin(t, reference.NewEngine("reference"))
+	defer cleanup()
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(socket))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	assert.Equal(t, "plugin", engine.Name(), "name is the AutoscalingEngine name")
+
+	pluginName, err := engine.pluginName()
+	assert.NoError(t, err)
+	assert.Equal(t, "reference", pluginName, "plugin reports its own name")
+}
+
+func TestSetAndGetTargetNodeCount(t *testing.T) {
+	socket, cleanup := startPlugin(t, reference.NewEngine("reference"))
+	defer cleanup()
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(socket))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	_, err = engine.SetTargetNodeCount(selector, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	_, err = engine.GetTargetNodeCount(selector)
+	assert.Error(t, err, "no target set yet")
+
+	scaled, err := engine.SetTargetNodeCount(selector, 3, "random")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	numNodes, err := engine.GetTargetNodeCount(selector)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, numNodes, "target round trips through plugin")
+
+	scaled, err = engine.SetTargetNodeCount(selector, 3, "random")
+	assert.NoError(t, err)
+	assert.False(t, scaled, "plugin reports no-op")
+}
+
+func TestPluginErrors(t *testing.T) {
+	socket, cleanup := startPlugin(t, errorEngine{})
+	defer cleanup()
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(socket))
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	_, err = engine.SetTargetNodeCount(selector, 1, "")
+	assert.Error(t, err, "plugin errors are propagated")
+	assert.Contains(t, err.Error(), "some plugin error")
+
+	_, err = engine.GetTargetNodeCount(selector)
+	assert.Error(t, err, "GetTargetNodeCount unimplemented by plugin")
+}
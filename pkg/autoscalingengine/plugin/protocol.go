@@ -0,0 +1,203 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/grpc"
+)
+
+// The types in this file mirror engine.proto. They are maintained by hand
+// (rather than generated by protoc) so that building Cerebral does not
+// require a protobuf toolchain; any change here must be reflected in
+// engine.proto and vice versa.
+
+// ServiceName is the fully qualified name of the plugin gRPC service. It is
+// also the service name plugins must report as serving via the gRPC health
+// checking protocol.
+const ServiceName = "cerebral.autoscalingengine.v1.AutoscalingEngine"
+
+// NameRequest is the request for AutoscalingEngine.Name
+type NameRequest struct{}
+
+// Reset implements proto.Message
+func (m *NameRequest) Reset() { *m = NameRequest{} }
+
+// String implements proto.Message
+func (m *NameRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*NameRequest) ProtoMessage() {}
+
+// NameResponse is the response for AutoscalingEngine.Name
+type NameResponse struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *NameResponse) Reset() { *m = NameResponse{} }
+
+// String implements proto.Message
+func (m *NameResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*NameResponse) ProtoMessage() {}
+
+// SetTargetNodeCountRequest is the request for AutoscalingEngine.SetTargetNodeCount
+type SetTargetNodeCountRequest struct {
+	NodeSelector map[string]string `protobuf:"bytes,1,rep,name=node_selector,json=nodeSelector,proto3" json:"node_selector,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	NumNodes     int32             `protobuf:"varint,2,opt,name=num_nodes,json=numNodes,proto3" json:"num_nodes,omitempty"`
+	Strategy     string            `protobuf:"bytes,3,opt,name=strategy,proto3" json:"strategy,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *SetTargetNodeCountRequest) Reset() { *m = SetTargetNodeCountRequest{} }
+
+// String implements proto.Message
+func (m *SetTargetNodeCountRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*SetTargetNodeCountRequest) ProtoMessage() {}
+
+// SetTargetNodeCountResponse is the response for AutoscalingEngine.SetTargetNodeCount
+type SetTargetNodeCountResponse struct {
+	Scaled bool `protobuf:"varint,1,opt,name=scaled,proto3" json:"scaled,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *SetTargetNodeCountResponse) Reset() { *m = SetTargetNodeCountResponse{} }
+
+// String implements proto.Message
+func (m *SetTargetNodeCountResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*SetTargetNodeCountResponse) ProtoMessage() {}
+
+// GetTargetNodeCountRequest is the request for AutoscalingEngine.GetTargetNodeCount
+type GetTargetNodeCountRequest struct {
+	NodeSelector map[string]string `protobuf:"bytes,1,rep,name=node_selector,json=nodeSelector,proto3" json:"node_selector,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+// Reset implements proto.Message
+func (m *GetTargetNodeCountRequest) Reset() { *m = GetTargetNodeCountRequest{} }
+
+// String implements proto.Message
+func (m *GetTargetNodeCountRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*GetTargetNodeCountRequest) ProtoMessage() {}
+
+// GetTargetNodeCountResponse is the response for AutoscalingEngine.GetTargetNodeCount
+type GetTargetNodeCountResponse struct {
+	NumNodes int32 `protobuf:"varint,1,opt,name=num_nodes,json=numNodes,proto3" json:"num_nodes,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *GetTargetNodeCountResponse) Reset() { *m = GetTargetNodeCountResponse{} }
+
+// String implements proto.Message
+func (m *GetTargetNodeCountResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*GetTargetNodeCountResponse) ProtoMessage() {}
+
+// AutoscalingEngineServer is the server API for the plugin service
+type AutoscalingEngineServer interface {
+	Name(context.Context, *NameRequest) (*NameResponse, error)
+	SetTargetNodeCount(context.Context, *SetTargetNodeCountRequest) (*SetTargetNodeCountResponse, error)
+	GetTargetNodeCount(context.Context, *GetTargetNodeCountRequest) (*GetTargetNodeCountResponse, error)
+}
+
+// RegisterAutoscalingEngineServer registers the plugin service with a gRPC server
+func RegisterAutoscalingEngineServer(s *grpc.Server, srv AutoscalingEngineServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*AutoscalingEngineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Name",
+			Handler:    nameHandler,
+		},
+		{
+			MethodName: "SetTargetNodeCount",
+			Handler:    setTargetNodeCountHandler,
+		},
+		{
+			MethodName: "GetTargetNodeCount",
+			Handler:    getTargetNodeCountHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "engine.proto",
+}
+
+func fullMethod(method string) string {
+	return "/" + ServiceName + "/" + method
+}
+
+func nameHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(AutoscalingEngineServer).Name(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod("Name"),
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutoscalingEngineServer).Name(ctx, req.(*NameRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+func setTargetNodeCountHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetTargetNodeCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(AutoscalingEngineServer).SetTargetNodeCount(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod("SetTargetNodeCount"),
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutoscalingEngineServer).SetTargetNodeCount(ctx, req.(*SetTargetNodeCountRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+func getTargetNodeCountHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTargetNodeCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(AutoscalingEngineServer).GetTargetNodeCount(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod("GetTargetNodeCount"),
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AutoscalingEngineServer).GetTargetNodeCount(ctx, req.(*GetTargetNodeCountRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
@@ -0,0 +1,68 @@
+// Package reference provides a reference AutoscalingEngine implementation
+// for serving as an out-of-process plugin. It does not scale anything; it
+// simply remembers the target node counts it has been asked to set. It is
+// intended for testing and as an example for plugin authors.
+package reference
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/containership/cerebral/pkg/nodeutil"
+)
+
+// Engine is an in-memory AutoscalingEngine
+type Engine struct {
+	sync.RWMutex
+
+	name string
+	// Key is the string representation of the node selector
+	targets map[string]int
+}
+
+// NewEngine returns a new in-memory engine with the given name
+func NewEngine(name string) *Engine {
+	return &Engine{
+		name:    name,
+		targets: make(map[string]int),
+	}
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// SetTargetNodeCount records the target node count for the node selector
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	key := nodeutil.GetNodesLabelSelector(nodeSelector).String()
+	if current, ok := e.targets[key]; ok && current == numNodes {
+		return false, nil
+	}
+
+	e.targets[key] = numNodes
+	return true, nil
+}
+
+// GetTargetNodeCount returns the last target node count set for the node
+// selector
+func (e *Engine) GetTargetNodeCount(nodeSelector map[string]string) (int, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	key := nodeutil.GetNodesLabelSelector(nodeSelector).String()
+	numNodes, ok := e.targets[key]
+	if !ok {
+		return 0, errors.Errorf("no target node count set for node selector %q", key)
+	}
+
+	return numNodes, nil
+}
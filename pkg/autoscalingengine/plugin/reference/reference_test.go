@@ -0,0 +1,35 @@
+package reference
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine(t *testing.T) {
+	e := NewEngine("reference")
+	assert.Equal(t, "reference", e.Name())
+
+	selector := map[string]string{
+		"b": "2",
+		"a": "1",
+	}
+
+	_, err := e.SetTargetNodeCount(selector, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	_, err = e.GetTargetNodeCount(selector)
+	assert.Error(t, err, "no target set")
+
+	scaled, err := e.SetTargetNodeCount(selector, 2, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	scaled, err = e.SetTargetNodeCount(map[string]string{"a": "1", "b": "2"}, 2, "")
+	assert.NoError(t, err)
+	assert.False(t, scaled, "same selector and target is a noop")
+
+	numNodes, err := e.GetTargetNodeCount(selector)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numNodes)
+}
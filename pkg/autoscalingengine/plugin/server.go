@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/containership/cerebral/pkg/autoscalingengine"
+)
+
+// TargetNodeCountGetter may optionally be implemented by an AutoscalingEngine
+// served by NewServer in order to support GetTargetNodeCount
+type TargetNodeCountGetter interface {
+	GetTargetNodeCount(nodeSelector map[string]string) (int, error)
+}
+
+// NewServer returns a gRPC server serving the given engine using the plugin
+// protocol, along with the gRPC health checking service. This is intended for
+// use by plugin authors writing plugins in Go.
+func NewServer(engine autoscalingengine.AutoscalingEngine, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+
+	RegisterAutoscalingEngineServer(s, &engineServer{
+		engine: engine,
+	})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	return s
+}
+
+// engineServer adapts an AutoscalingEngine to the plugin service
+type engineServer struct {
+	engine autoscalingengine.AutoscalingEngine
+}
+
+func (s *engineServer) Name(ctx context.Context, req *NameRequest) (*NameResponse, error) {
+	return &NameResponse{
+		Name: s.engine.Name(),
+	}, nil
+}
+
+func (s *engineServer) SetTargetNodeCount(ctx context.Context, req *SetTargetNodeCountRequest) (*SetTargetNodeCountResponse, error) {
+	scaled, err := s.engine.SetTargetNodeCount(req.NodeSelector, int(req.NumNodes), req.Strategy)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	return &SetTargetNodeCountResponse{
+		Scaled: scaled,
+	}, nil
+}
+
+func (s *engineServer) GetTargetNodeCount(ctx context.Context, req *GetTargetNodeCountRequest) (*GetTargetNodeCountResponse, error) {
+	getter, ok := s.engine.(TargetNodeCountGetter)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "engine does not support GetTargetNodeCount")
+	}
+
+	numNodes, err := getter.GetTargetNodeCount(req.NodeSelector)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	return &GetTargetNodeCountResponse{
+		NumNodes: int32(numNodes),
+	}, nil
+}
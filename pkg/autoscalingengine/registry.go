@@ -1,20 +1,24 @@
 package autoscalingengine
 
 import (
+	"sync"
+
 	"github.com/containership/cluster-manager/pkg/log"
 
 	"github.com/pkg/errors"
 )
 
-// RegistryInterface is an interface for an AutoscalingEngin. This
-// registry is not thread safe.
+// RegistryInterface is an interface to an AutoscalingEngine registry.
+// See function comments for implementation limitations.
 type RegistryInterface interface {
 	Put(engine AutoscalingEngine)
 	Get(name string) (AutoscalingEngine, error)
+	Delete(name string)
 	IsRegistered(name string) bool
 }
 
 type registry struct {
+	sync.RWMutex
 	items map[string]AutoscalingEngine
 }
 
@@ -31,6 +35,9 @@ func Registry() RegistryInterface {
 // AutoscalingEngine's name. If the AutoscalingEngine already exists it will be
 // overwritten
 func (r *registry) Put(engine AutoscalingEngine) {
+	r.Lock()
+	defer r.Unlock()
+
 	log.Infof("Registered Autoscaling Engine %q", engine.Name())
 	r.items[engine.Name()] = engine
 }
@@ -38,12 +45,20 @@ func (r *registry) Put(engine AutoscalingEngine) {
 // IsRegistered returns true if the name corresponds to an engine that has been
 // registered
 func (r *registry) IsRegistered(name string) bool {
+	r.RLock()
+	defer r.RUnlock()
+
 	_, found := r.items[name]
 	return found
 }
 
-// Get returns the AutoscalingEngine that is registered to the name
+// Get returns the AutoscalingEngine that is registered to the name. An
+// AutoscalingEngine returned is not guaranteed to be valid still; it's assumed
+// that the caller will handle engine errors appropriately.
 func (r *registry) Get(name string) (AutoscalingEngine, error) {
+	r.RLock()
+	defer r.RUnlock()
+
 	e, found := r.items[name]
 	if !found {
 		return nil, errors.Errorf("Autoscaling Engine '%s' not found", name)
@@ -51,3 +66,14 @@ func (r *registry) Get(name string) (AutoscalingEngine, error) {
 
 	return e, nil
 }
+
+// Delete deletes the AutoscalingEngine with the given name from the registry,
+// or noops if the AutoscalingEngine doesn't exist. It only deletes it from the
+// registry; it does not clean up the underlying type.
+func (r *registry) Delete(name string) {
+	r.Lock()
+	defer r.Unlock()
+
+	log.Infof("Unregistered Autoscaling Engine %q", name)
+	delete(r.items, name)
+}
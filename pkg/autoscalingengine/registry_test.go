@@ -80,3 +80,20 @@ func TestIsRegistered(t *testing.T) {
 	e = r.IsRegistered("invalidengine")
 	assert.False(t, e)
 }
+
+func TestDelete(t *testing.T) {
+	r := registry{
+		items: make(map[string]AutoscalingEngine),
+	}
+	te := NewTestAutoscalingEngine()
+	r.Put(te)
+
+	r.Delete(te.name)
+	assert.False(t, r.IsRegistered(te.name), "engine deleted properly")
+
+	_, err := r.Get(te.name)
+	assert.Error(t, err, "get engine that was deleted")
+	assert.Empty(t, r.items, "registry emptied out cleanly")
+
+	assert.NotPanics(t, func() { r.Delete("invalidengine") }, "deleting nonexistent engine is a noop")
+}
@@ -0,0 +1,102 @@
+package scalesubresource
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/scale"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+
+	"github.com/pkg/errors"
+)
+
+// Engine is an autoscaling engine that scales any Kubernetes object exposing
+// the /scale subresource, e.g. a Deployment of hollow nodes or a custom node
+// pool resource. The target object is fixed by the engine configuration, so
+// the node selector and strategy of a scale request are not used.
+type Engine struct {
+	name   string
+	config *scaleConfig
+	scales scale.ScalesGetter
+}
+
+type scaleConfig struct {
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// NewAutoscalingEngine creates a new instance of the scale subresource
+// autoscaling engine. The version of the target resource is resolved via
+// discovery by the scale client, so only its group and resource are required.
+func NewAutoscalingEngine(e cerebralv1alpha1.AutoscalingEngine, scales scale.ScalesGetter) (*Engine, error) {
+	configmap := e.Spec.Configuration
+	engine := &Engine{
+		name: e.Name,
+		config: &scaleConfig{
+			// An empty group refers to the core API group
+			Group:     configmap["group"],
+			Resource:  configmap["resource"],
+			Namespace: configmap["namespace"],
+			Name:      configmap["name"],
+		},
+		scales: scales,
+	}
+
+	if scales == nil {
+		return nil, errors.New("scale client must be provided")
+	}
+
+	if engine.config.Resource == "" {
+		return nil, errors.New("scale subresource engine requires resource in configuration")
+	}
+
+	if engine.config.Name == "" {
+		return nil, errors.New("scale subresource engine requires name in configuration")
+	}
+
+	return engine, nil
+}
+
+// SetTargetNodeCount sets the replica count of the configured object
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	resource := e.resource()
+
+	s, err := e.scales.Scales(e.config.Namespace).Get(resource, e.config.Name)
+	if err != nil {
+		return false, errors.Wrapf(err, "getting scale of %s %q", resource.String(), e.config.Name)
+	}
+
+	if int(s.Spec.Replicas) == numNodes {
+		return false, nil
+	}
+
+	log.Infof("AutoscalingEngine %s is setting replicas of %s %q to %d", e.Name(), resource.String(), e.config.Name, numNodes)
+
+	s.Spec.Replicas = int32(numNodes)
+	_, err = e.scales.Scales(e.config.Namespace).Update(resource, s)
+	if err != nil {
+		return false, errors.Wrapf(err, "updating scale of %s %q", resource.String(), e.config.Name)
+	}
+
+	return true, nil
+}
+
+// resource returns the configured group resource
+func (e *Engine) resource() schema.GroupResource {
+	return schema.GroupResource{
+		Group:    e.config.Group,
+		Resource: e.config.Resource,
+	}
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
@@ -0,0 +1,123 @@
+package scalesubresource
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakescale "k8s.io/client-go/scale/fake"
+	core "k8s.io/client-go/testing"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+func buildEngineCR(configuration map[string]string) cerebralv1alpha1.AutoscalingEngine {
+	return cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "hollow-nodes",
+		},
+		Spec: cerebralv1alpha1.AutoscalingEngineSpec{
+			Type:          "scale-subresource",
+			Configuration: configuration,
+		},
+	}
+}
+
+// buildFakeScaleClient returns a fake scale client backed by a single scale
+// object for the deployment with the given name
+func buildFakeScaleClient(name string, replicas int32) (*fakescale.FakeScaleClient, *autoscalingv1.Scale) {
+	client := &fakescale.FakeScaleClient{}
+	current := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kubemark",
+		},
+		Spec: autoscalingv1.ScaleSpec{
+			Replicas: replicas,
+		},
+	}
+
+	client.AddReactor("get", "deployments", func(rawAction core.Action) (bool, runtime.Object, error) {
+		action := rawAction.(core.GetAction)
+		if action.GetName() != current.Name {
+			return true, nil, fmt.Errorf("not found")
+		}
+
+		return true, current.DeepCopy(), nil
+	})
+
+	client.AddReactor("update", "deployments", func(rawAction core.Action) (bool, runtime.Object, error) {
+		action := rawAction.(core.UpdateAction)
+		current = action.GetObject().(*autoscalingv1.Scale).DeepCopy()
+		return true, current, nil
+	})
+
+	return client, current
+}
+
+func TestNewAutoscalingEngine(t *testing.T) {
+	client, _ := buildFakeScaleClient("hollow-node", 1)
+
+	_, err := NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"resource": "deployments",
+		"name":     "hollow-node",
+	}), nil)
+	assert.Error(t, err, "scale client is required")
+
+	_, err = NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"name": "hollow-node",
+	}), client)
+	assert.Error(t, err, "resource is required")
+
+	_, err = NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"resource": "deployments",
+	}), client)
+	assert.Error(t, err, "name is required")
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"group":     "apps",
+		"resource":  "deployments",
+		"namespace": "kubemark",
+		"name":      "hollow-node",
+	}), client)
+	assert.NoError(t, err)
+	assert.Equal(t, "hollow-nodes", engine.Name())
+}
+
+func TestSetTargetNodeCount(t *testing.T) {
+	client, _ := buildFakeScaleClient("hollow-node", 2)
+
+	engine, _ := NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"group":     "apps",
+		"resource":  "deployments",
+		"namespace": "kubemark",
+		"name":      "hollow-node",
+	}), client)
+
+	_, err := engine.SetTargetNodeCount(nil, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	scaled, err := engine.SetTargetNodeCount(nil, 2, "")
+	assert.NoError(t, err)
+	assert.False(t, scaled, "no update if replicas already match")
+
+	scaled, err = engine.SetTargetNodeCount(nil, 5, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+
+	s, _ := client.Scales("kubemark").Get(engine.resource(), "hollow-node")
+	assert.Equal(t, int32(5), s.Spec.Replicas, "replicas updated")
+
+	missing, _ := NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"group":    "apps",
+		"resource": "deployments",
+		"name":     "does-not-exist",
+	}), client)
+
+	_, err = missing.SetTargetNodeCount(nil, 1, "")
+	assert.Error(t, err, "error getting scale of nonexistent object")
+}
@@ -0,0 +1,29 @@
+package webhook
+
+// APIVersion is the version of the webhook payload sent by this engine. It
+// must be bumped on any breaking change to Request or Response.
+const APIVersion = "webhook.cerebral.containership.io/v1"
+
+// Request is the payload POSTed to the webhook when Cerebral requests that an
+// AutoscalingGroup be scaled
+type Request struct {
+	APIVersion string `json:"apiVersion"`
+
+	// AutoscalingGroup is the name of the AutoscalingGroup being scaled
+	AutoscalingGroup string            `json:"autoscalingGroup"`
+	NodeSelector     map[string]string `json:"nodeSelector"`
+	CurrentNodeCount int               `json:"currentNodeCount"`
+	TargetNodeCount  int               `json:"targetNodeCount"`
+	Strategy         string            `json:"strategy"`
+
+	// NodesToRemove contains the names of the nodes Cerebral selected for
+	// removal when scaling down. It is empty when scaling up.
+	NodesToRemove []string `json:"nodesToRemove,omitempty"`
+}
+
+// Response is the payload expected in response to a Request
+type Response struct {
+	// Accepted indicates whether the scale request was accepted
+	Accepted bool   `json:"accepted"`
+	Message  string `json:"message,omitempty"`
+}
@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/nodeutil"
+	"github.com/containership/cerebral/pkg/secretutil"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SignatureHeader is the header containing the hex encoded HMAC-SHA256
+	// signature of the request body, if signing is configured
+	SignatureHeader = "X-Cerebral-Signature"
+
+	// RequestIDHeader is the header containing a unique ID for each scale
+	// request. Retries of a request carry the same ID, so receivers should
+	// use it to avoid applying the same scale request twice.
+	RequestIDHeader = "X-Cerebral-Request-ID"
+
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 3
+
+	defaultHMACSecretKey = "key"
+)
+
+// retryDelay is the time to wait between attempts. It's a var so it can be
+// overridden in tests.
+var retryDelay = 2 * time.Second
+
+// Engine is an autoscaling engine that delegates scaling to an external HTTP
+// service
+type Engine struct {
+	name    string
+	url     string
+	retries int
+	hmacKey []byte
+
+	// timeout bounds the total time spent sending a scale request, including
+	// all retries, since scale requests block the ScaleManager
+	timeout time.Duration
+
+	client *http.Client
+
+	nodeLister corelistersv1.NodeLister
+}
+
+// NewAutoscalingEngine creates a new instance of the webhook autoscaling
+// engine. Any Secrets referenced by the configuration are read once using the
+// given clientset.
+func NewAutoscalingEngine(e cerebralv1alpha1.AutoscalingEngine,
+	kubeclientset kubernetes.Interface,
+	nodeLister corelistersv1.NodeLister) (*Engine, error) {
+	configmap := e.Spec.Configuration
+	engine := &Engine{
+		name:       e.Name,
+		url:        configmap["url"],
+		retries:    defaultRetries,
+		timeout:    defaultTimeout,
+		nodeLister: nodeLister,
+	}
+
+	if engine.url == "" {
+		return nil, errors.New("webhook engine requires url in configuration")
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	if s, ok := configmap["timeoutSeconds"]; ok {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds <= 0 {
+			return nil, errors.Errorf("invalid timeoutSeconds %q", s)
+		}
+
+		engine.timeout = time.Duration(seconds) * time.Second
+	}
+
+	if s, ok := configmap["retries"]; ok {
+		retries, err := strconv.Atoi(s)
+		if err != nil || retries < 0 {
+			return nil, errors.Errorf("invalid retries %q", s)
+		}
+
+		engine.retries = retries
+	}
+
+	if name := configmap["hmacSecretName"]; name != "" {
+		key := configmap["hmacSecretKey"]
+		if key == "" {
+			key = defaultHMACSecretKey
+		}
+
+		secret, err := secretutil.Get(kubeclientset, configmap["hmacSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		engine.hmacKey = secret.Data[key]
+		if len(engine.hmacKey) == 0 {
+			return nil, errors.Errorf("HMAC Secret %s does not contain key %q", name, key)
+		}
+	}
+
+	tlsConfig := &tls.Config{}
+	if name := configmap["tlsSecretName"]; name != "" {
+		secret, err := secretutil.Get(kubeclientset, configmap["tlsSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err = secretutil.TLSConfig(secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "building TLS configuration from Secret %s", name)
+		}
+	}
+
+	engine.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	return engine, nil
+}
+
+// SetTargetNodeCount requests that the webhook scale the nodes selected by
+// the node selector to the given count. The AutoscalingGroup is not known, so
+// it's left empty in the request.
+func (e *Engine) SetTargetNodeCount(nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	return e.SetAutoscalingGroupTargetNodeCount("", nodeSelector, numNodes, strategy)
+}
+
+// SetAutoscalingGroupTargetNodeCount requests that the webhook scale the
+// named AutoscalingGroup, whose nodes are selected by the node selector, to
+// the given count
+func (e *Engine) SetAutoscalingGroupTargetNodeCount(asgName string, nodeSelector map[string]string, numNodes int, strategy string) (bool, error) {
+	if numNodes < 0 {
+		return false, errors.New("cannot scale below 0")
+	}
+
+	nodes, err := e.nodeLister.List(nodeutil.GetNodesLabelSelector(nodeSelector))
+	if err != nil {
+		return false, errors.Wrap(err, "listing nodes")
+	}
+
+	req := Request{
+		APIVersion:       APIVersion,
+		AutoscalingGroup: asgName,
+		NodeSelector:     nodeSelector,
+		CurrentNodeCount: len(nodes),
+		TargetNodeCount:  numNodes,
+		Strategy:         strategy,
+	}
+
+	if numNodes < len(nodes) {
+		toRemove, err := nodeutil.SelectNodesForRemoval(nodes, len(nodes)-numNodes, strategy)
+		if err != nil {
+			return false, errors.Wrap(err, "selecting nodes to remove")
+		}
+
+		for _, node := range toRemove {
+			req.NodesToRemove = append(req.NodesToRemove, node.Name)
+		}
+	}
+
+	log.Infof("AutoscalingEngine %s is requesting webhook to set target nodes of AutoscalingGroup %q (%v) to %d",
+		e.Name(), asgName, nodeSelector, numNodes)
+
+	resp, err := e.send(req)
+	if err != nil {
+		return false, err
+	}
+
+	if !resp.Accepted {
+		log.Infof("AutoscalingEngine %s: webhook did not accept scale request: %s", e.Name(), resp.Message)
+	}
+
+	return resp.Accepted, nil
+}
+
+// Name returns the name of the engine
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// send POSTs the request to the webhook, retrying on connection errors and
+// server errors. Every attempt carries the same request ID. The total time
+// spent, including retries, is bounded by the engine timeout.
+func (e *Engine) send(req Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling webhook request")
+	}
+
+	requestID, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 0; attempt <= e.retries; attempt++ {
+		if attempt > 0 {
+			log.Debugf("AutoscalingEngine %s: retrying webhook request %s (attempt %d): %s", e.Name(), requestID, attempt+1, lastErr)
+
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return nil, errors.Wrapf(lastErr, "webhook request %s timed out after %d attempts", requestID, attempt)
+			}
+		}
+
+		var resp *Response
+		var retryable bool
+		resp, retryable, lastErr = e.post(ctx, requestID, body)
+		if lastErr == nil {
+			return resp, nil
+		}
+
+		if !retryable || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// post performs a single POST to the webhook, returning whether a failure
+// may be retried
+func (e *Engine) post(ctx context.Context, requestID string, body []byte) (*Response, bool, error) {
+	httpReq, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, errors.Wrap(err, "building webhook request")
+	}
+
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(RequestIDHeader, requestID)
+	if len(e.hmacKey) > 0 {
+		httpReq.Header.Set(SignatureHeader, Sign(e.hmacKey, body))
+	}
+
+	httpResp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "sending webhook request")
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "reading webhook response")
+	}
+
+	if httpResp.StatusCode >= http.StatusInternalServerError {
+		return nil, true, errors.Errorf("webhook responded with status %d: %s", httpResp.StatusCode, respBody)
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return nil, false, errors.Errorf("webhook responded with status %d: %s", httpResp.StatusCode, respBody)
+	}
+
+	resp := &Response{}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		return nil, false, errors.Wrap(err, "unmarshaling webhook response")
+	}
+
+	return resp, false, nil
+}
+
+// newRequestID returns a random ID identifying a scale request
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating webhook request ID")
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of the body using the given key, in the format
+// sent in the SignatureHeader
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/secretutil"
+)
+
+var (
+	poolSelector = map[string]string{
+		"pool": "bare-metal",
+	}
+
+	hmacSecret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhook-hmac",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			defaultHMACSecretKey: []byte("super-secret"),
+		},
+	}
+)
+
+func buildNode(name string, created int64) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            poolSelector,
+			CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+		},
+	}
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
+
+func buildEngineCR(configuration map[string]string) cerebralv1alpha1.AutoscalingEngine {
+	return cerebralv1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "webhook",
+		},
+		Spec: cerebralv1alpha1.AutoscalingEngineSpec{
+			Type:          "webhook",
+			Configuration: configuration,
+		},
+	}
+}
+
+func TestNewAutoscalingEngine(t *testing.T) {
+	nodeLister := buildNodeLister(nil)
+	kubeclientset := fake.NewSimpleClientset(&hmacSecret)
+
+	_, err := NewAutoscalingEngine(buildEngineCR(map[string]string{}), kubeclientset, nodeLister)
+	assert.Error(t, err, "url is required")
+
+	config := map[string]string{
+		"url": "http://localhost:8080/scale",
+	}
+
+	_, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nil)
+	assert.Error(t, err, "node lister is required")
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.NoError(t, err)
+	assert.Equal(t, "webhook", engine.Name())
+	assert.Equal(t, defaultRetries, engine.retries, "retries defaulted")
+	assert.Equal(t, defaultTimeout, engine.timeout, "timeout defaulted")
+
+	config["timeoutSeconds"] = "zero"
+	_, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.Error(t, err, "invalid timeout")
+
+	config["timeoutSeconds"] = "5"
+	config["retries"] = "-1"
+	_, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.Error(t, err, "invalid retries")
+
+	config["retries"] = "0"
+	config["hmacSecretName"] = hmacSecret.Name
+	_, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.Error(t, err, "HMAC secret namespace is required")
+
+	config["hmacSecretNamespace"] = hmacSecret.Namespace
+	config["hmacSecretKey"] = "missing"
+	_, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.Error(t, err, "HMAC secret key must exist")
+
+	delete(config, "hmacSecretKey")
+	engine, err = NewAutoscalingEngine(buildEngineCR(config), kubeclientset, nodeLister)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, engine.timeout)
+	assert.Equal(t, 0, engine.retries)
+	assert.Equal(t, hmacSecret.Data[defaultHMACSecretKey], engine.hmacKey)
+}
+
+func TestSetTargetNodeCount(t *testing.T) {
+	var received Request
+	var signature, requestID string
+	accept := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		signature = r.Header.Get(SignatureHeader)
+		requestID = r.Header.Get(RequestIDHeader)
+		assert.Equal(t, Sign(hmacSecret.Data[defaultHMACSecretKey], body), signature, "signature is valid")
+
+		json.NewEncoder(w).Encode(Response{Accepted: accept})
+	}))
+	defer server.Close()
+
+	nodes := []corev1.Node{
+		buildNode("node-0", 100),
+		buildNode("node-1", 200),
+	}
+
+	engine, err := NewAutoscalingEngine(buildEngineCR(map[string]string{
+		"url":                 server.URL,
+		"hmacSecretName":      hmacSecret.Name,
+		"hmacSecretNamespace": hmacSecret.Namespace,
+	}), fake.NewSimpleClientset(&hmacSecret), buildNodeLister(nodes))
+	assert.NoError(t, err)
+
+	_, err = engine.SetAutoscalingGroupTargetNodeCount("bare-metal", poolSelector, -1, "")
+	assert.Error(t, err, "cannot scale below 0")
+
+	scaled, err := engine.SetAutoscalingGroupTargetNodeCount("bare-metal", poolSelector, 3, "")
+	assert.NoError(t, err)
+	assert.True(t, scaled)
+	assert.NotEmpty(t, signature, "request is signed")
+	assert.NotEmpty(t, requestID, "request has an ID")
+	assert.Equal(t, APIVersion, received.APIVersion)
+	assert.Equal(t, "bare-metal", received.AutoscalingGroup, "ASG name passed through")
+	assert.Equal(t, poolSelector, received.NodeSelector)
+	assert.Equal(t, 2, received.CurrentNodeCount)
+	assert.Equal(t, 3, received.TargetNodeCount)
+	assert.Empty(t, received.NodesToRemove, "no nodes to remove on scale up")
+
+	firstRequestID := requestID
+	accept = false
+	scaled, err = engine.SetTargetNodeCount(poolSelector, 1, "oldest")
+	assert.NoError(t, err)
+	assert.False(t, scaled, "webhook did not accept")
+	assert.NotEqual(t, firstRequestID, requestID, "each scale request has a new ID")
+	assert.Empty(t, received.AutoscalingGroup, "ASG name unknown")
+	assert.Equal(t, "oldest", received.Strategy)
+	assert.Equal(t, []string{"node-0"}, received.NodesToRemove, "oldest node selected for removal")
+}
+
+func TestSend(t *testing.T) {
+	retryDelay = 0
+	defer func() { retryDelay = 2 * time.Second }()
+
+	attempts := 0
+	requestIDs := make(map[string]bool)
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		requestIDs[r.Header.Get(RequestIDHeader)] = true
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Response{Accepted: true})
+	}))
+	defer server.Close()
+
+	engine := &Engine{
+		name:    "webhook",
+		url:     server.URL,
+		retries: 2,
+		timeout: defaultTimeout,
+		client:  server.Client(),
+	}
+
+	_, err := engine.send(Request{})
+	assert.Error(t, err, "server errors are returned after retries")
+	assert.Equal(t, 3, attempts, "server errors are retried")
+	assert.Len(t, requestIDs, 1, "retries carry the same request ID")
+
+	attempts = 0
+	status = http.StatusBadRequest
+	_, err = engine.send(Request{})
+	assert.Error(t, err, "client errors are returned")
+	assert.Equal(t, 1, attempts, "client errors are not retried")
+
+	attempts = 0
+	status = http.StatusOK
+	resp, err := engine.send(Request{})
+	assert.NoError(t, err)
+	assert.True(t, resp.Accepted)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestSendTimeout(t *testing.T) {
+	retryDelay = 50 * time.Millisecond
+	defer func() { retryDelay = 2 * time.Second }()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	engine := &Engine{
+		name:    "webhook",
+		url:     server.URL,
+		retries: 100,
+		timeout: 120 * time.Millisecond,
+		client:  server.Client(),
+	}
+
+	start := time.Now()
+	_, err := engine.send(Request{})
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second, "retries are bounded by the timeout")
+	assert.True(t, attempts < 100, "not all retries attempted")
+}
+
+func TestSendTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{Accepted: true})
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	config, err := secretutil.TLSConfig(&corev1.Secret{
+		Data: map[string][]byte{
+			secretutil.CAKey: ca,
+		},
+	})
+	assert.NoError(t, err)
+
+	engine := &Engine{
+		name:    "webhook",
+		url:     server.URL,
+		timeout: defaultTimeout,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: config,
+			},
+		},
+	}
+
+	resp, err := engine.send(Request{})
+	assert.NoError(t, err, "server trusted using CA from Secret")
+	assert.True(t, resp.Accepted)
+}
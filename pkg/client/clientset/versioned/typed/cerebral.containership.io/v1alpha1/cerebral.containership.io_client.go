@@ -31,6 +31,7 @@ type CerebralV1alpha1Interface interface {
 	AutoscalingGroupsGetter
 	AutoscalingPoliciesGetter
 	MetricsBackendsGetter
+	ScheduledScalingActionsGetter
 }
 
 // CerebralV1alpha1Client is used to interact with features provided by the cerebral.containership.io group.
@@ -54,6 +55,10 @@ func (c *CerebralV1alpha1Client) MetricsBackends() MetricsBackendInterface {
 	return newMetricsBackends(c)
 }
 
+func (c *CerebralV1alpha1Client) ScheduledScalingActions() ScheduledScalingActionInterface {
+	return newScheduledScalingActions(c)
+}
+
 // NewForConfig creates a new CerebralV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*CerebralV1alpha1Client, error) {
 	config := *c
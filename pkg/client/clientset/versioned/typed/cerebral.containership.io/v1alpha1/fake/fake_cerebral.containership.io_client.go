@@ -44,6 +44,10 @@ func (c *FakeCerebralV1alpha1) MetricsBackends() v1alpha1.MetricsBackendInterfac
 	return &FakeMetricsBackends{c}
 }
 
+func (c *FakeCerebralV1alpha1) ScheduledScalingActions() v1alpha1.ScheduledScalingActionInterface {
+	return &FakeScheduledScalingActions{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeCerebralV1alpha1) RESTClient() rest.Interface {
@@ -94,6 +94,17 @@ func (c *FakeMetricsBackends) Update(metricsBackend *v1alpha1.MetricsBackend) (r
 	return obj.(*v1alpha1.MetricsBackend), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeMetricsBackends) UpdateStatus(metricsBackend *v1alpha1.MetricsBackend) (*v1alpha1.MetricsBackend, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(metricsbackendsResource, "status", metricsBackend), &v1alpha1.MetricsBackend{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.MetricsBackend), err
+}
+
 // Delete takes name of the metricsBackend and deletes it. Returns an error if one occurs.
 func (c *FakeMetricsBackends) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
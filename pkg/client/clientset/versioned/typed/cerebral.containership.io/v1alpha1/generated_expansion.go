@@ -25,3 +25,5 @@ type AutoscalingGroupExpansion interface{}
 type AutoscalingPolicyExpansion interface{}
 
 type MetricsBackendExpansion interface{}
+
+type ScheduledScalingActionExpansion interface{}
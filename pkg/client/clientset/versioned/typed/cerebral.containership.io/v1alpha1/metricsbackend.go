@@ -37,6 +37,7 @@ type MetricsBackendsGetter interface {
 type MetricsBackendInterface interface {
 	Create(*v1alpha1.MetricsBackend) (*v1alpha1.MetricsBackend, error)
 	Update(*v1alpha1.MetricsBackend) (*v1alpha1.MetricsBackend, error)
+	UpdateStatus(*v1alpha1.MetricsBackend) (*v1alpha1.MetricsBackend, error)
 	Delete(name string, options *v1.DeleteOptions) error
 	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
 	Get(name string, options v1.GetOptions) (*v1alpha1.MetricsBackend, error)
@@ -113,6 +114,21 @@ func (c *metricsBackends) Update(metricsBackend *v1alpha1.MetricsBackend) (resul
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *metricsBackends) UpdateStatus(metricsBackend *v1alpha1.MetricsBackend) (result *v1alpha1.MetricsBackend, err error) {
+	result = &v1alpha1.MetricsBackend{}
+	err = c.client.Put().
+		Resource("metricsbackends").
+		Name(metricsBackend.Name).
+		SubResource("status").
+		Body(metricsBackend).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the metricsBackend and deletes it. Returns an error if one occurs.
 func (c *metricsBackends) Delete(name string, options *v1.DeleteOptions) error {
 	return c.client.Delete().
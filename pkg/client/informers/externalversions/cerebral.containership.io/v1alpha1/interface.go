@@ -32,6 +32,8 @@ type Interface interface {
 	AutoscalingPolicies() AutoscalingPolicyInformer
 	// MetricsBackends returns a MetricsBackendInformer.
 	MetricsBackends() MetricsBackendInformer
+	// ScheduledScalingActions returns a ScheduledScalingActionInformer.
+	ScheduledScalingActions() ScheduledScalingActionInformer
 }
 
 type version struct {
@@ -64,3 +66,8 @@ func (v *version) AutoscalingPolicies() AutoscalingPolicyInformer {
 func (v *version) MetricsBackends() MetricsBackendInformer {
 	return &metricsBackendInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// ScheduledScalingActions returns a ScheduledScalingActionInformer.
+func (v *version) ScheduledScalingActions() ScheduledScalingActionInformer {
+	return &scheduledScalingActionInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
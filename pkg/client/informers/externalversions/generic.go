@@ -61,6 +61,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Cerebral().V1alpha1().AutoscalingPolicies().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("metricsbackends"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Cerebral().V1alpha1().MetricsBackends().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("scheduledscalingactions"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Cerebral().V1alpha1().ScheduledScalingActions().Informer()}, nil
 
 	}
 
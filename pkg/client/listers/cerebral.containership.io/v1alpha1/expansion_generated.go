@@ -33,3 +33,7 @@ type AutoscalingPolicyListerExpansion interface{}
 // MetricsBackendListerExpansion allows custom methods to be added to
 // MetricsBackendLister.
 type MetricsBackendListerExpansion interface{}
+
+// ScheduledScalingActionListerExpansion allows custom methods to be added to
+// ScheduledScalingActionLister.
+type ScheduledScalingActionListerExpansion interface{}
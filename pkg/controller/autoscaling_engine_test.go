@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+func TestInstantiateEngine(t *testing.T) {
+	c := AutoscalingEngineController{}
+
+	_, err := c.instantiateEngine(&v1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "unknown",
+		},
+		Spec: v1alpha1.AutoscalingEngineSpec{
+			Type: "not-a-real-engine",
+		},
+	})
+	assert.Error(t, err, "unknown engine type errors")
+
+	_, err = c.instantiateEngine(&v1alpha1.AutoscalingEngine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "containership",
+		},
+		Spec: v1alpha1.AutoscalingEngineSpec{
+			Type: "containership",
+		},
+	})
+	assert.Error(t, err, "containership engine without configuration errors")
+}
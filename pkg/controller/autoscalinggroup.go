@@ -51,6 +51,9 @@ type AutoscalingGroupController struct {
 	agLister clisters.AutoscalingGroupLister
 	agSynced cache.InformerSynced
 
+	ssaLister clisters.ScheduledScalingActionLister
+	ssaSynced cache.InformerSynced
+
 	workqueue workqueue.RateLimitingInterface
 
 	scaleRequestCh chan<- ScaleRequest
@@ -74,6 +77,7 @@ func NewAutoscalingGroupController(kubeclientset kubernetes.Interface,
 
 	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
 	agInformer := cInformerFactory.Cerebral().V1alpha1().AutoscalingGroups()
+	ssaInformer := cInformerFactory.Cerebral().V1alpha1().ScheduledScalingActions()
 
 	log.Info("Setting up event handlers")
 
@@ -97,12 +101,35 @@ func NewAutoscalingGroupController(kubeclientset kubernetes.Interface,
 		},
 	})
 
+	// Scheduled scaling actions may override the bounds of an autoscaling
+	// group, so changes to them must be reconciled
+	ssaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: agc.enqueueAGForScheduledScalingAction,
+		UpdateFunc: func(old, new interface{}) {
+			newSSA := new.(*cerebralv1alpha1.ScheduledScalingAction)
+			oldSSA := old.(*cerebralv1alpha1.ScheduledScalingAction)
+			if newSSA.ResourceVersion == oldSSA.ResourceVersion ||
+				newSSA.Generation == oldSSA.Generation {
+				return
+			}
+
+			if oldSSA.Spec.AutoscalingGroup != newSSA.Spec.AutoscalingGroup {
+				agc.enqueueAGForScheduledScalingAction(old)
+			}
+			agc.enqueueAGForScheduledScalingAction(new)
+		},
+		DeleteFunc: agc.enqueueAGForScheduledScalingAction,
+	})
+
 	agc.nodeLister = nodeInformer.Lister()
 	agc.nodesSynced = nodeInformer.Informer().HasSynced
 
 	agc.agLister = agInformer.Lister()
 	agc.agSynced = agInformer.Informer().HasSynced
 
+	agc.ssaLister = ssaInformer.Lister()
+	agc.ssaSynced = ssaInformer.Informer().HasSynced
+
 	return agc
 }
 
@@ -119,7 +146,8 @@ func (agc *AutoscalingGroupController) Run(numWorkers int, stopCh <-chan struct{
 
 	if ok := cache.WaitForCacheSync(stopCh,
 		agc.nodesSynced,
-		agc.agSynced); !ok {
+		agc.agSynced,
+		agc.ssaSynced); !ok {
 		// If this channel is unable to wait for caches to sync we return an error
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
@@ -222,6 +250,49 @@ func (agc *AutoscalingGroupController) enqueueAGForNode(obj interface{}) {
 	}
 }
 
+// enqueueAGForScheduledScalingAction enqueues the AG referenced by the
+// enqueued scheduled scaling action
+func (agc *AutoscalingGroupController) enqueueAGForScheduledScalingAction(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	action, ok := obj.(*cerebralv1alpha1.ScheduledScalingAction)
+	if !ok {
+		log.Errorf("%s: expected ScheduledScalingAction but got %#v", controllerName, obj)
+		return
+	}
+
+	ag, err := agc.agLister.Get(action.Spec.AutoscalingGroup)
+	if err != nil {
+		if !kubeerrors.IsNotFound(err) {
+			log.Errorf("%s: getting autoscaling group %q for scheduled scaling action %q: %s",
+				controllerName, action.Spec.AutoscalingGroup, action.Name, err)
+		}
+		return
+	}
+
+	agc.enqueueAutoscalingGroup(ag)
+}
+
+// enqueueAtNextBoundsChange enqueues the AG again when a bounds override of
+// a scheduled scaling action for it next starts or ends, so that the node
+// count is reconciled with the new bounds
+func (agc *AutoscalingGroupController) enqueueAtNextBoundsChange(key string, ag *cerebralv1alpha1.AutoscalingGroup) error {
+	actions, err := agc.ssaLister.List(labels.NewSelector())
+	if err != nil {
+		return errors.Wrap(err, "listing ScheduledScalingActions")
+	}
+
+	now := nowFunc()
+	if next, ok := nextBoundsChange(ag.Name, actions, now); ok {
+		log.Debugf("%s: %q will be enqueued again at %s when its bounds change", controllerName, key, next)
+		agc.workqueue.AddAfter(key, next.Sub(now))
+	}
+
+	return nil
+}
+
 // enqueueAutoscalingGroup enqueues an autoscalinggroup object.
 func (agc *AutoscalingGroupController) enqueueAutoscalingGroup(obj interface{}) {
 	var key string
@@ -258,6 +329,10 @@ func (agc *AutoscalingGroupController) syncHandler(key string) error {
 		return nil
 	}
 
+	if err := agc.enqueueAtNextBoundsChange(key, autoscalingGroup); err != nil {
+		return errors.Wrapf(err, "scheduling bounds change for AutoscalingGroup %s", autoscalingGroup.Name)
+	}
+
 	ns := nodeutil.GetNodesLabelSelector(autoscalingGroup.Spec.NodeSelector)
 	// get nodes associated with autoscaling group using the node selector
 	nodes, err := agc.nodeLister.List(ns)
@@ -268,7 +343,13 @@ func (agc *AutoscalingGroupController) syncHandler(key string) error {
 	numNodes := len(nodes)
 	log.Infof("Current number of nodes in autoscaling group '%s' : %d", autoscalingGroup.Name, numNodes)
 
-	delta, dir := determineScaleDeltaAndDirection(numNodes, autoscalingGroup.Spec.MinNodes, autoscalingGroup.Spec.MaxNodes)
+	// Scheduled scaling actions may temporarily override the bounds
+	minNodes, maxNodes, err := autoscalingGroupBounds(autoscalingGroup, agc.ssaLister)
+	if err != nil {
+		return errors.Wrapf(err, "getting bounds for AutoscalingGroup %s", autoscalingGroup.Name)
+	}
+
+	delta, dir := determineScaleDeltaAndDirection(numNodes, minNodes, maxNodes)
 	if delta == 0 {
 		log.Debugf("%s: AutoscalingGroup %s is within bounds - ignoring", controllerName, autoscalingGroup.Name)
 		return nil
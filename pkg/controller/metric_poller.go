@@ -1,21 +1,36 @@
 package controller
 
 import (
+	"math"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
 	"github.com/containership/cluster-manager/pkg/log"
 
 	"github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
 	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
 	"github.com/containership/cerebral/pkg/operator"
 )
 
+// The default ratio by which a target tracking metric value may differ from
+// the target before scaling. This is the same default the HPA uses.
+const defaultTargetTrackingTolerance = 0.1
+
+// Operators for combining the threshold and conditions of a policy
+const (
+	conditionOperatorAnd = "and"
+	conditionOperatorOr  = "or"
+)
+
 type metricPoller struct {
 	asp          *v1alpha1.AutoscalingPolicy
 	nodeSelector map[string]string
+	nodeLister   corelistersv1.NodeLister
 }
 
 type alertState struct {
@@ -34,10 +49,15 @@ func (a *alertState) shouldFire(samplePeriod time.Duration) bool {
 	return a.active && nowFunc().Sub(a.startTime) >= samplePeriod
 }
 
-func newMetricPoller(asp *v1alpha1.AutoscalingPolicy, nodeSelector map[string]string) metricPoller {
+func newMetricPoller(asp *v1alpha1.AutoscalingPolicy, nodeSelector map[string]string,
+	nodeLister corelistersv1.NodeLister) metricPoller {
+	warnIgnoredStepAdjustments(asp.ObjectMeta.Name, "scaleUp", asp.Spec.ScalingPolicy.ScaleUp)
+	warnIgnoredStepAdjustments(asp.ObjectMeta.Name, "scaleDown", asp.Spec.ScalingPolicy.ScaleDown)
+
 	return metricPoller{
 		asp:          asp,
 		nodeSelector: nodeSelector,
+		nodeLister:   nodeLister,
 	}
 }
 
@@ -53,6 +73,7 @@ func (p metricPoller) run(wg *sync.WaitGroup, alertCh chan<- alert, stopCh <-cha
 
 	upAlert := &alertState{}
 	downAlert := &alertState{}
+	targetAlert := &alertState{}
 
 	ticker := time.NewTicker(pollInterval)
 	for {
@@ -74,16 +95,38 @@ func (p metricPoller) run(wg *sync.WaitGroup, alertCh chan<- alert, stopCh <-cha
 
 			log.Debugf("Poller for ASP %q got value %f", policyName, val)
 
+			// Target tracking alerts replace scale up and down alerts
+			if targetConfig := p.asp.Spec.ScalingPolicy.TargetTracking; targetConfig != nil {
+				if err := p.trackTarget(alertCh, targetConfig, targetAlert, samplePeriod, val); err != nil {
+					alertCh <- alert{err: err}
+					return
+				}
+
+				continue
+			}
+
 			// Scale up alerts
 			upConfig := p.asp.Spec.ScalingPolicy.ScaleUp
-			if policyConfigurationShouldFireAlert(upConfig, upAlert, samplePeriod, val) {
-				p.fireAlert(alertCh, upConfig, scaleDirectionUp)
+			upConditionValues, err := p.getConditionValues(upConfig)
+			if err != nil {
+				alertCh <- alert{err: err}
+				return
+			}
+
+			if policyConfigurationShouldFireAlert(upConfig, upAlert, samplePeriod, val, upConditionValues) {
+				p.fireAlert(alertCh, upConfig, scaleDirectionUp, val)
 			}
 
 			// Scale down alerts
 			downConfig := p.asp.Spec.ScalingPolicy.ScaleDown
-			if policyConfigurationShouldFireAlert(downConfig, downAlert, samplePeriod, val) {
-				p.fireAlert(alertCh, downConfig, scaleDirectionDown)
+			downConditionValues, err := p.getConditionValues(downConfig)
+			if err != nil {
+				alertCh <- alert{err: err}
+				return
+			}
+
+			if policyConfigurationShouldFireAlert(downConfig, downAlert, samplePeriod, val, downConditionValues) {
+				p.fireAlert(alertCh, downConfig, scaleDirectionDown, val)
 			}
 
 		case <-stopCh:
@@ -93,27 +136,224 @@ func (p metricPoller) run(wg *sync.WaitGroup, alertCh chan<- alert, stopCh <-cha
 	}
 }
 
-func (p *metricPoller) fireAlert(alertCh chan<- alert, policy *v1alpha1.ScalingPolicyConfiguration, dir scaleDirection) {
+func (p *metricPoller) fireAlert(alertCh chan<- alert, policy *v1alpha1.ScalingPolicyConfiguration, dir scaleDirection, val float64) {
+	step := stepAdjustmentForValue(policy, val)
+
 	// Thanks to CRD validation, we can assume that this is valid
-	adjustmentType, _ := adjustmentTypeFromString(policy.AdjustmentType)
+	adjustmentType, _ := adjustmentTypeFromString(step.AdjustmentType)
 	alertCh <- alert{
 		aspName:         p.asp.ObjectMeta.Name,
 		direction:       dir,
 		adjustmentType:  adjustmentType,
-		adjustmentValue: policy.AdjustmentValue,
+		adjustmentValue: step.AdjustmentValue,
+	}
+}
+
+// stepAdjustmentForValue returns the adjustment to make for the given value.
+// Of the policy's own threshold and its step adjustment thresholds, the
+// breached threshold furthest from the policy's threshold wins, so that
+// larger breaches result in larger adjustments.
+func stepAdjustmentForValue(policy *v1alpha1.ScalingPolicyConfiguration, val float64) v1alpha1.StepAdjustment {
+	result := v1alpha1.StepAdjustment{
+		Threshold:       policy.Threshold,
+		AdjustmentType:  policy.AdjustmentType,
+		AdjustmentValue: policy.AdjustmentValue,
+	}
+
+	// Assume the operator is correct thanks to OpenAPI validation on the CR
+	op, _ := operator.FromString(policy.ComparisonOperator)
+
+	var furthest float64
+	for _, step := range policy.StepAdjustments {
+		if !stepBeyondThreshold(op, policy.Threshold, step) || !op.Evaluate(val, step.Threshold) {
+			continue
+		}
+
+		if distance := math.Abs(step.Threshold - policy.Threshold); distance > furthest {
+			furthest = distance
+			result = step
+		}
+	}
+
+	return result
+}
+
+// stepBeyondThreshold returns true if the step's threshold is beyond the
+// policy threshold in the direction of the operator, e.g. above it for ">".
+// Other steps could be breached when the policy threshold is not and so are
+// ignored.
+func stepBeyondThreshold(op operator.ComparisonOperator, threshold float64, step v1alpha1.StepAdjustment) bool {
+	return step.Threshold != threshold && op.Evaluate(step.Threshold, threshold)
+}
+
+// warnIgnoredStepAdjustments logs the step adjustments of the policy that
+// will be ignored because they are not beyond its threshold
+func warnIgnoredStepAdjustments(policyName string, direction string, policy *v1alpha1.ScalingPolicyConfiguration) {
+	if policy == nil {
+		return
+	}
+
+	op, err := operator.FromString(policy.ComparisonOperator)
+	if err != nil {
+		return
+	}
+
+	for _, step := range policy.StepAdjustments {
+		if !stepBeyondThreshold(op, policy.Threshold, step) {
+			log.Errorf("Policy %q: ignoring %s step adjustment with threshold %v, which is not beyond threshold %v using operator %s",
+				policyName, direction, step.Threshold, policy.Threshold, policy.ComparisonOperator)
+		}
+	}
+}
+
+// trackTarget fires an alert requesting the target node count for the value
+// if the node count has differed from it for the entire sample period
+func (p metricPoller) trackTarget(alertCh chan<- alert, config *v1alpha1.TargetTrackingConfiguration,
+	state *alertState, samplePeriod time.Duration, val float64) error {
+	nodes, err := p.nodeLister.List(nodeutil.GetNodesLabelSelector(p.nodeSelector))
+	if err != nil {
+		return errors.Wrapf(err, "listing nodes for policy %q", p.asp.ObjectMeta.Name)
+	}
+
+	currNodeCount := len(nodes)
+	targetNodeCount, err := targetTrackingNodeCount(config, currNodeCount, val)
+	if err != nil {
+		return errors.Wrapf(err, "tracking target for policy %q", p.asp.ObjectMeta.Name)
+	}
+
+	if targetNodeCount == currNodeCount {
+		// We're on target, so any active alert is resolved
+		state.active = false
+		return nil
+	}
+
+	if !state.active {
+		state.start()
+		return nil
 	}
+
+	if !state.shouldFire(samplePeriod) {
+		return nil
+	}
+
+	state.active = false
+
+	dir := scaleDirectionUp
+	if targetNodeCount < currNodeCount {
+		dir = scaleDirectionDown
+	}
+
+	alertCh <- alert{
+		aspName:         p.asp.ObjectMeta.Name,
+		direction:       dir,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: float64(targetNodeCount),
+	}
+
+	return nil
+}
+
+// targetTrackingNodeCount returns the node count required to bring the value
+// to the configured target, assuming the value is inversely proportional to
+// the node count. The current node count is returned if the value is within
+// the tolerance of the target. A group with no nodes is treated as having a
+// single node when scaling up so that it can scale up from zero.
+func targetTrackingNodeCount(config *v1alpha1.TargetTrackingConfiguration, curr int, val float64) (int, error) {
+	if config.TargetValue <= 0 {
+		return 0, errors.Errorf("target value %f must be positive", config.TargetValue)
+	}
+
+	// Backends such as Prometheus may return NaN or Inf (e.g. for 0/0), which
+	// would otherwise result in an arbitrary node count
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, errors.Errorf("metric value %f is not a finite number", val)
+	}
+
+	tolerance := defaultTargetTrackingTolerance
+	if config.Tolerance != nil {
+		tolerance = *config.Tolerance
+	}
+
+	if tolerance < 0 {
+		return 0, errors.Errorf("tolerance %f must not be negative", tolerance)
+	}
+
+	ratio := val / config.TargetValue
+	if math.Abs(ratio-1) <= tolerance {
+		return curr, nil
+	}
+
+	if curr == 0 && ratio > 1 {
+		curr = 1
+	}
+
+	return int(math.Ceil(float64(curr) * ratio)), nil
+}
+
+// getConditionValues returns the current value of each condition of the
+// policy, in order
+func (p metricPoller) getConditionValues(policy *v1alpha1.ScalingPolicyConfiguration) ([]float64, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	policyName := p.asp.ObjectMeta.Name
+
+	var values []float64
+	for _, condition := range policy.Conditions {
+		backendName := condition.MetricsBackend
+		if backendName == "" {
+			backendName = p.asp.Spec.MetricsBackend
+		}
+
+		backend, err := metrics.Registry().Get(backendName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "metrics backend %q specified by condition of policy %q is unavailable", backendName, policyName)
+		}
+
+		val, err := backend.GetValue(condition.Metric, condition.MetricConfiguration, p.nodeSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting metric %q for condition of policy %q", condition.Metric, policyName)
+		}
+
+		log.Debugf("Poller for ASP %q got value %f for condition metric %q", policyName, val, condition.Metric)
+
+		values = append(values, val)
+	}
+
+	return values, nil
+}
+
+// policyConfigurationConditionsMet returns true if the policy's threshold and
+// conditions, combined using its condition operator, are met. The condition
+// values must be in the same order as the conditions.
+func policyConfigurationConditionsMet(policy *v1alpha1.ScalingPolicyConfiguration, val float64, conditionValues []float64) bool {
+	// Assume the operators are correct thanks to OpenAPI validation on the CR
+	op, _ := operator.FromString(policy.ComparisonOperator)
+	met := op.Evaluate(val, policy.Threshold)
+
+	for i, condition := range policy.Conditions {
+		op, _ := operator.FromString(condition.ComparisonOperator)
+		conditionMet := op.Evaluate(conditionValues[i], condition.Threshold)
+
+		if policy.ConditionOperator == conditionOperatorOr {
+			met = met || conditionMet
+		} else {
+			met = met && conditionMet
+		}
+	}
+
+	return met
 }
 
 func policyConfigurationShouldFireAlert(policy *v1alpha1.ScalingPolicyConfiguration,
-	alert *alertState, samplePeriod time.Duration, val float64) bool {
+	alert *alertState, samplePeriod time.Duration, val float64, conditionValues []float64) bool {
 	if policy == nil {
 		// Nothing to do
 		return false
 	}
 
-	// Assume the operator is correct thanks to OpenAPI validation on the CR
-	op, _ := operator.FromString(policy.ComparisonOperator)
-	if !op.Evaluate(val, policy.Threshold) {
+	if !policyConfigurationConditionsMet(policy, val, conditionValues) {
 		// We're not alerting, so nothing to do
 		return false
 	}
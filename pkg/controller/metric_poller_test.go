@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -20,12 +21,12 @@ func resetTime() {
 }
 
 func TestNewMetricPoller(t *testing.T) {
-	p := newMetricPoller(&v1alpha1.AutoscalingPolicy{}, map[string]string{})
+	p := newMetricPoller(&v1alpha1.AutoscalingPolicy{}, map[string]string{}, nil)
 	assert.NotNil(t, p, "never nil")
 }
 
 func TestPolicyConfigurationShouldFireAlert(t *testing.T) {
-	fired := policyConfigurationShouldFireAlert(nil, &alertState{}, time.Second, 0)
+	fired := policyConfigurationShouldFireAlert(nil, &alertState{}, time.Second, 0, nil)
 	assert.False(t, fired, "nil config is a noop")
 
 	alert := &alertState{active: false}
@@ -34,27 +35,27 @@ func TestPolicyConfigurationShouldFireAlert(t *testing.T) {
 		ComparisonOperator: ">=",
 	}
 
-	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 10)
+	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 10, nil)
 	assert.False(t, fired, "have not breached threshold")
 
 	alert = &alertState{active: true, startTime: time.Unix(0, 0)}
 	setTime(2)
-	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80)
+	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80, nil)
 	assert.False(t, fired, "breached threshold but not long enough")
 
 	alert = &alertState{active: false}
 	setTime(2)
-	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80)
+	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80, nil)
 	assert.False(t, fired, "breached threshold but not active")
 
 	alert = &alertState{active: true, startTime: time.Unix(0, 0)}
 	setTime(10)
-	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80)
+	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 80, nil)
 	assert.True(t, fired, "breached threshold for long enough")
 
 	alert = &alertState{active: false, startTime: time.Unix(0, 0)}
 	setTime(2)
-	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 10)
+	fired = policyConfigurationShouldFireAlert(gteConfig, alert, 5*time.Second, 10, nil)
 	assert.False(t, fired, "breached threshold but not long enough")
 
 	resetTime()
@@ -82,3 +83,167 @@ func TestAlertShouldFire(t *testing.T) {
 
 	resetTime()
 }
+
+func TestStepAdjustmentForValue(t *testing.T) {
+	upConfig := &v1alpha1.ScalingPolicyConfiguration{
+		Threshold:          70,
+		ComparisonOperator: ">",
+		AdjustmentType:     "absolute",
+		AdjustmentValue:    1,
+	}
+
+	step := stepAdjustmentForValue(upConfig, 90)
+	assert.Equal(t, float64(1), step.AdjustmentValue, "no steps uses policy adjustment")
+
+	upConfig.StepAdjustments = []v1alpha1.StepAdjustment{
+		{
+			Threshold:       95,
+			AdjustmentType:  "percent",
+			AdjustmentValue: 25,
+		},
+		{
+			Threshold:       85,
+			AdjustmentType:  "absolute",
+			AdjustmentValue: 3,
+		},
+	}
+
+	step = stepAdjustmentForValue(upConfig, 75)
+	assert.Equal(t, "absolute", step.AdjustmentType)
+	assert.Equal(t, float64(1), step.AdjustmentValue, "only policy threshold breached")
+
+	step = stepAdjustmentForValue(upConfig, 90)
+	assert.Equal(t, "absolute", step.AdjustmentType)
+	assert.Equal(t, float64(3), step.AdjustmentValue, "second step breached")
+
+	step = stepAdjustmentForValue(upConfig, 99)
+	assert.Equal(t, "percent", step.AdjustmentType)
+	assert.Equal(t, float64(25), step.AdjustmentValue, "furthest step breached regardless of order")
+
+	step = stepAdjustmentForValue(upConfig, 95)
+	assert.Equal(t, float64(3), step.AdjustmentValue, "operator is respected for steps")
+
+	downConfig := &v1alpha1.ScalingPolicyConfiguration{
+		Threshold:          30,
+		ComparisonOperator: "<=",
+		AdjustmentType:     "absolute",
+		AdjustmentValue:    1,
+		StepAdjustments: []v1alpha1.StepAdjustment{
+			{
+				Threshold:       10,
+				AdjustmentType:  "absolute",
+				AdjustmentValue: 2,
+			},
+		},
+	}
+
+	step = stepAdjustmentForValue(downConfig, 20)
+	assert.Equal(t, float64(1), step.AdjustmentValue, "only policy threshold breached")
+
+	step = stepAdjustmentForValue(downConfig, 10)
+	assert.Equal(t, float64(2), step.AdjustmentValue, "step breached below policy threshold")
+
+	downConfig.StepAdjustments = append(downConfig.StepAdjustments, v1alpha1.StepAdjustment{
+		Threshold:       50,
+		AdjustmentType:  "absolute",
+		AdjustmentValue: 5,
+	})
+
+	step = stepAdjustmentForValue(downConfig, 20)
+	assert.Equal(t, float64(1), step.AdjustmentValue, "step on the wrong side of policy threshold ignored")
+
+	step = stepAdjustmentForValue(downConfig, 40)
+	assert.Equal(t, float64(1), step.AdjustmentValue, "step on the wrong side of policy threshold ignored when policy threshold not breached")
+}
+
+func TestTargetTrackingNodeCount(t *testing.T) {
+	config := &v1alpha1.TargetTrackingConfiguration{
+		TargetValue: 60,
+	}
+
+	count := func(curr int, val float64) int {
+		result, err := targetTrackingNodeCount(config, curr, val)
+		assert.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, 4, count(4, 60), "on target")
+	assert.Equal(t, 4, count(4, 65), "within default tolerance above target")
+	assert.Equal(t, 4, count(4, 55), "within default tolerance below target")
+	assert.Equal(t, 6, count(4, 90), "scales up proportionally")
+	assert.Equal(t, 2, count(4, 30), "scales down proportionally")
+	assert.Equal(t, 5, count(4, 70), "takes ceiling")
+	assert.Equal(t, 1, count(4, 1), "takes ceiling when scaling down")
+	assert.Equal(t, 2, count(0, 90), "scales up from zero nodes")
+	assert.Equal(t, 0, count(0, 30), "stays at zero nodes below target")
+
+	_, err := targetTrackingNodeCount(config, 4, math.NaN())
+	assert.Error(t, err, "NaN value is rejected")
+
+	_, err = targetTrackingNodeCount(config, 4, math.Inf(1))
+	assert.Error(t, err, "+Inf value is rejected")
+
+	_, err = targetTrackingNodeCount(config, 4, math.Inf(-1))
+	assert.Error(t, err, "-Inf value is rejected")
+
+	tolerance := 0.5
+	config.Tolerance = &tolerance
+	assert.Equal(t, 4, count(4, 85), "within configured tolerance")
+	assert.Equal(t, 7, count(4, 95), "outside configured tolerance")
+
+	tolerance = 0
+	assert.Equal(t, 4, count(4, 60), "on target with zero tolerance")
+	assert.Equal(t, 5, count(4, 61), "zero tolerance scales on any difference")
+
+	tolerance = -0.1
+	_, err = targetTrackingNodeCount(config, 4, 60)
+	assert.Error(t, err, "negative tolerance is rejected")
+
+	config.TargetValue = 0
+	_, err = targetTrackingNodeCount(config, 4, 60)
+	assert.Error(t, err, "non-positive target value is rejected")
+}
+
+func TestPolicyConfigurationConditionsMet(t *testing.T) {
+	config := &v1alpha1.ScalingPolicyConfiguration{
+		Threshold:          30,
+		ComparisonOperator: "<",
+	}
+
+	assert.True(t, policyConfigurationConditionsMet(config, 20, nil), "no conditions uses threshold")
+	assert.False(t, policyConfigurationConditionsMet(config, 40, nil), "no conditions uses threshold")
+
+	config.Conditions = []v1alpha1.MetricCondition{
+		{
+			Metric:             "memory_percent_utilization",
+			Threshold:          40,
+			ComparisonOperator: "<",
+		},
+		{
+			Metric:             "pods_per_node",
+			Threshold:          50,
+			ComparisonOperator: "<=",
+		},
+	}
+
+	assert.True(t, policyConfigurationConditionsMet(config, 20, []float64{30, 50}), "and defaults: all met")
+	assert.False(t, policyConfigurationConditionsMet(config, 20, []float64{60, 50}), "and defaults: condition not met")
+	assert.False(t, policyConfigurationConditionsMet(config, 40, []float64{30, 50}), "and defaults: threshold not met")
+
+	config.ConditionOperator = "and"
+	assert.False(t, policyConfigurationConditionsMet(config, 20, []float64{30, 60}), "and: last condition not met")
+
+	config.ConditionOperator = "or"
+	assert.True(t, policyConfigurationConditionsMet(config, 40, []float64{60, 50}), "or: one condition met")
+	assert.True(t, policyConfigurationConditionsMet(config, 20, []float64{60, 60}), "or: threshold met")
+	assert.False(t, policyConfigurationConditionsMet(config, 40, []float64{60, 60}), "or: none met")
+
+	alert := &alertState{active: true, startTime: time.Unix(0, 0)}
+	setTime(10)
+	defer resetTime()
+	fired := policyConfigurationShouldFireAlert(config, alert, 5*time.Second, 40, []float64{60, 60})
+	assert.False(t, fired, "conditions not met for long enough")
+
+	fired = policyConfigurationShouldFireAlert(config, alert, 5*time.Second, 40, []float64{30, 60})
+	assert.True(t, fired, "conditions met for long enough")
+}
@@ -17,6 +17,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -52,6 +53,9 @@ type MetricsController struct {
 	aspLister clisters.AutoscalingPolicyLister
 	aspSynced cache.InformerSynced
 
+	nodeLister corelistersv1.NodeLister
+	nodeSynced cache.InformerSynced
+
 	workqueue workqueue.RateLimitingInterface
 
 	recorder record.EventRecorder
@@ -88,6 +92,7 @@ func NewMetrics(kubeclientset kubernetes.Interface,
 
 	asgInformer := cInformerFactory.Cerebral().V1alpha1().AutoscalingGroups()
 	aspInformer := cInformerFactory.Cerebral().V1alpha1().AutoscalingPolicies()
+	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
 
 	log.Infof("%s: setting up event handlers", metricsControllerName)
 
@@ -126,6 +131,9 @@ func NewMetrics(kubeclientset kubernetes.Interface,
 	c.aspLister = aspInformer.Lister()
 	c.aspSynced = aspInformer.Informer().HasSynced
 
+	c.nodeLister = nodeInformer.Lister()
+	c.nodeSynced = nodeInformer.Informer().HasSynced
+
 	return c
 }
 
@@ -140,7 +148,7 @@ func (c *MetricsController) Run(numWorkers int, stopCh <-chan struct{}) error {
 	// Start the informer factories to begin populating the informer caches
 	log.Infof("Starting %s", metricsControllerName)
 
-	if ok := cache.WaitForCacheSync(stopCh, c.asgSynced, c.aspSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.asgSynced, c.aspSynced, c.nodeSynced); !ok {
 		return errors.Errorf("%s: failed to wait for caches to sync", metricsControllerName)
 	}
 
@@ -307,7 +315,8 @@ func (c *MetricsController) syncHandler(key string) error {
 	}
 
 	stopCh := make(chan struct{})
-	c.pollManagers[asgName] = newPollManager(asgName, asps, asg.Spec.NodeSelector, c.recorder, c.scaleRequestCh, stopCh)
+	c.pollManagers[asgName] = newPollManager(asgName, asps, asg.Spec.NodeSelector, c.nodeLister,
+		conflictResolutionForASG(asg), c.recorder, c.scaleRequestCh, stopCh)
 
 	go func() {
 		log.Infof("Starting poll manager for AutoscalingGroup %q", asgName)
@@ -2,9 +2,13 @@ package controller
 
 import (
 	"fmt"
+	"io"
+	"reflect"
 	"time"
 
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 
@@ -13,6 +17,9 @@ import (
 	corelistersv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+	custommetricsclient "k8s.io/metrics/pkg/client/custom_metrics"
+	externalmetricsclient "k8s.io/metrics/pkg/client/external_metrics"
 
 	"github.com/containership/cluster-manager/pkg/log"
 
@@ -22,6 +29,13 @@ import (
 	clisters "github.com/containership/cerebral/pkg/client/listers/cerebral.containership.io/v1alpha1"
 
 	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/metrics/backends/custommetrics"
+	"github.com/containership/cerebral/pkg/metrics/backends/graphite"
+	"github.com/containership/cerebral/pkg/metrics/backends/httpjson"
+	"github.com/containership/cerebral/pkg/metrics/backends/influxdb"
+	kubernetesbackend "github.com/containership/cerebral/pkg/metrics/backends/kubernetes"
+	"github.com/containership/cerebral/pkg/metrics/backends/metricsserver"
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin"
 	"github.com/containership/cerebral/pkg/metrics/backends/prometheus"
 
 	"github.com/pkg/errors"
@@ -35,6 +49,10 @@ const (
 
 	// number of times a MetricsBackend will retry syncing
 	metricsBackendMaxRequeues = 10
+
+	// how often the status of MetricsBackends is refreshed from their
+	// instantiated backends
+	metricsBackendStatusInterval = time.Minute
 )
 
 // MetricsBackendController reconciles MetricsBackends with a local registry of
@@ -42,6 +60,10 @@ const (
 type MetricsBackendController struct {
 	kubeclientset     kubernetes.Interface
 	cerebralclientset cerebral.Interface
+	metricsclientset  metricsclientset.Interface
+
+	customMetricsClient   custommetricsclient.CustomMetricsClient
+	externalMetricsClient externalmetricsclient.ExternalMetricsClient
 
 	metricsBackendLister clisters.MetricsBackendLister
 	metricsBackendSynced cache.InformerSynced
@@ -62,13 +84,20 @@ type MetricsBackendController struct {
 func NewMetricsBackend(kubeclientset kubernetes.Interface,
 	kubeInformerFactory kubeinformers.SharedInformerFactory,
 	cerebralclientset cerebral.Interface,
-	cInformerFactory cinformers.SharedInformerFactory) *MetricsBackendController {
+	cInformerFactory cinformers.SharedInformerFactory,
+	metricsclientset metricsclientset.Interface,
+	customMetricsClient custommetricsclient.CustomMetricsClient,
+	externalMetricsClient externalmetricsclient.ExternalMetricsClient) *MetricsBackendController {
 	rateLimiter := workqueue.NewItemExponentialFailureRateLimiter(metricsBackendDelayBetweenRequeues, metricsBackendMaxRequeues)
 
 	c := &MetricsBackendController{
 		kubeclientset:     kubeclientset,
 		cerebralclientset: cerebralclientset,
+		metricsclientset:  metricsclientset,
 		workqueue:         workqueue.NewNamedRateLimitingQueue(rateLimiter, metricsBackendControllerName),
+
+		customMetricsClient:   customMetricsClient,
+		externalMetricsClient: externalMetricsClient,
 	}
 
 	metricsBackendInformer := cInformerFactory.Cerebral().V1alpha1().MetricsBackends()
@@ -81,10 +110,12 @@ func NewMetricsBackend(kubeclientset kubernetes.Interface,
 	metricsBackendInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: c.enqueueMetricsBackend,
 		UpdateFunc: func(old, new interface{}) {
-			// We want to ignore periodic resyncs
+			// We want to ignore periodic resyncs as well as status updates,
+			// which don't change the generation
 			newBackend := new.(*cerebralv1alpha1.MetricsBackend)
 			oldBackend := old.(*cerebralv1alpha1.MetricsBackend)
-			if newBackend.ResourceVersion == oldBackend.ResourceVersion {
+			if newBackend.ResourceVersion == oldBackend.ResourceVersion ||
+				newBackend.Generation == oldBackend.Generation {
 				return
 			}
 
@@ -128,6 +159,8 @@ func (c *MetricsBackendController) Run(numWorkers int, stopCh <-chan struct{}) e
 		go wait.Until(c.runWorker, time.Second, stopCh)
 	}
 
+	go wait.Until(c.updateStatuses, metricsBackendStatusInterval, stopCh)
+
 	log.Infof("%s: started workers", metricsBackendControllerName)
 	<-stopCh
 	log.Infof("%s: shutting down workers", metricsBackendControllerName)
@@ -220,7 +253,7 @@ func (c *MetricsBackendController) syncHandler(key string) error {
 	if err != nil {
 		if kubeerrors.IsNotFound(err) {
 			// Must've been deleted, so let's delete it from the registry
-			metrics.Registry().Delete(name)
+			deleteBackend(name)
 			return nil
 		}
 
@@ -233,7 +266,7 @@ func (c *MetricsBackendController) syncHandler(key string) error {
 	// backoff into a retry loop.
 	if _, err := metrics.Registry().Get(name); err == nil {
 		log.Infof("%s: backend for %q already exists - it will be replaced", metricsBackendControllerName, name)
-		metrics.Registry().Delete(name)
+		deleteBackend(name)
 	}
 
 	log.Infof("Instantiating backend client for MetricsBackend %q", name)
@@ -244,24 +277,109 @@ func (c *MetricsBackendController) syncHandler(key string) error {
 	metrics.Registry().Put(name, client)
 	log.Infof("Backend %q instantiated successfully", name)
 
+	// Failing to update the status isn't a reason to reinstantiate the
+	// backend; it will be retried periodically
+	if err := c.updateStatus(backend, client); err != nil {
+		log.Errorf("%s: error updating status of MetricsBackend %q: %s", metricsBackendControllerName, name, err)
+	}
+
 	return nil
 }
 
+// updateStatuses updates the status of every MetricsBackend with an
+// instantiated backend
+func (c *MetricsBackendController) updateStatuses() {
+	backends, err := c.metricsBackendLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("%s: error listing MetricsBackends: %s", metricsBackendControllerName, err)
+		return
+	}
+
+	for _, backend := range backends {
+		client, err := metrics.Registry().Get(backend.Name)
+		if err != nil {
+			// Not instantiated (yet)
+			continue
+		}
+
+		if err := c.updateStatus(backend, client); err != nil {
+			log.Errorf("%s: error updating status of MetricsBackend %q: %s", metricsBackendControllerName, backend.Name, err)
+		}
+	}
+}
+
+// updateStatus updates the status of the MetricsBackend with the details
+// reported by its instantiated backend, if the backend reports any and they
+// have changed
+func (c *MetricsBackendController) updateStatus(backend *cerebralv1alpha1.MetricsBackend, client metrics.Backend) error {
+	reporter, ok := client.(metrics.StatusReporter)
+	if !ok {
+		return nil
+	}
+
+	details := reporter.Status()
+	if reflect.DeepEqual(details, backend.Status.Details) {
+		return nil
+	}
+
+	backendCopy := backend.DeepCopy()
+	backendCopy.Status.Details = details
+	backendCopy.Status.LastUpdatedAt = metav1.Now()
+	_, err := c.cerebralclientset.CerebralV1alpha1().MetricsBackends().UpdateStatus(backendCopy)
+	return err
+}
+
 // insantiateBackend instantiates a new backend for the given MetricsBackend.
 // It should be the only function that knows how to instantiate a particular backend type.
 func (c *MetricsBackendController) instantiateBackend(backend *cerebralv1alpha1.MetricsBackend) (metrics.Backend, error) {
 	switch backend.Spec.Type {
 	case "prometheus":
-		var address string
-		var ok bool
-		address, ok = backend.Spec.Configuration["address"]
-		if !ok {
+		if _, ok := backend.Spec.Configuration["address"]; !ok {
 			return nil, errors.New("Prometheus backend requires address in configuration")
 		}
 
-		return prometheus.NewClient(address, c.nodeLister, c.podLister)
+		return prometheus.NewClient(backend.Spec.Configuration, c.kubeclientset, c.nodeLister, c.podLister)
+
+	case "graphite":
+		return graphite.NewClient(backend.Spec.Configuration, c.nodeLister)
+
+	case "http":
+		return httpjson.NewClient(backend.Spec.Configuration, c.kubeclientset, c.nodeLister)
+
+	case "influxdb":
+		return influxdb.NewClient(backend.Spec.Configuration, c.kubeclientset, c.nodeLister)
+
+	case "kubernetes":
+		return kubernetesbackend.NewClient(c.nodeLister, c.podLister)
+
+	case "metrics-server":
+		return metricsserver.NewClient(c.metricsclientset, c.nodeLister)
+
+	case "custom-metrics":
+		return custommetrics.NewClient(c.customMetricsClient, c.externalMetricsClient)
+
+	case "grpc":
+		return plugin.NewClient(backend.Spec.Configuration)
 
 	default:
 		return nil, errors.Errorf("unknown backend type %q", backend.Spec.Type)
 	}
 }
+
+// deleteBackend deletes the backend with the given name from the registry,
+// releasing any resources held by it (e.g. plugin connections)
+func deleteBackend(name string) {
+	backend, err := metrics.Registry().Get(name)
+	if err != nil {
+		// Nothing to do
+		return
+	}
+
+	metrics.Registry().Delete(name)
+
+	if closer, ok := backend.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Errorf("%s: error closing backend %q: %s", metricsBackendControllerName, name, err)
+		}
+	}
+}
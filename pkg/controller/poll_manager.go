@@ -2,20 +2,33 @@ package controller
 
 import (
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
 
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/record"
 
 	"github.com/containership/cluster-manager/pkg/log"
 
 	"github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
 	"github.com/containership/cerebral/pkg/events"
+	"github.com/containership/cerebral/pkg/nodeutil"
 )
 
+// Modes for resolving conflicting alerts
+const (
+	conflictResolutionScaleUpWins       = "scaleUpWins"
+	conflictResolutionLargestAdjustment = "largestAdjustment"
+	conflictResolutionVote              = "vote"
+)
+
+const defaultAlertEvaluationWindow = 30 * time.Second
+
 type pollManager struct {
 	asgName string
 
@@ -23,6 +36,11 @@ type pollManager struct {
 	asps    map[string]*v1alpha1.AutoscalingPolicy
 	pollers map[string]metricPoller
 
+	nodeSelector map[string]string
+	nodeLister   corelistersv1.NodeLister
+
+	resolution conflictResolution
+
 	recorder record.EventRecorder
 
 	scaleRequestCh chan<- ScaleRequest
@@ -38,20 +56,44 @@ type alert struct {
 	err error
 }
 
+// conflictResolution configures how a pollManager merges alerts. If mode is
+// empty, alerts are not merged.
+type conflictResolution struct {
+	mode   string
+	window time.Duration
+}
+
+// conflictResolutionForASG returns the conflict resolution configured for the
+// AutoscalingGroup
+func conflictResolutionForASG(asg *v1alpha1.AutoscalingGroup) conflictResolution {
+	window := time.Duration(asg.Spec.AlertEvaluationWindow) * time.Second
+	if window == 0 {
+		window = defaultAlertEvaluationWindow
+	}
+
+	return conflictResolution{
+		mode:   asg.Spec.ConflictResolution,
+		window: window,
+	}
+}
+
 func newPollManager(asgName string, asps map[string]*v1alpha1.AutoscalingPolicy, nodeSelector map[string]string,
-	recorder record.EventRecorder,
+	nodeLister corelistersv1.NodeLister, resolution conflictResolution, recorder record.EventRecorder,
 	scaleRequestCh chan<- ScaleRequest, stopCh chan struct{}) pollManager {
 	mgr := pollManager{
 		asgName:        asgName,
 		asps:           asps,
 		pollers:        make(map[string]metricPoller),
+		nodeSelector:   nodeSelector,
+		nodeLister:     nodeLister,
+		resolution:     resolution,
 		recorder:       recorder,
 		scaleRequestCh: scaleRequestCh,
 		stopCh:         stopCh,
 	}
 
 	for _, asp := range asps {
-		p := newMetricPoller(asp, nodeSelector)
+		p := newMetricPoller(asp, nodeSelector, nodeLister)
 		mgr.pollers[asp.ObjectMeta.Name] = p
 	}
 
@@ -69,6 +111,11 @@ func (m pollManager) run() error {
 
 	errCh := make(chan error)
 
+	// Alerts waiting to be resolved and the end of their evaluation window,
+	// if conflict resolution is enabled
+	var pending []alert
+	var windowCh <-chan time.Time
+
 	for {
 		select {
 		case alert := <-alertCh:
@@ -80,26 +127,42 @@ func (m pollManager) run() error {
 
 			if alert.direction == scaleDirectionUp {
 				m.recorder.Event(asp, corev1.EventTypeNormal, events.ScaleUpAlerted,
-					fmt.Sprintf("Alert triggered to scale up by %.2f (%s)",
-						alert.adjustmentValue, alert.adjustmentType.String()))
+					fmt.Sprintf("Alert triggered to scale up %s", describeAdjustment(alert)))
 			} else {
 				m.recorder.Event(asp, corev1.EventTypeNormal, events.ScaleDownAlerted,
-					fmt.Sprintf("Alert triggered to scale down by %.2f (%s)",
-						alert.adjustmentValue, alert.adjustmentType.String()))
+					fmt.Sprintf("Alert triggered to scale down %s", describeAdjustment(alert)))
 			}
 
-			m.scaleRequestCh <- ScaleRequest{
-				asgName:         m.asgName,
-				direction:       alert.direction,
-				adjustmentType:  alert.adjustmentType,
-				adjustmentValue: alert.adjustmentValue,
-				errCh:           errCh,
+			if m.resolution.mode == "" {
+				if err := m.requestScale(alert, errCh); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if len(pending) == 0 {
+				windowCh = time.After(m.resolution.window)
 			}
 
-			err := <-errCh
+			pending = append(pending, alert)
+
+		case <-windowCh:
+			alerts := pending
+			pending = nil
+			windowCh = nil
+
+			winner, ok, err := m.resolveAlerts(alerts)
 			if err != nil {
-				// If a scale request fails, just return an error so the relevant ASG can be re-enqueued
-				return errors.Wrap(err, "requesting scale manager to scale")
+				return errors.Wrap(err, "resolving alerts")
+			}
+
+			if !ok {
+				continue
+			}
+
+			if err := m.requestScale(winner, errCh); err != nil {
+				return err
 			}
 
 		case <-m.stopCh:
@@ -112,3 +175,134 @@ func (m pollManager) run() error {
 		}
 	}
 }
+
+// requestScale requests the scale manager to scale according to the alert and
+// waits for the response
+func (m pollManager) requestScale(a alert, errCh chan error) error {
+	m.scaleRequestCh <- ScaleRequest{
+		asgName:         m.asgName,
+		direction:       a.direction,
+		adjustmentType:  a.adjustmentType,
+		adjustmentValue: a.adjustmentValue,
+		errCh:           errCh,
+	}
+
+	err := <-errCh
+	if err != nil {
+		// If a scale request fails, just return an error so the relevant ASG can be re-enqueued
+		return errors.Wrap(err, "requesting scale manager to scale")
+	}
+
+	return nil
+}
+
+// resolveAlerts resolves the alerts collected during an evaluation window to
+// the single alert to act on, recording an event for each suppressed alert.
+// If no alert wins, false is returned.
+func (m pollManager) resolveAlerts(alerts []alert) (alert, bool, error) {
+	if len(alerts) == 1 {
+		return alerts[0], true, nil
+	}
+
+	nodes, err := m.nodeLister.List(nodeutil.GetNodesLabelSelector(m.nodeSelector))
+	if err != nil {
+		return alert{}, false, errors.Wrapf(err, "listing nodes for AutoscalingGroup %q", m.asgName)
+	}
+
+	winnerIndex, ok := selectWinningAlert(m.resolution.mode, alerts, len(nodes))
+
+	for i, a := range alerts {
+		if ok && i == winnerIndex {
+			continue
+		}
+
+		var reason string
+		if ok {
+			reason = fmt.Sprintf("in favor of alert from policy %q to scale %s %s",
+				alerts[winnerIndex].aspName, alerts[winnerIndex].direction.String(), describeAdjustment(alerts[winnerIndex]))
+		} else {
+			reason = "because the vote was tied"
+		}
+
+		m.recorder.Event(m.asps[a.aspName], corev1.EventTypeNormal, events.AlertSuppressed,
+			fmt.Sprintf("Alert to scale %s %s was suppressed by %s conflict resolution %s",
+				a.direction.String(), describeAdjustment(a), m.resolution.mode, reason))
+	}
+
+	if !ok {
+		return alert{}, false, nil
+	}
+
+	return alerts[winnerIndex], true, nil
+}
+
+// selectWinningAlert returns the index of the alert that wins using the given
+// conflict resolution mode. With scaleUpWins, scale up alerts are the
+// candidates if there are any. With largestAdjustment, all alerts are
+// candidates. With vote, alerts for the direction with the most alerts are the
+// candidates, and if the vote is tied then no alert wins and false is
+// returned. The candidate with the largest adjustment in nodes from the
+// current node count wins, with ties won by scaling up and then by the
+// earliest alert.
+func selectWinningAlert(mode string, alerts []alert, currNodeCount int) (int, bool) {
+	var ups, downs []int
+	for i, a := range alerts {
+		if a.direction == scaleDirectionUp {
+			ups = append(ups, i)
+		} else {
+			downs = append(downs, i)
+		}
+	}
+
+	var candidates []int
+	switch mode {
+	case conflictResolutionScaleUpWins:
+		candidates = ups
+		if len(candidates) == 0 {
+			candidates = downs
+		}
+
+	case conflictResolutionVote:
+		if len(ups) == len(downs) {
+			return 0, false
+		}
+
+		candidates = ups
+		if len(downs) > len(ups) {
+			candidates = downs
+		}
+
+	default:
+		// conflictResolutionLargestAdjustment
+		candidates = append(ups, downs...)
+	}
+
+	// Candidates are ordered by direction and then by time, so only
+	// replacing the winner with strictly larger adjustments breaks ties
+	winner := candidates[0]
+	for _, i := range candidates[1:] {
+		if alertNodeCountDelta(alerts[i], currNodeCount) > alertNodeCountDelta(alerts[winner], currNodeCount) {
+			winner = i
+		}
+	}
+
+	return winner, true
+}
+
+// alertNodeCountDelta returns the number of nodes the alert would add or
+// remove, ignoring the bounds of the AutoscalingGroup
+func alertNodeCountDelta(a alert, currNodeCount int) int {
+	target := calculateTargetNodeCount(currNodeCount, 0, math.MaxInt32,
+		a.direction, a.adjustmentType, a.adjustmentValue)
+	return abs(target - currNodeCount)
+}
+
+// describeAdjustment returns a description of the alert's adjustment, e.g.
+// "by 2.00 (absolute)"
+func describeAdjustment(a alert) string {
+	if a.adjustmentType == adjustmentTypeTarget {
+		return fmt.Sprintf("to target of %d nodes", int(a.adjustmentValue))
+	}
+
+	return fmt.Sprintf("by %.2f (%s)", a.adjustmentValue, a.adjustmentType.String())
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+func TestConflictResolutionForASG(t *testing.T) {
+	asg := &v1alpha1.AutoscalingGroup{}
+	resolution := conflictResolutionForASG(asg)
+	assert.Empty(t, resolution.mode, "no conflict resolution by default")
+	assert.Equal(t, defaultAlertEvaluationWindow, resolution.window, "default window")
+
+	asg.Spec.ConflictResolution = conflictResolutionVote
+	asg.Spec.AlertEvaluationWindow = 60
+	resolution = conflictResolutionForASG(asg)
+	assert.Equal(t, conflictResolutionVote, resolution.mode)
+	assert.Equal(t, time.Minute, resolution.window)
+}
+
+func TestAlertNodeCountDelta(t *testing.T) {
+	assert.Equal(t, 2, alertNodeCountDelta(alert{
+		direction:       scaleDirectionUp,
+		adjustmentType:  adjustmentTypeAbsolute,
+		adjustmentValue: 2,
+	}, 4), "absolute")
+
+	assert.Equal(t, 2, alertNodeCountDelta(alert{
+		direction:       scaleDirectionDown,
+		adjustmentType:  adjustmentTypePercent,
+		adjustmentValue: 50,
+	}, 4), "percent")
+
+	assert.Equal(t, 3, alertNodeCountDelta(alert{
+		direction:       scaleDirectionUp,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: 7,
+	}, 4), "target")
+
+	assert.Equal(t, 4, alertNodeCountDelta(alert{
+		direction:       scaleDirectionDown,
+		adjustmentType:  adjustmentTypeAbsolute,
+		adjustmentValue: 10,
+	}, 4), "can't scale below zero")
+}
+
+func TestSelectWinningAlert(t *testing.T) {
+	alerts := []alert{
+		{
+			aspName:         "up-by-1",
+			direction:       scaleDirectionUp,
+			adjustmentType:  adjustmentTypeAbsolute,
+			adjustmentValue: 1,
+		},
+		{
+			aspName:         "down-by-half",
+			direction:       scaleDirectionDown,
+			adjustmentType:  adjustmentTypePercent,
+			adjustmentValue: 50,
+		},
+		{
+			aspName:         "up-to-7",
+			direction:       scaleDirectionUp,
+			adjustmentType:  adjustmentTypeTarget,
+			adjustmentValue: 7,
+		},
+		{
+			aspName:         "down-by-1",
+			direction:       scaleDirectionDown,
+			adjustmentType:  adjustmentTypeAbsolute,
+			adjustmentValue: 1,
+		},
+	}
+
+	winner, ok := selectWinningAlert(conflictResolutionScaleUpWins, alerts, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 2, winner, "scaleUpWins: largest scale up wins")
+
+	winner, ok = selectWinningAlert(conflictResolutionScaleUpWins, []alert{alerts[1], alerts[3]}, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 0, winner, "scaleUpWins: largest scale down wins if no scale ups")
+
+	winner, ok = selectWinningAlert(conflictResolutionLargestAdjustment, alerts, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 2, winner, "largestAdjustment")
+
+	winner, ok = selectWinningAlert(conflictResolutionLargestAdjustment, []alert{alerts[3], alerts[1]}, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 1, winner, "largestAdjustment: scale down can win")
+
+	winner, ok = selectWinningAlert(conflictResolutionLargestAdjustment, []alert{alerts[3], alerts[0]}, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 1, winner, "largestAdjustment: ties won by scaling up")
+
+	winner, ok = selectWinningAlert(conflictResolutionLargestAdjustment, []alert{alerts[3], alerts[3]}, 4)
+	assert.True(t, ok)
+	assert.Equal(t, 0, winner, "largestAdjustment: ties in the same direction won by earliest alert")
+
+	_, ok = selectWinningAlert(conflictResolutionVote, alerts, 4)
+	assert.False(t, ok, "vote: tied vote has no winner")
+
+	winner, ok = selectWinningAlert(conflictResolutionVote, alerts[:3], 4)
+	assert.True(t, ok)
+	assert.Equal(t, 2, winner, "vote: largest alert in majority direction wins")
+
+	winner, ok = selectWinningAlert(conflictResolutionVote, alerts[1:], 4)
+	assert.True(t, ok)
+	assert.Equal(t, 0, winner, "vote: largest alert in scale down majority wins")
+}
@@ -53,6 +53,10 @@ type adjustmentType int
 const (
 	adjustmentTypeAbsolute = iota
 	adjustmentTypePercent
+	// adjustmentTypeTarget means the adjustment value is the target node
+	// count itself. It's used for target tracking and can't be specified
+	// directly in a ScalingPolicyConfiguration.
+	adjustmentTypeTarget
 )
 
 func (a adjustmentType) String() string {
@@ -61,6 +65,8 @@ func (a adjustmentType) String() string {
 		return "absolute"
 	case adjustmentTypePercent:
 		return "percent"
+	case adjustmentTypeTarget:
+		return "target"
 	}
 
 	return "unknown"
@@ -89,13 +95,17 @@ type ScaleManager struct {
 	nodeLister corelistersv1.NodeLister
 	nodeSynced cache.InformerSynced
 
+	ssaLister clisters.ScheduledScalingActionLister
+	ssaSynced cache.InformerSynced
+
 	recorder record.EventRecorder
 
 	scaleRequestCh chan ScaleRequest
 }
 
 // A ScaleRequest represents a request to the ScaleManager to perform a scaling
-// operation.
+// operation. Requests either adjust the node count in a direction or, if the
+// adjustment type is adjustmentTypeTarget, set it to an absolute target.
 type ScaleRequest struct {
 	asgName         string
 	direction       scaleDirection
@@ -135,6 +145,7 @@ func NewScaleManager(
 
 	asgInformer := cInformerFactory.Cerebral().V1alpha1().AutoscalingGroups()
 	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+	ssaInformer := cInformerFactory.Cerebral().V1alpha1().ScheduledScalingActions()
 
 	m.asgLister = asgInformer.Lister()
 	m.asgSynced = asgInformer.Informer().HasSynced
@@ -142,6 +153,9 @@ func NewScaleManager(
 	m.nodeLister = nodeInformer.Lister()
 	m.nodeSynced = nodeInformer.Informer().HasSynced
 
+	m.ssaLister = ssaInformer.Lister()
+	m.ssaSynced = ssaInformer.Informer().HasSynced
+
 	return m
 }
 
@@ -229,29 +243,40 @@ func (m *ScaleManager) handleScaleRequestForASG(asg *cerebralv1alpha1.Autoscalin
 		return false, errors.Wrapf(err, "listing nodes for AutoscalingGroup %q", req.asgName)
 	}
 
+	minNodes, maxNodes, err := autoscalingGroupBounds(asg, m.ssaLister)
+	if err != nil {
+		return false, errors.Wrapf(err, "getting bounds for AutoscalingGroup %q", req.asgName)
+	}
+
 	currNodeCount := len(nodes)
-	targetNodeCount := calculateTargetNodeCount(currNodeCount, asg.Spec.MinNodes, asg.Spec.MaxNodes,
+	targetNodeCount := calculateTargetNodeCount(currNodeCount, minNodes, maxNodes,
 		req.direction, req.adjustmentType, req.adjustmentValue)
+	dir := req.directionFrom(currNodeCount, targetNodeCount)
 
 	if currNodeCount == targetNodeCount {
 		// The scale operation would be a noop, so just ignore it but record
 		// a warning event if this case is interesting
-		if req.direction == scaleDirectionUp && targetNodeCount == asg.Spec.MaxNodes {
+		if dir == scaleDirectionUp && targetNodeCount == maxNodes {
 			m.recorder.Event(asg, corev1.EventTypeWarning, events.ScaleIgnored,
 				fmt.Sprintf("Scale %s operation would exceed upper bound of %d nodes",
-					req.direction.String(), asg.Spec.MaxNodes))
-		} else if req.direction == scaleDirectionDown && targetNodeCount == asg.Spec.MinNodes {
+					dir.String(), maxNodes))
+		} else if dir == scaleDirectionDown && targetNodeCount == minNodes {
 			m.recorder.Event(asg, corev1.EventTypeWarning, events.ScaleIgnored,
 				fmt.Sprintf("Scale %s operation would exceed lower bound of %d nodes",
-					req.direction.String(), asg.Spec.MinNodes))
+					dir.String(), minNodes))
 		}
 
 		return false, nil
 	}
 
-	strategy := getAutoscalingGroupStrategy(req.direction, asg)
+	strategy := getAutoscalingGroupStrategy(dir, asg)
 
-	scaled, err := engine.SetTargetNodeCount(asg.Spec.NodeSelector, targetNodeCount, strategy)
+	var scaled bool
+	if scaler, ok := engine.(autoscalingengine.AutoscalingGroupScaler); ok {
+		scaled, err = scaler.SetAutoscalingGroupTargetNodeCount(asg.Name, asg.Spec.NodeSelector, targetNodeCount, strategy)
+	} else {
+		scaled, err = engine.SetTargetNodeCount(asg.Spec.NodeSelector, targetNodeCount, strategy)
+	}
 	if err != nil {
 		m.recorder.Event(asg, corev1.EventTypeWarning, events.ScaleError,
 			fmt.Sprintf("Failed to scale: %s", err))
@@ -262,7 +287,7 @@ func (m *ScaleManager) handleScaleRequestForASG(asg *cerebralv1alpha1.Autoscalin
 		return false, nil
 	}
 
-	if req.direction == scaleDirectionUp {
+	if dir == scaleDirectionUp {
 		m.recorder.Event(asg, corev1.EventTypeNormal, events.ScaledUp,
 			fmt.Sprintf("Scaled up to %d nodes using strategy %q", targetNodeCount, strategy))
 	} else {
@@ -273,6 +298,22 @@ func (m *ScaleManager) handleScaleRequestForASG(asg *cerebralv1alpha1.Autoscalin
 	return true, nil
 }
 
+// directionFrom returns the direction in which scaling from the current node
+// count to the target node count goes. This may differ from the requested
+// direction, since the node count may have changed since a target was
+// requested and the target is fit within bounds that scheduled overrides may
+// have moved past the current node count. The requested direction is used if
+// the target is the current node count.
+func (req ScaleRequest) directionFrom(curr, target int) scaleDirection {
+	if target > curr {
+		return scaleDirectionUp
+	} else if target < curr {
+		return scaleDirectionDown
+	}
+
+	return req.direction
+}
+
 func (m *ScaleManager) updateAutoscalingGroupStatus(asg *cerebralv1alpha1.AutoscalingGroup) error {
 	asgCopy := asg.DeepCopy()
 	asgCopy.Status.LastUpdatedAt = metav1.Now()
@@ -304,6 +345,9 @@ func calculateTargetNodeCount(curr, min, max int,
 		} else {
 			result = int(float64(curr) - math.Ceil(adjustBy))
 		}
+
+	case adjustmentTypeTarget:
+		result = int(adjustmentValue)
 	}
 
 	return fitWithinBounds(result, min, max)
@@ -156,6 +156,39 @@ var calculateTargetNodeCountTests = []calculateTargetNodeCountTest{
 		expected: 3,
 		message:  "percent takes ceiling",
 	},
+	{
+		curr:            2,
+		min:             1,
+		max:             5,
+		dir:             scaleDirectionUp,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: 4,
+
+		expected: 4,
+		message:  "target is used as is",
+	},
+	{
+		curr:            2,
+		min:             1,
+		max:             5,
+		dir:             scaleDirectionUp,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: 8,
+
+		expected: 5,
+		message:  "target would scale above max",
+	},
+	{
+		curr:            4,
+		min:             2,
+		max:             5,
+		dir:             scaleDirectionDown,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: 1,
+
+		expected: 2,
+		message:  "target would scale below min",
+	},
 }
 
 type fitWithinBoundsTest struct {
@@ -205,6 +238,27 @@ func TestCalculateSetTargetNodeCount(t *testing.T) {
 	}
 }
 
+func TestScaleRequestDirectionFrom(t *testing.T) {
+	req := ScaleRequest{
+		direction:       scaleDirectionUp,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: 4,
+	}
+	assert.Equal(t, scaleDirectionUp, req.directionFrom(2, 4), "target above current scales up")
+	assert.Equal(t, scaleDirectionDown, req.directionFrom(6, 4), "target below current scales down")
+	assert.Equal(t, scaleDirectionUp, req.directionFrom(4, 4), "requested direction used if on target")
+
+	// A scale down fit within a minimum raised above the current node count
+	req = ScaleRequest{
+		direction:       scaleDirectionDown,
+		adjustmentType:  adjustmentTypeAbsolute,
+		adjustmentValue: 1,
+	}
+	target := calculateTargetNodeCount(2, 3, 10, req.direction, req.adjustmentType, req.adjustmentValue)
+	assert.Equal(t, 3, target)
+	assert.Equal(t, scaleDirectionUp, req.directionFrom(2, target), "direction follows target fit within bounds")
+}
+
 func TestFitWithinBounds(t *testing.T) {
 	for _, test := range fitWithinBoundsTests {
 		val := fitWithinBounds(test.val, test.min, test.max)
@@ -0,0 +1,461 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+
+	corev1 "k8s.io/api/core/v1"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/containership/cluster-manager/pkg/log"
+
+	cerebralv1alpha1 "github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+	cerebral "github.com/containership/cerebral/pkg/client/clientset/versioned"
+	cinformers "github.com/containership/cerebral/pkg/client/informers/externalversions"
+	clisters "github.com/containership/cerebral/pkg/client/listers/cerebral.containership.io/v1alpha1"
+	"github.com/containership/cerebral/pkg/events"
+	"github.com/containership/cerebral/pkg/nodeutil"
+)
+
+const (
+	scheduledScalingActionControllerName = "ScheduledScalingActionController"
+
+	// How often scheduled actions are checked. Cron schedules have a
+	// granularity of one minute.
+	scheduledScalingActionSyncInterval = 10 * time.Second
+
+	// Scheduled times that are missed by more than this, e.g. because
+	// Cerebral was not running, are skipped
+	scheduledScalingActionStartingDeadline = 5 * time.Minute
+)
+
+// ScheduledScalingActionController is a controller that triggers
+// ScheduledScalingActions at their scheduled times by requesting the
+// ScaleManager to scale the referenced AutoscalingGroups
+type ScheduledScalingActionController struct {
+	kubeclientset     kubernetes.Interface
+	cerebralclientset cerebral.Interface
+
+	ssaLister clisters.ScheduledScalingActionLister
+	ssaSynced cache.InformerSynced
+
+	asgLister clisters.AutoscalingGroupLister
+	asgSynced cache.InformerSynced
+
+	nodeLister corelistersv1.NodeLister
+	nodeSynced cache.InformerSynced
+
+	recorder record.EventRecorder
+
+	scaleRequestCh chan<- ScaleRequest
+}
+
+// NewScheduledScalingAction constructs a new ScheduledScalingAction controller
+func NewScheduledScalingAction(kubeclientset kubernetes.Interface,
+	kubeInformerFactory kubeinformers.SharedInformerFactory,
+	cerebralclientset cerebral.Interface,
+	cInformerFactory cinformers.SharedInformerFactory,
+	scaleRequestCh chan<- ScaleRequest) *ScheduledScalingActionController {
+	c := &ScheduledScalingActionController{
+		kubeclientset:     kubeclientset,
+		cerebralclientset: cerebralclientset,
+		scaleRequestCh:    scaleRequestCh,
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(log.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: kubeclientset.CoreV1().Events(""),
+	})
+	c.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+		Component: controllerName,
+	})
+
+	ssaInformer := cInformerFactory.Cerebral().V1alpha1().ScheduledScalingActions()
+	asgInformer := cInformerFactory.Cerebral().V1alpha1().AutoscalingGroups()
+	nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+
+	c.ssaLister = ssaInformer.Lister()
+	c.ssaSynced = ssaInformer.Informer().HasSynced
+
+	c.asgLister = asgInformer.Lister()
+	c.asgSynced = asgInformer.Informer().HasSynced
+
+	c.nodeLister = nodeInformer.Lister()
+	c.nodeSynced = nodeInformer.Informer().HasSynced
+
+	return c
+}
+
+// Run waits for the informer caches to sync and then periodically triggers
+// any ScheduledScalingActions that are due. It will block until stopCh is
+// closed.
+func (c *ScheduledScalingActionController) Run(stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+
+	log.Infof("Starting %s", scheduledScalingActionControllerName)
+
+	if ok := cache.WaitForCacheSync(stopCh, c.ssaSynced, c.asgSynced, c.nodeSynced); !ok {
+		return errors.Errorf("%s: failed to wait for caches to sync", scheduledScalingActionControllerName)
+	}
+
+	wait.Until(c.syncAll, scheduledScalingActionSyncInterval, stopCh)
+	log.Infof("Shutting down %s", scheduledScalingActionControllerName)
+
+	return nil
+}
+
+// syncAll triggers all ScheduledScalingActions that are due
+func (c *ScheduledScalingActionController) syncAll() {
+	actions, err := c.ssaLister.List(labels.NewSelector())
+	if err != nil {
+		log.Errorf("%s: listing ScheduledScalingActions: %s", scheduledScalingActionControllerName, err)
+		return
+	}
+
+	for _, action := range actions {
+		if err := c.syncHandler(action); err != nil {
+			log.Errorf("%s: syncing ScheduledScalingAction %q: %s", scheduledScalingActionControllerName, action.Name, err)
+		}
+	}
+}
+
+// syncHandler triggers the ScheduledScalingAction if a scheduled time has
+// passed since it was last triggered
+func (c *ScheduledScalingActionController) syncHandler(action *cerebralv1alpha1.ScheduledScalingAction) error {
+	if action.Spec.Suspended {
+		return nil
+	}
+
+	if err := validateScheduledScalingActionSpec(action.Spec); err != nil {
+		return err
+	}
+
+	schedule, err := parseSchedule(action.Spec)
+	if err != nil {
+		return err
+	}
+
+	now := nowFunc()
+	since := action.Status.LastScheduleTime.Time
+	if since.IsZero() {
+		since = action.CreationTimestamp.Time
+	}
+
+	start, missed := scheduleWindowStart(schedule, since, now)
+	scheduled, ok := lastScheduledTime(schedule, start, now)
+	if !ok {
+		if missed.IsZero() {
+			return nil
+		}
+
+		c.recorder.Event(action, corev1.EventTypeWarning, events.ScheduledActionMissed,
+			fmt.Sprintf("Missed scheduled times since %s", missed.Format(time.RFC3339)))
+		return c.updateStatus(action, start)
+	}
+
+	if err := c.trigger(action); err != nil {
+		c.recorder.Event(action, corev1.EventTypeWarning, events.ScheduledActionError,
+			fmt.Sprintf("Failed to trigger for scheduled time %s: %s", scheduled.Format(time.RFC3339), err))
+		return err
+	}
+
+	return c.updateStatus(action, scheduled)
+}
+
+// trigger requests the ScaleManager to scale the AutoscalingGroup to the
+// target node count of the action, fit within the bounds of the
+// AutoscalingGroup including any overrides
+func (c *ScheduledScalingActionController) trigger(action *cerebralv1alpha1.ScheduledScalingAction) error {
+	asgName := action.Spec.AutoscalingGroup
+	asg, err := c.asgLister.Get(asgName)
+	if err != nil {
+		return errors.Wrapf(err, "getting AutoscalingGroup %q", asgName)
+	}
+
+	nodes, err := c.nodeLister.List(nodeutil.GetNodesLabelSelector(asg.Spec.NodeSelector))
+	if err != nil {
+		return errors.Wrapf(err, "listing nodes for AutoscalingGroup %q", asgName)
+	}
+
+	min, max, err := autoscalingGroupBounds(asg, c.ssaLister)
+	if err != nil {
+		return errors.Wrapf(err, "getting bounds for AutoscalingGroup %q", asgName)
+	}
+
+	currNodeCount := len(nodes)
+	targetNodeCount := currNodeCount
+	if action.Spec.TargetNodeCount != nil {
+		targetNodeCount = *action.Spec.TargetNodeCount
+	}
+
+	targetNodeCount = fitWithinBounds(targetNodeCount, min, max)
+
+	c.recorder.Event(action, corev1.EventTypeNormal, events.ScheduledActionTriggered,
+		fmt.Sprintf("Triggered with AutoscalingGroup %q bounds of %d to %d nodes and target of %d nodes",
+			asgName, min, max, targetNodeCount))
+
+	if targetNodeCount == currNodeCount {
+		return nil
+	}
+
+	dir := scaleDirectionUp
+	if targetNodeCount < currNodeCount {
+		dir = scaleDirectionDown
+	}
+
+	// Scheduled actions do not respect the cooldown because they're
+	// explicitly requested for a specific time
+	errCh := make(chan error)
+	c.scaleRequestCh <- ScaleRequest{
+		asgName:         asgName,
+		direction:       dir,
+		adjustmentType:  adjustmentTypeTarget,
+		adjustmentValue: float64(targetNodeCount),
+		ignoreCooldown:  true,
+		errCh:           errCh,
+	}
+
+	if err := <-errCh; err != nil {
+		return errors.Wrap(err, "requesting scale manager to scale")
+	}
+
+	return nil
+}
+
+func (c *ScheduledScalingActionController) updateStatus(action *cerebralv1alpha1.ScheduledScalingAction, scheduled time.Time) error {
+	actionCopy := action.DeepCopy()
+	actionCopy.Status.LastScheduleTime = metav1.NewTime(scheduled)
+	_, err := c.cerebralclientset.CerebralV1alpha1().ScheduledScalingActions().UpdateStatus(actionCopy)
+	if err != nil && !kubeerrors.IsNotFound(err) {
+		return errors.Wrap(err, "updating status")
+	}
+
+	return nil
+}
+
+// validateScheduledScalingActionSpec validates the parts of the spec that
+// can't be validated by the CRD
+func validateScheduledScalingActionSpec(spec cerebralv1alpha1.ScheduledScalingActionSpec) error {
+	hasOverride := spec.MinNodes != nil || spec.MaxNodes != nil
+	if spec.TargetNodeCount == nil && !hasOverride {
+		return errors.New("at least one of targetNodeCount, minNodes or maxNodes must be specified")
+	}
+
+	if hasOverride && spec.Duration <= 0 {
+		return errors.New("duration must be specified when minNodes or maxNodes is specified")
+	}
+
+	return nil
+}
+
+// parseSchedule parses the schedule of the spec, returning a schedule that
+// is evaluated in the time zone of the spec
+func parseSchedule(spec cerebralv1alpha1.ScheduledScalingActionSpec) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec.Schedule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing schedule %q", spec.Schedule)
+	}
+
+	// An empty time zone is UTC
+	loc, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading time zone %q", spec.TimeZone)
+	}
+
+	return locationSchedule{schedule, loc}, nil
+}
+
+// locationSchedule is a cron.Schedule that is evaluated in a time zone
+type locationSchedule struct {
+	cron.Schedule
+	loc *time.Location
+}
+
+// Next returns the next scheduled time after t
+func (s locationSchedule) Next(t time.Time) time.Time {
+	return s.Schedule.Next(t.In(s.loc))
+}
+
+// scheduleWindowStart returns the time after which scheduled times of an
+// action last handled at since can still be triggered at now. Scheduled times
+// before the starting deadline can only be missed, so rather than walking
+// every one of them from an old LastScheduleTime or CreationTimestamp, the
+// window starts at the deadline and only the first skipped scheduled time is
+// returned. If none was skipped, the returned missed time is zero.
+func scheduleWindowStart(schedule cron.Schedule, since, now time.Time) (time.Time, time.Time) {
+	deadline := now.Add(-scheduledScalingActionStartingDeadline)
+	if !since.Before(deadline) {
+		return since, time.Time{}
+	}
+
+	missed := schedule.Next(since)
+	if missed.IsZero() || missed.After(deadline) {
+		return deadline, time.Time{}
+	}
+
+	return deadline, missed
+}
+
+// lastScheduledTime returns the most recent scheduled time after since and at
+// or before now. If there is none, false is returned.
+func lastScheduledTime(schedule cron.Schedule, since, now time.Time) (time.Time, bool) {
+	scheduled := schedule.Next(since)
+	if scheduled.IsZero() || scheduled.After(now) {
+		return time.Time{}, false
+	}
+
+	for {
+		next := schedule.Next(scheduled)
+		if next.IsZero() || next.After(now) {
+			return scheduled, true
+		}
+
+		scheduled = next
+	}
+}
+
+// autoscalingGroupBounds returns the min and max node counts of the
+// AutoscalingGroup, including the overrides of any active
+// ScheduledScalingActions
+func autoscalingGroupBounds(asg *cerebralv1alpha1.AutoscalingGroup,
+	ssaLister clisters.ScheduledScalingActionLister) (int, int, error) {
+	actions, err := ssaLister.List(labels.NewSelector())
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "listing ScheduledScalingActions")
+	}
+
+	min, max := effectiveBounds(asg, actions, nowFunc())
+	return min, max, nil
+}
+
+// effectiveBounds returns the min and max node counts of the
+// AutoscalingGroup, replacing them with the overrides of any actions for the
+// AutoscalingGroup that are active at the given time. If multiple overrides
+// are active, the largest min and max win. If the min would exceed the max,
+// the max is raised to the min.
+func effectiveBounds(asg *cerebralv1alpha1.AutoscalingGroup,
+	actions []*cerebralv1alpha1.ScheduledScalingAction, now time.Time) (int, int) {
+	var minOverride, maxOverride *int
+	for _, action := range actions {
+		if action.Spec.AutoscalingGroup != asg.Name || !overrideIsActive(action, now) {
+			continue
+		}
+
+		if m := action.Spec.MinNodes; m != nil && (minOverride == nil || *m > *minOverride) {
+			minOverride = m
+		}
+
+		if m := action.Spec.MaxNodes; m != nil && (maxOverride == nil || *m > *maxOverride) {
+			maxOverride = m
+		}
+	}
+
+	min, max := asg.Spec.MinNodes, asg.Spec.MaxNodes
+	if minOverride != nil {
+		min = *minOverride
+	}
+
+	if maxOverride != nil {
+		max = *maxOverride
+	}
+
+	if min > max {
+		max = min
+	}
+
+	return min, max
+}
+
+// overrideIsActive returns true if the action overrides bounds and was
+// scheduled within its duration of the given time
+func overrideIsActive(action *cerebralv1alpha1.ScheduledScalingAction, now time.Time) bool {
+	_, ok := activeOverrideEnd(action, now)
+	return ok
+}
+
+// activeOverrideEnd returns the time at which the bounds override of the
+// action that is active at the given time ends. If no override is active,
+// false is returned.
+func activeOverrideEnd(action *cerebralv1alpha1.ScheduledScalingAction, now time.Time) (time.Time, bool) {
+	schedule, ok := overrideSchedule(action)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	duration := time.Duration(action.Spec.Duration) * time.Second
+	start := now.Add(-duration)
+	if start.Before(action.CreationTimestamp.Time) {
+		start = action.CreationTimestamp.Time
+	}
+
+	scheduled, ok := lastScheduledTime(schedule, start, now)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return scheduled.Add(duration), true
+}
+
+// overrideSchedule returns the schedule of the action if it overrides
+// bounds. Otherwise, false is returned.
+func overrideSchedule(action *cerebralv1alpha1.ScheduledScalingAction) (cron.Schedule, bool) {
+	spec := action.Spec
+	if spec.Suspended || spec.Duration <= 0 || (spec.MinNodes == nil && spec.MaxNodes == nil) {
+		return nil, false
+	}
+
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return nil, false
+	}
+
+	return schedule, true
+}
+
+// nextBoundsChange returns the earliest time after now at which a bounds
+// override of one of the actions for the AutoscalingGroup starts or ends. If
+// there is none, false is returned.
+func nextBoundsChange(asgName string, actions []*cerebralv1alpha1.ScheduledScalingAction, now time.Time) (time.Time, bool) {
+	var next time.Time
+	consider := func(t time.Time) {
+		if !t.IsZero() && t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	for _, action := range actions {
+		if action.Spec.AutoscalingGroup != asgName {
+			continue
+		}
+
+		schedule, ok := overrideSchedule(action)
+		if !ok {
+			continue
+		}
+
+		consider(schedule.Next(now))
+
+		if end, ok := activeOverrideEnd(action, now); ok {
+			consider(end)
+		}
+	}
+
+	return next, !next.IsZero()
+}
@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/containership/cerebral/pkg/apis/cerebral.containership.io/v1alpha1"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidateScheduledScalingActionSpec(t *testing.T) {
+	err := validateScheduledScalingActionSpec(v1alpha1.ScheduledScalingActionSpec{})
+	assert.Error(t, err, "nothing to do")
+
+	err = validateScheduledScalingActionSpec(v1alpha1.ScheduledScalingActionSpec{
+		TargetNodeCount: intPtr(3),
+	})
+	assert.NoError(t, err, "target only")
+
+	err = validateScheduledScalingActionSpec(v1alpha1.ScheduledScalingActionSpec{
+		MinNodes: intPtr(3),
+	})
+	assert.Error(t, err, "override without duration")
+
+	err = validateScheduledScalingActionSpec(v1alpha1.ScheduledScalingActionSpec{
+		MaxNodes: intPtr(3),
+		Duration: 60,
+	})
+	assert.NoError(t, err, "override with duration")
+}
+
+func TestParseSchedule(t *testing.T) {
+	_, err := parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "not a schedule",
+	})
+	assert.Error(t, err, "invalid schedule")
+
+	_, err = parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "0 8 * * *",
+		TimeZone: "Not/AZone",
+	})
+	assert.Error(t, err, "invalid time zone")
+
+	schedule, err := parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "0 8 * * *",
+	})
+	assert.NoError(t, err)
+	since := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, schedule.Next(since).Equal(time.Date(2019, time.January, 1, 8, 0, 0, 0, time.UTC)),
+		"defaults to UTC")
+
+	schedule, err = parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "0 8 * * *",
+		TimeZone: "America/New_York",
+	})
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(since).Equal(time.Date(2019, time.January, 1, 13, 0, 0, 0, time.UTC)),
+		"evaluated in time zone")
+}
+
+func TestLastScheduledTime(t *testing.T) {
+	schedule, _ := parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "0 * * * *",
+	})
+
+	since := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	_, ok := lastScheduledTime(schedule, since, since.Add(30*time.Minute))
+	assert.False(t, ok, "not scheduled yet")
+
+	scheduled, ok := lastScheduledTime(schedule, since, since.Add(time.Hour))
+	assert.True(t, ok)
+	assert.True(t, scheduled.Equal(since.Add(time.Hour)), "scheduled time is inclusive")
+
+	scheduled, ok = lastScheduledTime(schedule, since, since.Add(5*time.Hour+time.Minute))
+	assert.True(t, ok)
+	assert.True(t, scheduled.Equal(since.Add(5*time.Hour)), "most recent of multiple scheduled times")
+
+	_, ok = lastScheduledTime(schedule, since.Add(5*time.Hour), since.Add(5*time.Hour+time.Minute))
+	assert.False(t, ok, "since is exclusive")
+}
+
+func TestScheduleWindowStart(t *testing.T) {
+	schedule, _ := parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "* * * * *",
+	})
+
+	// An action created long ago that has never been handled
+	created := time.Date(2018, time.January, 1, 0, 0, 30, 0, time.UTC)
+	now := time.Date(2019, time.January, 1, 0, 0, 30, 0, time.UTC)
+
+	start, missed := scheduleWindowStart(schedule, created, now)
+	assert.True(t, start.Equal(now.Add(-scheduledScalingActionStartingDeadline)), "window starts at deadline")
+	assert.True(t, missed.Equal(created.Add(30*time.Second)), "first skipped scheduled time is missed")
+
+	scheduled, ok := lastScheduledTime(schedule, start, now)
+	assert.True(t, ok)
+	assert.True(t, scheduled.Equal(now.Add(-30*time.Second)), "most recent scheduled time within deadline")
+
+	since := now.Add(-time.Minute)
+	start, missed = scheduleWindowStart(schedule, since, now)
+	assert.True(t, start.Equal(since), "recent since is kept")
+	assert.True(t, missed.IsZero())
+
+	hourly, _ := parseSchedule(v1alpha1.ScheduledScalingActionSpec{
+		Schedule: "0 * * * *",
+	})
+	since = now.Add(-30 * time.Minute)
+	start, missed = scheduleWindowStart(hourly, since, now)
+	assert.True(t, start.Equal(now.Add(-scheduledScalingActionStartingDeadline)))
+	assert.True(t, missed.IsZero(), "nothing scheduled before deadline")
+}
+
+func TestEffectiveBounds(t *testing.T) {
+	created := metav1.NewTime(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC))
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+
+	asg := &v1alpha1.AutoscalingGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "asg",
+		},
+		Spec: v1alpha1.AutoscalingGroupSpec{
+			MinNodes: 1,
+			MaxNodes: 5,
+		},
+	}
+
+	newAction := func(asgName, schedule string, min, max *int, duration int) *v1alpha1.ScheduledScalingAction {
+		return &v1alpha1.ScheduledScalingAction{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: created,
+			},
+			Spec: v1alpha1.ScheduledScalingActionSpec{
+				AutoscalingGroup: asgName,
+				Schedule:         schedule,
+				MinNodes:         min,
+				MaxNodes:         max,
+				Duration:         duration,
+			},
+		}
+	}
+
+	min, max := effectiveBounds(asg, nil, now)
+	assert.Equal(t, 1, min, "no actions")
+	assert.Equal(t, 5, max, "no actions")
+
+	actions := []*v1alpha1.ScheduledScalingAction{
+		newAction("other", "0 8 * * *", intPtr(8), intPtr(10), 7200),
+		newAction("asg", "0 6 * * *", intPtr(8), intPtr(10), 3600),
+	}
+	min, max = effectiveBounds(asg, actions, now)
+	assert.Equal(t, 1, min, "overrides of other groups and expired overrides ignored")
+	assert.Equal(t, 5, max, "overrides of other groups and expired overrides ignored")
+
+	actions = append(actions, newAction("asg", "0 8 * * *", intPtr(3), nil, 7200))
+	min, max = effectiveBounds(asg, actions, now)
+	assert.Equal(t, 3, min, "min overridden")
+	assert.Equal(t, 5, max, "max not overridden")
+
+	actions = append(actions, newAction("asg", "30 8 * * *", intPtr(4), intPtr(12), 3600))
+	min, max = effectiveBounds(asg, actions, now)
+	assert.Equal(t, 4, min, "largest min override wins")
+	assert.Equal(t, 12, max, "max overridden")
+
+	actions = append(actions, newAction("asg", "0 9 * * *", intPtr(15), nil, 3600))
+	min, max = effectiveBounds(asg, actions, now)
+	assert.Equal(t, 15, min, "scheduled time is inclusive")
+	assert.Equal(t, 15, max, "max raised to min")
+}
+
+func TestOverrideIsActive(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+
+	action := &v1alpha1.ScheduledScalingAction{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-30 * time.Minute)),
+		},
+		Spec: v1alpha1.ScheduledScalingActionSpec{
+			Schedule: "0 8 * * *",
+			MinNodes: intPtr(3),
+			Duration: 7200,
+		},
+	}
+
+	assert.False(t, overrideIsActive(action, now), "scheduled time before creation ignored")
+
+	action.CreationTimestamp = metav1.NewTime(now.Add(-24 * time.Hour))
+	assert.True(t, overrideIsActive(action, now), "active within duration")
+	assert.False(t, overrideIsActive(action, now.Add(2*time.Hour)), "inactive after duration")
+
+	action.Spec.Suspended = true
+	assert.False(t, overrideIsActive(action, now), "suspended actions are inactive")
+}
+
+func TestNextBoundsChange(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+
+	_, ok := nextBoundsChange("asg", nil, now)
+	assert.False(t, ok, "no actions")
+
+	override := &v1alpha1.ScheduledScalingAction{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+		},
+		Spec: v1alpha1.ScheduledScalingActionSpec{
+			AutoscalingGroup: "asg",
+			Schedule:         "0 8 * * *",
+			MinNodes:         intPtr(3),
+			Duration:         7200,
+		},
+	}
+
+	targetOnly := &v1alpha1.ScheduledScalingAction{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+		},
+		Spec: v1alpha1.ScheduledScalingActionSpec{
+			AutoscalingGroup: "asg",
+			Schedule:         "30 9 * * *",
+			TargetNodeCount:  intPtr(5),
+		},
+	}
+
+	actions := []*v1alpha1.ScheduledScalingAction{override, targetOnly}
+
+	next, ok := nextBoundsChange("asg", actions, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Hour), next, "active override ends")
+
+	next, ok = nextBoundsChange("asg", actions, now.Add(2*time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(23*time.Hour), next, "next override starts")
+
+	_, ok = nextBoundsChange("other", actions, now)
+	assert.False(t, ok, "actions for other AutoscalingGroups ignored")
+
+	override.Spec.Suspended = true
+	_, ok = nextBoundsChange("asg", actions, now)
+	assert.False(t, ok, "suspended and target-only actions do not change bounds")
+}
@@ -5,6 +5,9 @@ const (
 	ScaleUpAlerted = "ScaleUpAlerted"
 	// ScaleDownAlerted event is created when an AutoscalingPolicy scale down alert occurs
 	ScaleDownAlerted = "ScaleDownAlerted"
+	// AlertSuppressed event is created when an AutoscalingPolicy alert is
+	// suppressed by the conflict resolution of its AutoscalingGroup
+	AlertSuppressed = "AlertSuppressed"
 
 	// ScaledUp event is created when an AutoscalingGroup is scaled up
 	ScaledUp = "ScaledUp"
@@ -16,4 +19,14 @@ const (
 
 	// ScaleError event is created when a scale event errors
 	ScaleError = "ScaleError"
+
+	// ScheduledActionTriggered event is created when a ScheduledScalingAction
+	// is triggered at a scheduled time
+	ScheduledActionTriggered = "ScheduledActionTriggered"
+	// ScheduledActionMissed event is created when a ScheduledScalingAction
+	// scheduled time is missed, e.g. because Cerebral was not running
+	ScheduledActionMissed = "ScheduledActionMissed"
+	// ScheduledActionError event is created when a ScheduledScalingAction
+	// fails to trigger
+	ScheduledActionError = "ScheduledActionError"
 )
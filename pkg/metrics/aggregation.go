@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// aggregations maps the supported aggregations to a function that reduces
+// values to a single value. These are the subset of Prometheus aggregation
+// operators that produce a single value, so that backends which aggregate
+// client-side behave the same as Prometheus does.
+var aggregations = map[string]func(values []float64) float64{
+	"sum":    sum,
+	"min":    min,
+	"max":    max,
+	"avg":    avg,
+	"stddev": stddev,
+	"stdvar": stdvar,
+	"count":  count,
+}
+
+// DefaultAggregation is the aggregation used when none is specified
+const DefaultAggregation = "avg"
+
+// Aggregations that require a parameter, e.g. topk(3, expr). These are the
+// remaining Prometheus aggregation operators.
+const (
+	AggregationCountValues = "count_values"
+	AggregationBottomK     = "bottomk"
+	AggregationTopK        = "topk"
+	AggregationQuantile    = "quantile"
+)
+
+// DefaultReductions are the reductions used to reduce the multiple values
+// returned by an aggregation to a single value unless another is configured
+var DefaultReductions = map[string]string{
+	AggregationBottomK: "avg",
+	AggregationTopK:    "avg",
+}
+
+var validLabelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// IsValidAggregation returns true if the named aggregation is supported
+func IsValidAggregation(aggregation string) bool {
+	_, ok := aggregations[aggregation]
+	return ok
+}
+
+// Aggregate applies the named aggregation (or the default if empty) to the
+// given values
+func Aggregate(aggregation string, values []float64) (float64, error) {
+	if aggregation == "" {
+		aggregation = DefaultAggregation
+	}
+
+	f, ok := aggregations[aggregation]
+	if !ok {
+		return 0, errors.Errorf("invalid aggregation %s", aggregation)
+	}
+
+	if len(values) == 0 {
+		return 0, errors.New("no values to aggregate")
+	}
+
+	return f(values), nil
+}
+
+// ValidateAggregationParameter returns an error if the parameter is invalid
+// for the named aggregation. Aggregations other than the parameterized ones
+// require the parameter to be empty.
+func ValidateAggregationParameter(aggregation, parameter string) error {
+	switch aggregation {
+	case AggregationTopK, AggregationBottomK:
+		k, err := strconv.Atoi(parameter)
+		if err != nil || k < 1 {
+			return errors.Errorf("aggregation %s requires a positive integer aggregationParameter but it is %q", aggregation, parameter)
+		}
+
+	case AggregationQuantile:
+		phi, err := strconv.ParseFloat(parameter, 64)
+		if err != nil || phi < 0 || phi > 1 {
+			return errors.Errorf("aggregation %s requires an aggregationParameter between 0 and 1 but it is %q", aggregation, parameter)
+		}
+
+	case AggregationCountValues:
+		// The label is meaningless for client-side aggregation but is
+		// required so that configurations are portable between backends
+		if !validLabelNameRegex.MatchString(parameter) {
+			return errors.Errorf("aggregation %s requires a label name aggregationParameter but it is %q", aggregation, parameter)
+		}
+
+	default:
+		if parameter != "" {
+			return errors.Errorf("aggregation %s does not take an aggregationParameter", aggregation)
+		}
+	}
+
+	return nil
+}
+
+// AggregateWithParameter applies the named aggregation (or the default if
+// empty) with the given parameter to the given values, supporting the
+// parameterized aggregations in addition to those supported by Aggregate.
+// If the aggregation results in multiple values, e.g. for topk, they are
+// reduced to a single value using the named reduction. If the reduction is
+// empty, such results are an error.
+func AggregateWithParameter(aggregation, parameter, reduction string, values []float64) (float64, error) {
+	if aggregation == "" {
+		aggregation = DefaultAggregation
+	}
+
+	if err := ValidateAggregationParameter(aggregation, parameter); err != nil {
+		return 0, err
+	}
+
+	if len(values) == 0 {
+		return 0, errors.New("no values to aggregate")
+	}
+
+	var results []float64
+	switch aggregation {
+	case AggregationTopK, AggregationBottomK:
+		k, _ := strconv.Atoi(parameter)
+		results = sortedCopy(values)
+		if aggregation == AggregationTopK {
+			sort.Sort(sort.Reverse(sort.Float64Slice(results)))
+		}
+
+		if k < len(results) {
+			results = results[:k]
+		}
+
+	case AggregationQuantile:
+		phi, _ := strconv.ParseFloat(parameter, 64)
+		return quantile(phi, values), nil
+
+	case AggregationCountValues:
+		results = countValues(values)
+
+	default:
+		return Aggregate(aggregation, values)
+	}
+
+	if len(results) == 1 {
+		return results[0], nil
+	}
+
+	if reduction == "" {
+		return 0, errors.Errorf("aggregation %s resulted in %d values and no reduction is configured", aggregation, len(results))
+	}
+
+	return Aggregate(reduction, results)
+}
+
+func sortedCopy(values []float64) []float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// quantile returns the φ-quantile of the values, interpolating linearly
+// between the nearest values as Prometheus does
+func quantile(phi float64, values []float64) float64 {
+	sorted := sortedCopy(values)
+
+	rank := phi * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := lower + 1
+	if upper > len(sorted)-1 {
+		upper = len(sorted) - 1
+	}
+
+	weight := rank - math.Floor(rank)
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
+// countValues returns the number of occurrences of each distinct value,
+// ordered by value
+func countValues(values []float64) []float64 {
+	counts := make(map[float64]float64)
+	for _, v := range values {
+		counts[v]++
+	}
+
+	var distinct []float64
+	for v := range counts {
+		distinct = append(distinct, v)
+	}
+	sort.Float64s(distinct)
+
+	var results []float64
+	for _, v := range distinct {
+		results = append(results, counts[v])
+	}
+
+	return results
+}
+
+func sum(values []float64) float64 {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+
+	return s
+}
+
+func min(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = math.Min(m, v)
+	}
+
+	return m
+}
+
+func max(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = math.Max(m, v)
+	}
+
+	return m
+}
+
+func avg(values []float64) float64 {
+	return sum(values) / float64(len(values))
+}
+
+// stdvar is the population variance, as in Prometheus
+func stdvar(values []float64) float64 {
+	mean := avg(values)
+
+	var s float64
+	for _, v := range values {
+		s += (v - mean) * (v - mean)
+	}
+
+	return s / float64(len(values))
+}
+
+// stddev is the population standard deviation, as in Prometheus
+func stddev(values []float64) float64 {
+	return math.Sqrt(stdvar(values))
+}
+
+func count(values []float64) float64 {
+	return float64(len(values))
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	values := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	_, err := Aggregate("quantile", values)
+	assert.Error(t, err, "unsupported aggregation")
+
+	_, err = Aggregate("avg", nil)
+	assert.Error(t, err, "no values")
+
+	val, err := Aggregate("", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5), val, "default is avg")
+
+	expected := map[string]float64{
+		"sum":    40,
+		"min":    2,
+		"max":    9,
+		"avg":    5,
+		"stdvar": 4,
+		"stddev": 2,
+		"count":  8,
+	}
+
+	for aggregation, e := range expected {
+		val, err := Aggregate(aggregation, values)
+		assert.NoError(t, err, aggregation)
+		assert.True(t, math.Abs(e-val) < 1e-9, "%s: expected %f, got %f", aggregation, e, val)
+	}
+}
+
+func TestIsValidAggregation(t *testing.T) {
+	assert.True(t, IsValidAggregation("avg"))
+	assert.True(t, IsValidAggregation("stddev"))
+	assert.False(t, IsValidAggregation("topk"), "parameterized aggregations are not supported")
+	assert.False(t, IsValidAggregation(""), "empty is not valid")
+}
+
+func TestValidateAggregationParameter(t *testing.T) {
+	assert.NoError(t, ValidateAggregationParameter("avg", ""))
+	assert.Error(t, ValidateAggregationParameter("avg", "3"), "non-parameterized aggregation with parameter")
+
+	assert.NoError(t, ValidateAggregationParameter(AggregationTopK, "3"))
+	assert.Error(t, ValidateAggregationParameter(AggregationTopK, "0"), "k must be positive")
+	assert.Error(t, ValidateAggregationParameter(AggregationBottomK, ""), "k is required")
+
+	assert.NoError(t, ValidateAggregationParameter(AggregationQuantile, "0.9"))
+	assert.Error(t, ValidateAggregationParameter(AggregationQuantile, "1.5"), "phi must be between 0 and 1")
+
+	assert.NoError(t, ValidateAggregationParameter(AggregationCountValues, "value"))
+	assert.Error(t, ValidateAggregationParameter(AggregationCountValues, "not a label"), "invalid label name")
+}
+
+func TestAggregateWithParameter(t *testing.T) {
+	values := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	val, err := AggregateWithParameter("", "", "", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5), val, "default is avg")
+
+	val, err = AggregateWithParameter("max", "", "", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9), val, "non-parameterized aggregation")
+
+	_, err = AggregateWithParameter(AggregationTopK, "", "avg", values)
+	assert.Error(t, err, "invalid parameter")
+
+	_, err = AggregateWithParameter(AggregationTopK, "2", "avg", nil)
+	assert.Error(t, err, "no values")
+
+	val, err = AggregateWithParameter(AggregationTopK, "2", "avg", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(8), val, "topk reduced")
+
+	val, err = AggregateWithParameter(AggregationBottomK, "3", "sum", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), val, "bottomk reduced")
+
+	val, err = AggregateWithParameter(AggregationTopK, "1", "", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9), val, "single value needs no reduction")
+
+	val, err = AggregateWithParameter(AggregationTopK, "20", "count", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(8), val, "k larger than number of values")
+
+	_, err = AggregateWithParameter(AggregationTopK, "2", "", values)
+	assert.Error(t, err, "multiple values without reduction")
+
+	val, err = AggregateWithParameter(AggregationQuantile, "0.5", "", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4.5), val, "median interpolated")
+
+	val, err = AggregateWithParameter(AggregationQuantile, "1", "", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9), val, "quantile 1 is max")
+
+	val, err = AggregateWithParameter(AggregationCountValues, "value", "max", values)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), val, "most common value count")
+
+	assert.Equal(t, []float64{2, 4, 4, 4, 5, 5, 7, 9}, values, "values not modified")
+}
@@ -7,3 +7,11 @@ type Backend interface {
 	// at this point in time.
 	GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error)
 }
+
+// A StatusReporter is a Backend that reports backend-specific details, such as
+// values it has detected, to be shown in the status of its MetricsBackend.
+type StatusReporter interface {
+	// Status returns the details to report. It must not block on the
+	// backend itself.
+	Status() map[string]string
+}
@@ -0,0 +1,197 @@
+package custommetrics
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	custommetricsclient "k8s.io/metrics/pkg/client/custom_metrics"
+	externalmetricsclient "k8s.io/metrics/pkg/client/external_metrics"
+
+	"github.com/containership/cerebral/pkg/metrics"
+)
+
+const (
+	// apiCustom selects the custom.metrics.k8s.io API
+	apiCustom = "custom"
+	// apiExternal selects the external.metrics.k8s.io API
+	apiExternal = "external"
+)
+
+const defaultExternalNamespace = "default"
+
+// Backend implements a metrics backend that consumes the aggregated
+// custom.metrics.k8s.io and external.metrics.k8s.io APIs, as served by
+// adapters such as prometheus-adapter or KEDA. The metric requested is the
+// name of the metric in the API. These metrics describe Kubernetes objects
+// or external systems rather than nodes, so the node selector is ignored.
+type Backend struct {
+	customclient   custommetricsclient.CustomMetricsClient
+	externalclient externalmetricsclient.ExternalMetricsClient
+}
+
+// metricConfiguration describes which metric values to get
+type metricConfiguration struct {
+	// API is either custom (default) or external
+	API string `json:"api"`
+
+	// -- Generic
+	Namespace      string `json:"namespace"`
+	MetricSelector string `json:"metricSelector"`
+	Aggregation    string `json:"aggregation"`
+
+	// -- Custom only
+	// Group and Kind describe the object(s) that the metric describes, e.g.
+	// Kind=Service or Group=apps,Kind=Deployment
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	// Exactly one of Name or Selector must be specified
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+}
+
+// NewClient returns a new client for talking to the custom and external
+// metrics APIs, or an error
+func NewClient(customclient custommetricsclient.CustomMetricsClient, externalclient externalmetricsclient.ExternalMetricsClient) (metrics.Backend, error) {
+	if customclient == nil {
+		return nil, errors.New("custom metrics client must be provided")
+	}
+
+	if externalclient == nil {
+		return nil, errors.New("external metrics client must be provided")
+	}
+
+	return Backend{
+		customclient:   customclient,
+		externalclient: externalclient,
+	}, nil
+}
+
+// GetValue implements the metrics.Backend interface
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	config := metricConfiguration{}
+	if err := config.defaultAndValidate(configuration); err != nil {
+		return 0, errors.Wrap(err, "validating configuration")
+	}
+
+	// Already validated above
+	metricSelector, _ := labels.Parse(config.MetricSelector)
+
+	var values []float64
+	var err error
+	switch config.API {
+	case apiCustom:
+		values, err = b.getCustomMetricValues(metric, metricSelector, config)
+
+	case apiExternal:
+		values, err = b.getExternalMetricValues(metric, metricSelector, config)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting %s metric %s", config.API, metric)
+	}
+
+	return metrics.Aggregate(config.Aggregation, values)
+}
+
+func (b Backend) getCustomMetricValues(metric string, metricSelector labels.Selector, config metricConfiguration) ([]float64, error) {
+	groupKind := schema.GroupKind{
+		Group: config.Group,
+		Kind:  config.Kind,
+	}
+
+	var getter custommetricsclient.MetricsInterface
+	if config.Namespace == "" {
+		getter = b.customclient.RootScopedMetrics()
+	} else {
+		getter = b.customclient.NamespacedMetrics(config.Namespace)
+	}
+
+	if config.Name != "" {
+		value, err := getter.GetForObject(groupKind, config.Name, metric, metricSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		return []float64{quantityToFloat(value.Value)}, nil
+	}
+
+	// Already validated
+	selector, _ := labels.Parse(config.Selector)
+	list, err := getter.GetForObjects(groupKind, selector, metric, metricSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	for _, item := range list.Items {
+		values = append(values, quantityToFloat(item.Value))
+	}
+
+	return values, nil
+}
+
+func (b Backend) getExternalMetricValues(metric string, metricSelector labels.Selector, config metricConfiguration) ([]float64, error) {
+	list, err := b.externalclient.NamespacedMetrics(config.Namespace).List(metric, metricSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	for _, item := range list.Items {
+		values = append(values, quantityToFloat(item.Value))
+	}
+
+	return values, nil
+}
+
+// defaults and validates the metricConfiguration. Intended to be called with an
+// empty struct that we'll fill in here using the caller-provided configuration.
+func (c *metricConfiguration) defaultAndValidate(configuration map[string]string) error {
+	// Round trip the config through JSON parser to populate our struct
+	j, _ := json.Marshal(configuration)
+	json.Unmarshal(j, c)
+
+	if _, err := labels.Parse(c.MetricSelector); err != nil {
+		return errors.Wrap(err, "parsing metricSelector")
+	}
+
+	switch c.API {
+	case "", apiCustom:
+		c.API = apiCustom
+		return c.validateCustom()
+
+	case apiExternal:
+		if c.Namespace == "" {
+			c.Namespace = defaultExternalNamespace
+		}
+
+		return nil
+
+	default:
+		return errors.Errorf("invalid api %q (must be %s or %s)", c.API, apiCustom, apiExternal)
+	}
+}
+
+func (c *metricConfiguration) validateCustom() error {
+	if c.Kind == "" {
+		return errors.New("kind must be provided for custom metrics")
+	}
+
+	if (c.Name == "") == (c.Selector == "") {
+		return errors.New("exactly one of name or selector must be provided for custom metrics")
+	}
+
+	if _, err := labels.Parse(c.Selector); err != nil {
+		return errors.Wrap(err, "parsing selector")
+	}
+
+	return nil
+}
+
+// quantityToFloat converts a quantity to a float, preserving milli-units
+func quantityToFloat(q resource.Quantity) float64 {
+	return float64(q.MilliValue()) / 1000
+}
@@ -0,0 +1,211 @@
+package custommetrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	custommetricsv1beta2 "k8s.io/metrics/pkg/apis/custom_metrics/v1beta2"
+	externalmetricsv1beta1 "k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+	custommetricsclient "k8s.io/metrics/pkg/client/custom_metrics"
+	externalmetricsclient "k8s.io/metrics/pkg/client/external_metrics"
+)
+
+// customMetricsStub records the last request and returns the given values
+type customMetricsStub struct {
+	namespace string
+	groupKind schema.GroupKind
+	name      string
+	metric    string
+	selector  string
+
+	values []string
+	err    error
+}
+
+func (c *customMetricsStub) RootScopedMetrics() custommetricsclient.MetricsInterface {
+	c.namespace = ""
+	return c
+}
+
+func (c *customMetricsStub) NamespacedMetrics(namespace string) custommetricsclient.MetricsInterface {
+	c.namespace = namespace
+	return c
+}
+
+func (c *customMetricsStub) GetForObject(groupKind schema.GroupKind, name string, metricName string, metricSelector labels.Selector) (*custommetricsv1beta2.MetricValue, error) {
+	c.groupKind = groupKind
+	c.name = name
+	c.metric = metricName
+
+	list, err := c.GetForObjects(groupKind, labels.Everything(), metricName, metricSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list.Items[0], nil
+}
+
+func (c *customMetricsStub) GetForObjects(groupKind schema.GroupKind, selector labels.Selector, metricName string, metricSelector labels.Selector) (*custommetricsv1beta2.MetricValueList, error) {
+	c.groupKind = groupKind
+	c.selector = selector.String()
+	c.metric = metricName
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	list := &custommetricsv1beta2.MetricValueList{}
+	for _, v := range c.values {
+		list.Items = append(list.Items, custommetricsv1beta2.MetricValue{
+			Value: resource.MustParse(v),
+		})
+	}
+
+	return list, nil
+}
+
+// externalMetricsStub records the last request and returns the given values
+type externalMetricsStub struct {
+	namespace      string
+	metric         string
+	metricSelector string
+
+	values []string
+	err    error
+}
+
+func (e *externalMetricsStub) NamespacedMetrics(namespace string) externalmetricsclient.MetricsInterface {
+	e.namespace = namespace
+	return e
+}
+
+func (e *externalMetricsStub) List(metricName string, metricSelector labels.Selector) (*externalmetricsv1beta1.ExternalMetricValueList, error) {
+	e.metric = metricName
+	e.metricSelector = metricSelector.String()
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	list := &externalmetricsv1beta1.ExternalMetricValueList{}
+	for _, v := range e.values {
+		list.Items = append(list.Items, externalmetricsv1beta1.ExternalMetricValue{
+			Value: resource.MustParse(v),
+		})
+	}
+
+	return list, nil
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(&customMetricsStub{}, &externalMetricsStub{})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = NewClient(nil, &externalMetricsStub{})
+	assert.Error(t, err, "error on nil custom metrics client")
+
+	_, err = NewClient(&customMetricsStub{}, nil)
+	assert.Error(t, err, "error on nil external metrics client")
+}
+
+func TestGetValueCustom(t *testing.T) {
+	custom := &customMetricsStub{
+		values: []string{"500m"},
+	}
+	backend := Backend{
+		customclient:   custom,
+		externalclient: &externalMetricsStub{},
+	}
+
+	val, err := backend.GetValue("requests_per_second", map[string]string{
+		"kind":      "Service",
+		"namespace": "web",
+		"name":      "frontend",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, val, "single object value")
+	assert.Equal(t, "web", custom.namespace)
+	assert.Equal(t, "frontend", custom.name)
+	assert.Equal(t, "requests_per_second", custom.metric)
+	assert.Equal(t, schema.GroupKind{Kind: "Service"}, custom.groupKind)
+
+	custom.values = []string{"1", "2", "6"}
+	val, err = backend.GetValue("queue_depth", map[string]string{
+		"group":       "apps",
+		"kind":        "Deployment",
+		"selector":    "app=worker",
+		"aggregation": "sum",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(9), val, "selector values are aggregated")
+	assert.Equal(t, "", custom.namespace, "no namespace is root scoped")
+	assert.Equal(t, "app=worker", custom.selector)
+
+	_, err = backend.GetValue("queue_depth", map[string]string{
+		"kind": "Deployment",
+	}, nil)
+	assert.Error(t, err, "name or selector is required")
+
+	_, err = backend.GetValue("queue_depth", map[string]string{
+		"kind":     "Deployment",
+		"name":     "worker",
+		"selector": "app=worker",
+	}, nil)
+	assert.Error(t, err, "name and selector are exclusive")
+
+	_, err = backend.GetValue("queue_depth", map[string]string{
+		"name": "worker",
+	}, nil)
+	assert.Error(t, err, "kind is required")
+
+	custom.err = errors.New("adapter unavailable")
+	_, err = backend.GetValue("queue_depth", map[string]string{
+		"kind": "Deployment",
+		"name": "worker",
+	}, nil)
+	assert.Error(t, err, "API errors are propagated")
+}
+
+func TestGetValueExternal(t *testing.T) {
+	external := &externalMetricsStub{
+		values: []string{"10", "30"},
+	}
+	backend := Backend{
+		customclient:   &customMetricsStub{},
+		externalclient: external,
+	}
+
+	val, err := backend.GetValue("sqs_messages_visible", map[string]string{
+		"api":            "external",
+		"metricSelector": "queue=jobs",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20), val, "external values default to avg")
+	assert.Equal(t, "default", external.namespace, "namespace defaults")
+	assert.Equal(t, "queue=jobs", external.metricSelector)
+
+	external.values = nil
+	_, err = backend.GetValue("sqs_messages_visible", map[string]string{
+		"api":       "external",
+		"namespace": "jobs",
+	}, nil)
+	assert.Error(t, err, "no values is an error")
+	assert.Equal(t, "jobs", external.namespace)
+
+	_, err = backend.GetValue("sqs_messages_visible", map[string]string{
+		"api":            "external",
+		"metricSelector": "queue in (",
+	}, nil)
+	assert.Error(t, err, "invalid metric selector")
+
+	_, err = backend.GetValue("sqs_messages_visible", map[string]string{
+		"api": "not-an-api",
+	}, nil)
+	assert.Error(t, err, "invalid api")
+}
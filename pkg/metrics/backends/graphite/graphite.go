@@ -0,0 +1,262 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
+	"github.com/containership/cluster-manager/pkg/log"
+)
+
+// Metric is a metric exposed by this backend
+type Metric int
+
+const (
+	// MetricCustom is used to render a custom Graphite target
+	MetricCustom Metric = iota
+)
+
+// String is a stringer for Metric
+func (m Metric) String() string {
+	switch m {
+	case MetricCustom:
+		return "custom"
+	}
+
+	return "unknown"
+}
+
+// Backend implements a metrics backend for the Graphite render API. Nodes
+// accessed via the lister must not be mutated.
+type Backend struct {
+	address *url.URL
+	// hostNodeLabel is the node label holding the host name used in Graphite
+	// paths. If empty, the node name is used.
+	hostNodeLabel string
+
+	client *http.Client
+
+	nodeLister corelistersv1.NodeLister
+}
+
+// See https://graphite.readthedocs.io/en/latest/render_api.html#from-until
+var validRangeRegex = regexp.MustCompile(`^\d+(s|min|h|d|w|mon|y)$`)
+
+const defaultRange = "5min"
+
+const defaultTimeReduction = "avg"
+
+const requestTimeout = 10 * time.Second
+
+// targetTemplateData is available to target templates
+type targetTemplateData struct {
+	// Hosts are the host names of the selected nodes with dots replaced by
+	// underscores, as is the convention for Graphite paths
+	Hosts []string
+	// HostsGlob is a Graphite glob matching any of the Hosts, e.g. {a,b}
+	HostsGlob string
+}
+
+// renderResponse is the response of the render API with format=json
+type renderResponse []struct {
+	Target string `json:"target"`
+	// Each datapoint is a [value, timestamp] pair where value may be null
+	Datapoints [][2]*float64 `json:"datapoints"`
+}
+
+// NewClient returns a new client for talking to a Graphite Backend described
+// by the given MetricsBackend configuration, or an error
+func NewClient(configuration map[string]string, nodeLister corelistersv1.NodeLister) (metrics.Backend, error) {
+	address := configuration["address"]
+	if address == "" {
+		return nil, errors.New("address must not be empty")
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing address")
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	return Backend{
+		address:       u,
+		hostNodeLabel: configuration["hostNodeLabel"],
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+		nodeLister: nodeLister,
+	}, nil
+}
+
+// GetValue implements the metrics.Backend interface. The custom metric
+// requires a `target` key in the configuration, which is a template for the
+// Graphite target expression. The non-null datapoints of each returned series
+// over the `range` are reduced to a single value using the `timeReduction`,
+// and then the values of all series are reduced using the `aggregation`.
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	if metric != MetricCustom.String() {
+		return 0, errors.Errorf("unknown metric %q", metric)
+	}
+
+	targetTemplate, ok := configuration["target"]
+	if !ok {
+		return 0, errors.New("configuration key \"target\" must be provided for a custom metric")
+	}
+
+	timeRange := configuration["range"]
+	if timeRange == "" {
+		timeRange = defaultRange
+	}
+
+	if !validRangeRegex.MatchString(timeRange) {
+		return 0, errors.Errorf("invalid range %s", timeRange)
+	}
+
+	timeReduction := configuration["timeReduction"]
+	if timeReduction == "" {
+		timeReduction = defaultTimeReduction
+	}
+
+	if !metrics.IsValidAggregation(timeReduction) {
+		return 0, errors.Errorf("invalid time reduction %s", timeReduction)
+	}
+
+	selector := nodeutil.GetNodesLabelSelector(nodeSelector)
+	nodes, err := b.nodeLister.List(selector)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing nodes")
+	}
+
+	hosts, err := b.getHosts(nodes)
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting hosts for metric %s", metric)
+	}
+
+	target, err := buildTarget(targetTemplate, hosts)
+	if err != nil {
+		return 0, err
+	}
+
+	series, err := b.render(target, timeRange)
+	if err != nil {
+		return 0, err
+	}
+
+	var values []float64
+	for _, datapoints := range series {
+		// Series with only null datapoints have no value
+		if len(datapoints) == 0 {
+			continue
+		}
+
+		v, err := metrics.Aggregate(timeReduction, datapoints)
+		if err != nil {
+			return 0, err
+		}
+
+		values = append(values, v)
+	}
+
+	return metrics.Aggregate(configuration["aggregation"], values)
+}
+
+// getHosts returns the Graphite host name for each of the given nodes
+func (b Backend) getHosts(nodes []*corev1.Node) ([]string, error) {
+	var hosts []string
+	for _, node := range nodes {
+		host := node.Name
+		if b.hostNodeLabel != "" {
+			var ok bool
+			host, ok = node.Labels[b.hostNodeLabel]
+			if !ok {
+				return nil, errors.Errorf("node %s is missing host label %s", node.Name, b.hostNodeLabel)
+			}
+		}
+
+		hosts = append(hosts, strings.Replace(host, ".", "_", -1))
+	}
+
+	return hosts, nil
+}
+
+// render requests the target from the render API and returns the non-null
+// datapoint values of each series
+func (b Backend) render(target, timeRange string) ([][]float64, error) {
+	log.Debugf("Rendering graphite target: %s", target)
+
+	params := url.Values{}
+	params.Set("target", target)
+	params.Set("from", "-"+timeRange)
+	params.Set("format", "json")
+
+	u := *b.address
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/render"
+	u.RawQuery = params.Encode()
+
+	resp, err := b.client.Get(u.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "rendering graphite target %q", target)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %d rendering graphite target %q: %s",
+			resp.StatusCode, target, strings.TrimSpace(string(body)))
+	}
+
+	var series renderResponse
+	if err := json.Unmarshal(body, &series); err != nil {
+		return nil, errors.Wrap(err, "decoding graphite response")
+	}
+
+	values := make([][]float64, len(series))
+	for i, s := range series {
+		for _, dp := range s.Datapoints {
+			if dp[0] != nil {
+				values[i] = append(values[i], *dp[0])
+			}
+		}
+	}
+
+	return values, nil
+}
+
+func buildTarget(targetTemplate string, hosts []string) (string, error) {
+	tmpl, err := template.New("target").Parse(targetTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing target template")
+	}
+
+	data := targetTemplateData{
+		Hosts:     hosts,
+		HostsGlob: "{" + strings.Join(hosts, ",") + "}",
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", errors.Wrap(err, "executing target template")
+	}
+
+	return out.String(), nil
+}
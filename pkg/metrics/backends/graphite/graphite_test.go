@@ -0,0 +1,215 @@
+package graphite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+)
+
+var (
+	node0 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-0.example.com",
+			Labels: map[string]string{
+				"pool":     "a",
+				"hostname": "host-0",
+			},
+		},
+	}
+	node1 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1.example.com",
+			Labels: map[string]string{
+				"pool":     "a",
+				"hostname": "host-1",
+			},
+		},
+	}
+)
+
+const renderResponseBody = `[
+	{"target": "servers.node-0_example_com.cpu.usage", "datapoints": [[10, 1546300800], [null, 1546300860], [30, 1546300920]]},
+	{"target": "servers.node-1_example_com.cpu.usage", "datapoints": [[50, 1546300800]]}
+]`
+
+func newTestServer(status int, body string, lastQuery *url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/render" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		*lastQuery = r.URL.Query()
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(map[string]string{
+		"address": "http://graphite:8080",
+	}, corelistersv1.NewNodeLister(nil))
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = NewClient(map[string]string{}, corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on empty address")
+
+	_, err = NewClient(map[string]string{
+		"address": "http://graphite:8080",
+	}, nil)
+	assert.Error(t, err, "error on nil NodeLister")
+}
+
+func TestGetValue(t *testing.T) {
+	var lastQuery url.Values
+	server := newTestServer(http.StatusOK, renderResponseBody, &lastQuery)
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"address": server.URL,
+	}, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("cpu", map[string]string{}, nil)
+	assert.Error(t, err, "unknown metric requested")
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{}, nil)
+	assert.Error(t, err, "target is required")
+
+	val, err := backend.GetValue(MetricCustom.String(), map[string]string{
+		"target": "servers.{{.HostsGlob}}.cpu.usage",
+	}, map[string]string{"pool": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(35), val, "non-null datapoints of each series then series default to avg")
+	assert.Equal(t, "servers.{node-0_example_com,node-1_example_com}.cpu.usage", lastQuery.Get("target"))
+	assert.Equal(t, "-5min", lastQuery.Get("from"), "range defaults")
+	assert.Equal(t, "json", lastQuery.Get("format"))
+
+	val, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target":      "servers.{{.HostsGlob}}.cpu.usage",
+		"aggregation": "max",
+		"range":       "1h",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(50), val, "max aggregation")
+	assert.Equal(t, "-1h", lastQuery.Get("from"))
+
+	val, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target":      "servers.{{.HostsGlob}}.cpu.usage",
+		"aggregation": "count",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), val, "count aggregation counts series")
+
+	val, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target":        "servers.{{.HostsGlob}}.cpu.usage",
+		"timeReduction": "max",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(40), val, "time reduction applied to each series")
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target":        "servers.{{.HostsGlob}}.cpu.usage",
+		"timeReduction": "median",
+	}, nil)
+	assert.Error(t, err, "invalid time reduction")
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target": "servers.*.cpu.usage",
+		"range":  "1m",
+	}, nil)
+	assert.Error(t, err, "invalid range")
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target": "servers.{{.Missing}}.cpu.usage",
+	}, nil)
+	assert.Error(t, err, "invalid template")
+}
+
+func TestGetValueErrors(t *testing.T) {
+	var lastQuery url.Values
+	server := newTestServer(http.StatusBadRequest, "invalid target", &lastQuery)
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"address": server.URL,
+	}, buildNodeLister([]corev1.Node{node0}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target": "sumSeries(",
+	}, nil)
+	assert.Error(t, err, "non-200 status")
+
+	emptyServer := newTestServer(http.StatusOK, `[]`, &lastQuery)
+	defer emptyServer.Close()
+
+	backend, err = NewClient(map[string]string{
+		"address": emptyServer.URL,
+	}, buildNodeLister([]corev1.Node{node0}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"target": "servers.{{.HostsGlob}}.cpu.usage",
+	}, nil)
+	assert.Error(t, err, "no datapoints")
+}
+
+func TestGetHosts(t *testing.T) {
+	backend := Backend{}
+	hosts, err := backend.getHosts([]*corev1.Node{&node0, &node1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-0_example_com", "node-1_example_com"}, hosts, "dots are replaced")
+
+	backend.hostNodeLabel = "hostname"
+	hosts, err = backend.getHosts([]*corev1.Node{&node0, &node1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host-0", "host-1"}, hosts, "node label if configured")
+
+	backend.hostNodeLabel = "missing"
+	_, err = backend.getHosts([]*corev1.Node{&node0})
+	assert.Error(t, err, "missing host label")
+}
+
+func TestBuildTarget(t *testing.T) {
+	target, err := buildTarget("averageSeries(servers.{{.HostsGlob}}.load)", []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "averageSeries(servers.{a,b}.load)", target)
+
+	target, err = buildTarget(`{{range $i, $h := .Hosts}}{{if $i}},{{end}}servers.{{$h}}.load{{end}}`, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "servers.a.load,servers.b.load", target)
+
+	_, err = buildTarget("{{", nil)
+	assert.Error(t, err, "invalid template")
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
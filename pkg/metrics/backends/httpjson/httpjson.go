@@ -0,0 +1,452 @@
+package httpjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
+	"github.com/containership/cerebral/pkg/secretutil"
+	"github.com/containership/cluster-manager/pkg/log"
+)
+
+// Backend implements a generic metrics backend for any HTTP endpoint that
+// returns JSON. The request is built from templates and the value is
+// extracted from the response using a JSONPath expression. Nodes accessed via
+// the lister must not be mutated.
+type Backend struct {
+	// defaults holds request settings from the MetricsBackend configuration,
+	// which may be overridden by the metric configuration
+	defaults requestConfiguration
+
+	// Credentials are only sent to the url configured on the MetricsBackend.
+	// At most one of the following is set.
+	bearerTokenSecret    *secretutil.Reference
+	bearerTokenSecretKey string
+	basicAuthSecret      *secretutil.Reference
+
+	client *http.Client
+
+	nodeLister corelistersv1.NodeLister
+}
+
+// requestConfiguration describes the request to make. All fields other than
+// JSONPath are templates.
+type requestConfiguration struct {
+	URL      string
+	Method   string
+	Body     string
+	Headers  map[string]string
+	JSONPath string
+}
+
+// templateData is available to the URL, header and body templates
+type templateData struct {
+	Metric       string
+	NodeNames    []string
+	NodeIPs      []string
+	NodeSelector map[string]string
+}
+
+// Configuration keys shared by MetricsBackend and metric configurations
+const (
+	configKeyURL      = "url"
+	configKeyMethod   = "method"
+	configKeyBody     = "body"
+	configKeyJSONPath = "jsonPath"
+	// Each header is configured as a separate key with this prefix, e.g.
+	// header.Authorization
+	configKeyHeaderPrefix = "header."
+)
+
+const defaultTimeout = 10 * time.Second
+
+const (
+	defaultBearerTokenSecretKey = "token"
+
+	// Keys of the basic auth Secret data, matching kubernetes.io/basic-auth
+	// Secrets
+	basicAuthUsernameKey = "username"
+	basicAuthPasswordKey = "password"
+)
+
+// templateFuncs are available to all templates in addition to the built-in
+// functions such as urlquery
+var templateFuncs = template.FuncMap{
+	"join":   strings.Join,
+	"toJSON": toJSON,
+}
+
+// NewClient returns a new client for an HTTP JSON Backend described by the
+// given MetricsBackend configuration, or an error. Any credentials Secrets are
+// read using the given clientset.
+func NewClient(configuration map[string]string, kubeclientset kubernetes.Interface, nodeLister corelistersv1.NodeLister) (metrics.Backend, error) {
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	timeout := defaultTimeout
+	if s, ok := configuration["timeoutSeconds"]; ok {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds <= 0 {
+			return nil, errors.Errorf("timeoutSeconds must be a positive integer but is %q", s)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	b := Backend{
+		defaults: requestConfigurationFromMap(configuration),
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		nodeLister: nodeLister,
+	}
+
+	if name := configuration["bearerTokenSecretName"]; name != "" {
+		if configuration["basicAuthSecretName"] != "" {
+			return nil, errors.New("at most one of bearerTokenSecretName or basicAuthSecretName may be provided")
+		}
+
+		b.bearerTokenSecretKey = configuration["bearerTokenSecretKey"]
+		if b.bearerTokenSecretKey == "" {
+			b.bearerTokenSecretKey = defaultBearerTokenSecretKey
+		}
+
+		ref, err := secretutil.NewReference(kubeclientset, configuration["bearerTokenSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := ref.Value(b.bearerTokenSecretKey); err != nil {
+			return nil, err
+		}
+
+		b.bearerTokenSecret = ref
+	}
+
+	if name := configuration["basicAuthSecretName"]; name != "" {
+		ref, err := secretutil.NewReference(kubeclientset, configuration["basicAuthSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := ref.Value(basicAuthUsernameKey); err != nil {
+			return nil, err
+		}
+
+		b.basicAuthSecret = ref
+	}
+
+	return b, nil
+}
+
+// GetValue implements the metrics.Backend interface
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	overrides := requestConfigurationFromMap(configuration)
+	config := b.defaults.merge(overrides)
+	if err := config.validate(); err != nil {
+		return 0, errors.Wrap(err, "validating configuration")
+	}
+
+	selector := nodeutil.GetNodesLabelSelector(nodeSelector)
+	nodes, err := b.nodeLister.List(selector)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing nodes")
+	}
+
+	// Nodes are listed in no particular order, so sort them to keep requests
+	// stable across polls
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	data := templateData{
+		Metric:       metric,
+		NodeSelector: nodeSelector,
+	}
+	for _, node := range nodes {
+		data.NodeNames = append(data.NodeNames, node.Name)
+		data.NodeIPs = append(data.NodeIPs, nodeIP(node))
+	}
+
+	req, err := config.buildRequest(data)
+	if err != nil {
+		return 0, errors.Wrapf(err, "building request for metric %s", metric)
+	}
+
+	if overrides.URL == "" {
+		if err := b.authenticate(req); err != nil {
+			return 0, errors.Wrapf(err, "authenticating request for metric %s", metric)
+		}
+	}
+
+	log.Debugf("Performing HTTP metrics request: %s %s", req.Method, req.URL)
+
+	body, err := b.do(req)
+	if err != nil {
+		return 0, errors.Wrapf(err, "requesting metric %s", metric)
+	}
+
+	return extractValue(body, config.JSONPath)
+}
+
+func requestConfigurationFromMap(configuration map[string]string) requestConfiguration {
+	c := requestConfiguration{
+		URL:      configuration[configKeyURL],
+		Method:   configuration[configKeyMethod],
+		Body:     configuration[configKeyBody],
+		JSONPath: configuration[configKeyJSONPath],
+		Headers:  make(map[string]string),
+	}
+
+	for k, v := range configuration {
+		if strings.HasPrefix(k, configKeyHeaderPrefix) {
+			c.Headers[strings.TrimPrefix(k, configKeyHeaderPrefix)] = v
+		}
+	}
+
+	return c
+}
+
+// merge returns a copy of the configuration with any fields set in overrides
+// replaced. Headers are only inherited if the URL isn't overridden, since
+// they may contain credentials meant only for the configured URL.
+func (c requestConfiguration) merge(overrides requestConfiguration) requestConfiguration {
+	merged := requestConfiguration{
+		URL:      c.URL,
+		Method:   c.Method,
+		Body:     c.Body,
+		JSONPath: c.JSONPath,
+		Headers:  make(map[string]string),
+	}
+
+	if overrides.URL != "" {
+		merged.URL = overrides.URL
+	}
+
+	if overrides.Method != "" {
+		merged.Method = overrides.Method
+	}
+
+	if overrides.Body != "" {
+		merged.Body = overrides.Body
+	}
+
+	if overrides.JSONPath != "" {
+		merged.JSONPath = overrides.JSONPath
+	}
+
+	if overrides.URL == "" {
+		for k, v := range c.Headers {
+			merged.Headers[k] = v
+		}
+	}
+
+	for k, v := range overrides.Headers {
+		merged.Headers[k] = v
+	}
+
+	return merged
+}
+
+func (c *requestConfiguration) validate() error {
+	if c.URL == "" {
+		return errors.New("url must be provided")
+	}
+
+	if c.JSONPath == "" {
+		return errors.New("jsonPath must be provided")
+	}
+
+	switch strings.ToUpper(c.Method) {
+	case "":
+		c.Method = http.MethodGet
+	case http.MethodGet, http.MethodPost:
+		c.Method = strings.ToUpper(c.Method)
+	default:
+		return errors.Errorf("invalid method %q (must be GET or POST)", c.Method)
+	}
+
+	return nil
+}
+
+// buildRequest renders the templates using the given data and returns the
+// resulting request
+func (c requestConfiguration) buildRequest(data templateData) (*http.Request, error) {
+	url, err := render("url", c.URL, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if c.Body != "" {
+		b, err := render("body", c.Body, data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(b)
+	}
+
+	req, err := http.NewRequest(c.Method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, value := range c.Headers {
+		v, err := render("header "+name, value, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, v)
+	}
+
+	return req, nil
+}
+
+// authenticate adds the credentials from the configured Secret, if any, to
+// the request. Secrets are read again periodically so that rotated
+// credentials are used.
+func (b Backend) authenticate(req *http.Request) error {
+	switch {
+	case b.bearerTokenSecret != nil:
+		token, err := b.bearerTokenSecret.Value(b.bearerTokenSecretKey)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(token))
+
+	case b.basicAuthSecret != nil:
+		data, err := b.basicAuthSecret.Data()
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(string(data[basicAuthUsernameKey]), string(data[basicAuthPasswordKey]))
+	}
+
+	return nil
+}
+
+// do performs the request and returns the response body, or an error if the
+// request failed or returned a non-2xx status
+func (b Backend) do(req *http.Request) ([]byte, error) {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+// extractValue returns the single numeric value at the given JSONPath
+// expression in the JSON body. Numbers encoded as strings are allowed.
+func extractValue(body []byte, path string) (float64, error) {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, errors.Wrap(err, "decoding response")
+	}
+
+	j := jsonpath.New("value")
+	if err := j.Parse(path); err != nil {
+		return 0, errors.Wrapf(err, "parsing jsonPath %q", path)
+	}
+
+	results, err := j.FindResults(data)
+	if err != nil {
+		return 0, errors.Wrapf(err, "evaluating jsonPath %q", path)
+	}
+
+	var values []interface{}
+	for _, r := range results {
+		for _, v := range r {
+			values = append(values, v.Interface())
+		}
+	}
+
+	if len(values) != 1 {
+		return 0, errors.Errorf("expected jsonPath %q to match a single value but it matched %d", path, len(values))
+	}
+
+	switch v := values[0].(type) {
+	case float64:
+		return v, nil
+
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parsing value %q", v)
+		}
+		return f, nil
+
+	default:
+		return 0, errors.Errorf("unexpected value type %T: %#v", v, v)
+	}
+}
+
+// nodeIP returns the internal IP of the node, or its first address of any
+// type if it has no internal IP
+func nodeIP(node *corev1.Node) string {
+	for _, a := range node.Status.Addresses {
+		if a.Type == corev1.NodeInternalIP {
+			return a.Address
+		}
+	}
+
+	if len(node.Status.Addresses) > 0 {
+		return node.Status.Addresses[0].Address
+	}
+
+	return ""
+}
+
+func render(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing %s template", name)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", errors.Wrapf(err, "executing %s template", name)
+	}
+
+	return out.String(), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding JSON")
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,298 @@
+package httpjson
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+)
+
+var (
+	node0 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-0",
+			Labels: map[string]string{"pool": "a"},
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "node-0.example.com"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}
+	node1 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{"pool": "a"},
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+			},
+		},
+	}
+)
+
+// recordedRequest is the last request received by the test server
+type recordedRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   string
+}
+
+func newTestServer(status int, body string, last *recordedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		*last = recordedRequest{
+			method: r.Method,
+			uri:    r.URL.RequestURI(),
+			header: r.Header,
+			body:   string(b),
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(map[string]string{}, nil, corelistersv1.NewNodeLister(nil))
+	assert.NoError(t, err, "all configuration is optional")
+	assert.NotNil(t, client)
+
+	_, err = NewClient(map[string]string{}, nil, nil)
+	assert.Error(t, err, "error on nil NodeLister")
+
+	_, err = NewClient(map[string]string{"timeoutSeconds": "soon"}, nil, corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on invalid timeout")
+}
+
+func TestGetValueGet(t *testing.T) {
+	var last recordedRequest
+	server := newTestServer(http.StatusOK, `{"series":[{"pointlist":[[1546300800, 12.5]]}]}`, &last)
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"url":                server.URL + "/api/v1/query?query={{urlquery .Metric}}&hosts={{join .NodeNames \",\"}}",
+		"header.DD-API-KEY":  "secret",
+		"header.X-Node-Pool": "{{index .NodeSelector \"pool\"}}",
+	}, nil, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("avg:system.cpu.user{*}", nil, nil)
+	assert.Error(t, err, "jsonPath is required")
+
+	val, err := backend.GetValue("avg:system.cpu.user{*}", map[string]string{
+		"jsonPath": "{.series[0].pointlist[0][1]}",
+	}, map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, val)
+	assert.Equal(t, http.MethodGet, last.method, "GET by default")
+	assert.Equal(t, "/api/v1/query?query=avg%3Asystem.cpu.user%7B%2A%7D&hosts=node-0,node-1", last.uri)
+	assert.Equal(t, "secret", last.header.Get("DD-API-KEY"))
+
+	_, err = backend.GetValue("metric", map[string]string{
+		"jsonPath": "{.series[*].pointlist[0][0]}",
+		"url":      server.URL,
+	}, nil)
+	assert.NoError(t, err, "metric configuration overrides backend url")
+	assert.Equal(t, "/", last.uri)
+	assert.Empty(t, last.header.Get("DD-API-KEY"), "backend headers are not sent to an overridden url")
+
+	_, err = backend.GetValue("metric", map[string]string{
+		"jsonPath": "{.series[0].missing}",
+	}, nil)
+	assert.Error(t, err, "jsonPath must match")
+
+	_, err = backend.GetValue("metric", map[string]string{
+		"jsonPath": "{.series[0].pointlist[0][1]}",
+		"method":   "DELETE",
+	}, nil)
+	assert.Error(t, err, "invalid method")
+}
+
+func TestGetValuePost(t *testing.T) {
+	var last recordedRequest
+	server := newTestServer(http.StatusOK, `{"data":{"free":"3"}}`, &last)
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"url": server.URL + "/capacity",
+	}, nil, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	val, err := backend.GetValue("free_slots", map[string]string{
+		"method":          "post",
+		"body":            `{"ips":{{toJSON .NodeIPs}},"selector":{{toJSON .NodeSelector}}}`,
+		"jsonPath":        "{.data.free}",
+		"header.X-Metric": "{{.Metric}}",
+	}, map[string]string{"pool": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), val, "string values are parsed")
+	assert.Equal(t, http.MethodPost, last.method)
+	assert.Equal(t, `{"ips":["10.0.0.1","10.0.0.2"],"selector":{"pool":"a"}}`, last.body)
+	assert.Equal(t, "application/json", last.header.Get("Content-Type"))
+	assert.Equal(t, "free_slots", last.header.Get("X-Metric"))
+}
+
+func TestGetValueAuthentication(t *testing.T) {
+	var last recordedRequest
+	server := newTestServer(http.StatusOK, `{"value":1}`, &last)
+	defer server.Close()
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "capacity-token",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			defaultBearerTokenSecretKey: []byte("secret-token\n"),
+		},
+	}
+	basicAuthSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "capacity-basic-auth",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			basicAuthUsernameKey: []byte("cerebral"),
+			basicAuthPasswordKey: []byte("hunter2"),
+		},
+	}
+	kubeclientset := fake.NewSimpleClientset(tokenSecret, basicAuthSecret)
+
+	backend, err := NewClient(map[string]string{
+		"url":                        server.URL,
+		"jsonPath":                   "{.value}",
+		"bearerTokenSecretName":      tokenSecret.Name,
+		"bearerTokenSecretNamespace": tokenSecret.Namespace,
+	}, kubeclientset, buildNodeLister(nil))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("metric", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret-token", last.header.Get("Authorization"), "bearer token from Secret")
+
+	_, err = backend.GetValue("metric", map[string]string{
+		"url": server.URL + "/elsewhere",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, last.header.Get("Authorization"), "credentials are not sent to an overridden url")
+
+	backend, err = NewClient(map[string]string{
+		"url":                      server.URL,
+		"jsonPath":                 "{.value}",
+		"basicAuthSecretName":      basicAuthSecret.Name,
+		"basicAuthSecretNamespace": basicAuthSecret.Namespace,
+	}, kubeclientset, buildNodeLister(nil))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("metric", nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, last.header.Get("Authorization"), "Basic ", "basic auth from Secret")
+
+	_, err = NewClient(map[string]string{
+		"bearerTokenSecretName":      tokenSecret.Name,
+		"bearerTokenSecretNamespace": tokenSecret.Namespace,
+		"bearerTokenSecretKey":       "missing",
+	}, kubeclientset, buildNodeLister(nil))
+	assert.Error(t, err, "bearer token Secret key must exist")
+
+	_, err = NewClient(map[string]string{
+		"bearerTokenSecretName":      tokenSecret.Name,
+		"bearerTokenSecretNamespace": tokenSecret.Namespace,
+		"basicAuthSecretName":        basicAuthSecret.Name,
+		"basicAuthSecretNamespace":   basicAuthSecret.Namespace,
+	}, kubeclientset, buildNodeLister(nil))
+	assert.Error(t, err, "multiple authentication methods")
+
+	_, err = NewClient(map[string]string{
+		"basicAuthSecretName":      "missing",
+		"basicAuthSecretNamespace": "kube-system",
+	}, kubeclientset, buildNodeLister(nil))
+	assert.Error(t, err, "basic auth Secret must exist")
+}
+
+func TestGetValueErrors(t *testing.T) {
+	var last recordedRequest
+	server := newTestServer(http.StatusInternalServerError, `{"error":"boom"}`, &last)
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"url":      server.URL,
+		"jsonPath": "{.value}",
+	}, nil, buildNodeLister(nil))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("metric", nil, nil)
+	assert.Error(t, err, "non-2xx status")
+
+	_, err = backend.GetValue("metric", map[string]string{
+		"url": "{{.Missing}}",
+	}, nil)
+	assert.Error(t, err, "invalid template")
+}
+
+func TestExtractValue(t *testing.T) {
+	val, err := extractValue([]byte(`{"value": 1.5}`), "{.value}")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, val)
+
+	_, err = extractValue([]byte(`not json`), "{.value}")
+	assert.Error(t, err, "invalid JSON")
+
+	_, err = extractValue([]byte(`{"value": 1.5}`), "{.value")
+	assert.Error(t, err, "invalid jsonPath")
+
+	_, err = extractValue([]byte(`{"values": [1, 2]}`), "{.values[*]}")
+	assert.Error(t, err, "multiple matches")
+
+	_, err = extractValue([]byte(`{"value": true}`), "{.value}")
+	assert.Error(t, err, "non-numeric value")
+
+	_, err = extractValue([]byte(`{"value": "high"}`), "{.value}")
+	assert.Error(t, err, "non-numeric string value")
+}
+
+func TestNodeIP(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", nodeIP(&node0), "internal IP is preferred")
+	assert.Equal(t, "", nodeIP(&corev1.Node{}), "no addresses")
+
+	node := corev1.Node{
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeExternalIP, Address: "1.2.3.4"},
+			},
+		},
+	}
+	assert.Equal(t, "1.2.3.4", nodeIP(&node), "first address if no internal IP")
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
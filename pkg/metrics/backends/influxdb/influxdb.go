@@ -0,0 +1,574 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
+	"github.com/containership/cerebral/pkg/secretutil"
+	"github.com/containership/cluster-manager/pkg/log"
+)
+
+// Backend implements a metrics backend for InfluxDB. Built-in metrics assume
+// the default Telegraf schema, with each node's host tag matching its name
+// (or the value of a configurable node label). Nodes accessed via the lister
+// must not be mutated.
+type Backend struct {
+	address  *url.URL
+	database string
+	org      string
+	hostTag  string
+	// hostNodeLabel is the node label holding the host tag value. If empty,
+	// the node name is used.
+	hostNodeLabel string
+	// maxMissingHosts is the number of hosts that may have no series before
+	// built-in metric queries fail
+	maxMissingHosts int
+
+	// At most one of the following is set. Secrets are read again
+	// periodically so that rotated credentials are used.
+	tokenSecret     *secretutil.Reference
+	tokenSecretKey  string
+	basicAuthSecret *secretutil.Reference
+
+	client *http.Client
+
+	nodeLister corelistersv1.NodeLister
+}
+
+const defaultHostTag = "host"
+
+const (
+	defaultTokenSecretKey = "token"
+
+	// Keys of the basic auth Secret data, matching kubernetes.io/basic-auth
+	// Secrets
+	basicAuthUsernameKey = "username"
+	basicAuthPasswordKey = "password"
+)
+
+const queryTimeout = 10 * time.Second
+
+// Average CPU usage for each host for the given range
+const cpuQueryTemplateString = `SELECT 100 - mean("usage_idle") FROM "cpu" ` +
+	`WHERE "cpu" = 'cpu-total' AND "{{.HostTag}}" =~ /{{.HostsRegex}}/ AND time > now() - {{.Range}} ` +
+	`GROUP BY "{{.HostTag}}"`
+
+var cpuQueryTemplate = template.Must(template.New("cpu").Parse(cpuQueryTemplateString))
+
+// Average memory usage for each host for the given range
+const memoryQueryTemplateString = `SELECT mean("used_percent") FROM "mem" ` +
+	`WHERE "{{.HostTag}}" =~ /{{.HostsRegex}}/ AND time > now() - {{.Range}} ` +
+	`GROUP BY "{{.HostTag}}"`
+
+var memoryQueryTemplate = template.Must(template.New("mem").Parse(memoryQueryTemplateString))
+
+// queryTemplateData is available to all query templates, including custom
+// queries
+type queryTemplateData struct {
+	HostTag    string
+	HostsRegex string
+	Range      string
+}
+
+// NewClient returns a new client for talking to an InfluxDB Backend described
+// by the given MetricsBackend configuration, or an error. Any credentials
+// Secrets are read using the given clientset.
+func NewClient(configuration map[string]string, kubeclientset kubernetes.Interface, nodeLister corelistersv1.NodeLister) (metrics.Backend, error) {
+	address := configuration["address"]
+	if address == "" {
+		return nil, errors.New("address must not be empty")
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing address")
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	hostTag := configuration["hostTag"]
+	if hostTag == "" {
+		hostTag = defaultHostTag
+	}
+
+	b := Backend{
+		address:       u,
+		database:      configuration["database"],
+		org:           configuration["org"],
+		hostTag:       hostTag,
+		hostNodeLabel: configuration["hostNodeLabel"],
+		client:        &http.Client{},
+		nodeLister:    nodeLister,
+	}
+
+	if s, ok := configuration["maxMissingHosts"]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, errors.Errorf("maxMissingHosts must be a non-negative integer but is %q", s)
+		}
+		b.maxMissingHosts = n
+	}
+
+	if name := configuration["tokenSecretName"]; name != "" {
+		if configuration["basicAuthSecretName"] != "" {
+			return nil, errors.New("at most one of tokenSecretName or basicAuthSecretName may be provided")
+		}
+
+		b.tokenSecretKey = configuration["tokenSecretKey"]
+		if b.tokenSecretKey == "" {
+			b.tokenSecretKey = defaultTokenSecretKey
+		}
+
+		b.tokenSecret, err = secretutil.NewReference(kubeclientset, configuration["tokenSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := b.tokenSecret.Value(b.tokenSecretKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if name := configuration["basicAuthSecretName"]; name != "" {
+		b.basicAuthSecret, err = secretutil.NewReference(kubeclientset, configuration["basicAuthSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := b.basicAuthSecret.Value(basicAuthUsernameKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// GetValue implements the metrics.Backend interface
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	selector := nodeutil.GetNodesLabelSelector(nodeSelector)
+	nodes, err := b.nodeLister.List(selector)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing nodes")
+	}
+
+	hosts, err := b.getHosts(nodes)
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting hosts for metric %s", metric)
+	}
+
+	switch metric {
+	case MetricCPUPercentUtilization.String():
+		return b.getAggregatedValue(cpuQueryTemplate, hosts, configuration)
+
+	case MetricMemoryPercentUtilization.String():
+		return b.getAggregatedValue(memoryQueryTemplate, hosts, configuration)
+
+	case MetricCustom.String():
+		return b.getCustomValue(hosts, configuration)
+
+	default:
+		return 0, errors.Errorf("unknown metric %q", metric)
+	}
+}
+
+// getHosts returns the host tag value for each of the given nodes, sorted
+func (b Backend) getHosts(nodes []*corev1.Node) ([]string, error) {
+	var hosts []string
+	for _, node := range nodes {
+		if b.hostNodeLabel == "" {
+			hosts = append(hosts, node.Name)
+			continue
+		}
+
+		host, ok := node.Labels[b.hostNodeLabel]
+		if !ok {
+			return nil, errors.Errorf("node %s is missing host label %s", node.Name, b.hostNodeLabel)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	// The lister returns nodes in no particular order. Sort so that the
+	// same nodes always produce the same query.
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+// getAggregatedValue performs a built-in InfluxQL query that returns a series
+// per host and aggregates the per-host values
+func (b Backend) getAggregatedValue(tmpl *template.Template, hosts []string, configuration map[string]string) (float64, error) {
+	config := metricConfiguration{}
+	if err := config.defaultAndValidate(configuration); err != nil {
+		return 0, errors.Wrap(err, "validating configuration")
+	}
+
+	query, err := executeTemplate(tmpl, queryTemplateData{
+		HostTag:    b.hostTag,
+		HostsRegex: buildHostsRegex(hosts),
+		Range:      config.Range,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	series, err := b.performInfluxQLQuery(query)
+	if err != nil {
+		return 0, err
+	}
+
+	valuesByHost := make(map[string]float64)
+	for _, s := range series {
+		valuesByHost[s.tags[b.hostTag]] = s.value
+	}
+
+	var values []float64
+	var missing []string
+	for _, host := range hosts {
+		v, ok := valuesByHost[host]
+		if !ok {
+			missing = append(missing, host)
+			continue
+		}
+
+		values = append(values, v)
+	}
+
+	if err := b.checkMissing(len(hosts), missing); err != nil {
+		return 0, err
+	}
+
+	return metrics.Aggregate(config.Aggregation, values)
+}
+
+// checkMissing returns an error if more hosts have no series than tolerated,
+// or if all hosts have none. Otherwise, missing hosts are logged and excluded
+// from the aggregation.
+func (b Backend) checkMissing(numHosts int, missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(missing) > b.maxMissingHosts || len(missing) == numHosts {
+		return errors.Errorf("found series for %d of %d hosts (missing for %s)",
+			numHosts-len(missing), numHosts, strings.Join(missing, ", "))
+	}
+
+	log.Infof("InfluxDB backend: ignoring %d hosts with no series: %s",
+		len(missing), strings.Join(missing, ", "))
+
+	return nil
+}
+
+// For a custom query, a `query` key must be provided in the configuration
+// map. The `language` key may be set to `flux` to use Flux instead of the
+// default InfluxQL. The query must return a single value.
+func (b Backend) getCustomValue(hosts []string, configuration map[string]string) (float64, error) {
+	queryTemplate, ok := configuration["query"]
+	if !ok {
+		return 0, errors.New("configuration key \"query\" must be provided for a custom query")
+	}
+
+	tmpl, err := template.New("query").Parse(queryTemplate)
+	if err != nil {
+		return 0, errors.Wrap(err, "parsing custom query template")
+	}
+
+	query, err := executeTemplate(tmpl, queryTemplateData{
+		HostTag:    b.hostTag,
+		HostsRegex: buildHostsRegex(hosts),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var values []float64
+	switch language := configuration["language"]; language {
+	case "", languageInfluxQL:
+		var series []influxQLValue
+		series, err = b.performInfluxQLQuery(query)
+		for _, s := range series {
+			values = append(values, s.value)
+		}
+
+	case languageFlux:
+		values, err = b.performFluxQuery(query)
+
+	default:
+		return 0, errors.Errorf("invalid query language %q", language)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) != 1 {
+		return 0, errors.Errorf("expected query to return a single value but it returned %d", len(values))
+	}
+
+	return values[0], nil
+}
+
+// influxQLResponse is the response of the InfluxDB 1.x /query endpoint
+type influxQLResponse struct {
+	Results []struct {
+		Series []struct {
+			Tags    map[string]string `json:"tags"`
+			Columns []string          `json:"columns"`
+			Values  [][]interface{}   `json:"values"`
+		} `json:"series"`
+		Error string `json:"error"`
+	} `json:"results"`
+	Error string `json:"error"`
+}
+
+// influxQLValue is the last value of an InfluxQL series along with the tags
+// identifying the series
+type influxQLValue struct {
+	tags  map[string]string
+	value float64
+}
+
+// performInfluxQLQuery performs the query and returns the last value of each
+// series returned by the first statement
+func (b Backend) performInfluxQLQuery(query string) ([]influxQLValue, error) {
+	log.Debugf("Performing InfluxQL query: %s", query)
+
+	params := url.Values{}
+	params.Set("q", query)
+	if b.database != "" {
+		params.Set("db", b.database)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.endpoint("/query"), strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := b.do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying influxdb with string %q", query)
+	}
+
+	var resp influxQLResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "decoding influxdb response")
+	}
+
+	if resp.Error != "" {
+		return nil, errors.Errorf("influxdb error: %s", resp.Error)
+	}
+
+	if len(resp.Results) == 0 {
+		return nil, errors.New("influxdb returned no results")
+	}
+
+	result := resp.Results[0]
+	if result.Error != "" {
+		return nil, errors.Errorf("influxdb error: %s", result.Error)
+	}
+
+	var values []influxQLValue
+	for _, series := range result.Series {
+		if len(series.Values) == 0 {
+			continue
+		}
+
+		row := series.Values[len(series.Values)-1]
+		if len(row) == 0 {
+			continue
+		}
+
+		v, ok := row[len(row)-1].(float64)
+		if !ok {
+			return nil, errors.Errorf("unexpected influxdb value type %T: %#v", row[len(row)-1], row[len(row)-1])
+		}
+
+		values = append(values, influxQLValue{
+			tags:  series.Tags,
+			value: v,
+		})
+	}
+
+	return values, nil
+}
+
+// performFluxQuery performs the query and returns the _value column of each
+// row returned
+func (b Backend) performFluxQuery(query string) ([]float64, error) {
+	log.Debugf("Performing Flux query: %s", query)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"query": query,
+		"type":  "flux",
+	})
+
+	endpoint := b.endpoint("/api/v2/query")
+	if b.org != "" {
+		endpoint += "?" + url.Values{"org": []string{b.org}}.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/csv")
+
+	body, err := b.do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying influxdb with string %q", query)
+	}
+
+	return parseFluxCSV(body)
+}
+
+// do performs the request with a timeout and returns the response body, or an
+// error if the request failed or returned a non-2xx status
+func (b Backend) do(req *http.Request) ([]byte, error) {
+	if err := b.authenticate(req); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+// authenticate adds the credentials from the configured Secret, if any, to
+// the request. InfluxDB 2.x uses tokens and InfluxDB 1.x uses basic auth.
+func (b Backend) authenticate(req *http.Request) error {
+	switch {
+	case b.tokenSecret != nil:
+		token, err := b.tokenSecret.Value(b.tokenSecretKey)
+		if err != nil {
+			return errors.Wrap(err, "reading token")
+		}
+		req.Header.Set("Authorization", "Token "+strings.TrimSpace(token))
+
+	case b.basicAuthSecret != nil:
+		data, err := b.basicAuthSecret.Data()
+		if err != nil {
+			return errors.Wrap(err, "reading basic auth credentials")
+		}
+		req.SetBasicAuth(string(data[basicAuthUsernameKey]), string(data[basicAuthPasswordKey]))
+	}
+
+	return nil
+}
+
+func (b Backend) endpoint(path string) string {
+	u := *b.address
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String()
+}
+
+// parseFluxCSV returns the _value column of every row in a Flux annotated CSV
+// response. Each table in the response starts with its own header row.
+func parseFluxCSV(body []byte) ([]float64, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+
+	var values []float64
+	valueIndex := -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing flux response")
+		}
+
+		if isFluxHeader(record) {
+			valueIndex = indexOf(record, "_value")
+			continue
+		}
+
+		if valueIndex < 0 || valueIndex >= len(record) {
+			return nil, errors.New("flux response has no _value column")
+		}
+
+		v, err := strconv.ParseFloat(record[valueIndex], 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing flux value %q", record[valueIndex])
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+func isFluxHeader(record []string) bool {
+	return indexOf(record, "result") >= 0 && indexOf(record, "table") >= 0
+}
+
+func indexOf(record []string, s string) int {
+	for i, r := range record {
+		if r == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func executeTemplate(tmpl *template.Template, data queryTemplateData) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// buildHostsRegex returns a regex matching exactly the given hosts
+func buildHostsRegex(hosts []string) string {
+	quoted := make([]string, len(hosts))
+	for i, h := range hosts {
+		quoted[i] = regexp.QuoteMeta(h)
+	}
+
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
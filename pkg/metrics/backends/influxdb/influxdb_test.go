@@ -0,0 +1,383 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+)
+
+var (
+	node0 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-0",
+			Labels: map[string]string{
+				"kubernetes.io/hostname": "host-0",
+			},
+		},
+	}
+	node1 = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Labels: map[string]string{
+				"kubernetes.io/hostname": "host-1",
+			},
+		},
+	}
+)
+
+const twoSeriesResponse = `{"results":[{"statement_id":0,"series":[
+	{"name":"cpu","tags":{"host":"node-0"},"columns":["time","mean"],"values":[[0,20]]},
+	{"name":"cpu","tags":{"host":"node-1"},"columns":["time","mean"],"values":[[0,60]]}
+]}]}`
+
+const fluxResponse = `#datatype,string,long,dateTime:RFC3339,double
+#group,false,false,false,false
+#default,_result,,,
+,result,table,_time,_value
+,,0,2019-01-01T00:00:00Z,42.5
+`
+
+// testServer serves the given body for every request and records the last
+// request form
+type testServer struct {
+	*httptest.Server
+
+	status int
+	body   string
+
+	lastPath   string
+	lastForm   url.Values
+	lastQuery  map[string]interface{}
+	lastHeader http.Header
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		status: http.StatusOK,
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lastPath = r.URL.Path
+		s.lastHeader = r.Header
+		if r.URL.Path == "/api/v2/query" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &s.lastQuery)
+		} else {
+			r.ParseForm()
+			s.lastForm = r.Form
+		}
+
+		w.WriteHeader(s.status)
+		w.Write([]byte(s.body))
+	}))
+
+	return s
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(map[string]string{
+		"address": "http://localhost:8086",
+	}, nil, corelistersv1.NewNodeLister(nil))
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+	assert.Equal(t, defaultHostTag, client.(Backend).hostTag, "host tag defaults")
+
+	_, err = NewClient(map[string]string{}, nil, corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on empty address")
+
+	_, err = NewClient(map[string]string{
+		"address": "http://localhost:8086",
+	}, nil, nil)
+	assert.Error(t, err, "error on nil NodeLister")
+
+	_, err = NewClient(map[string]string{
+		"address":         "http://localhost:8086",
+		"maxMissingHosts": "-1",
+	}, nil, corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on negative maxMissingHosts")
+
+	_, err = NewClient(map[string]string{
+		"address":             "http://localhost:8086",
+		"tokenSecretName":     "token",
+		"basicAuthSecretName": "basic",
+	}, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on both token and basic auth")
+
+	_, err = NewClient(map[string]string{
+		"address":         "http://localhost:8086",
+		"tokenSecretName": "token",
+	}, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on missing token Secret")
+}
+
+func TestGetValue(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"address":  server.URL,
+		"database": "telegraf",
+	}, nil, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue("not a valid metric", nil, nil)
+	assert.Error(t, err, "unknown metric requested")
+
+	server.body = twoSeriesResponse
+	val, err := backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(40), val, "per-host values default to avg")
+	assert.Equal(t, "/query", server.lastPath)
+	assert.Equal(t, "telegraf", server.lastForm.Get("db"))
+	assert.Contains(t, server.lastForm.Get("q"), `"host" =~ /^(node-0|node-1)$/`)
+	assert.Contains(t, server.lastForm.Get("q"), `now() - 1m`)
+
+	val, err = backend.GetValue(MetricMemoryPercentUtilization.String(), map[string]string{
+		"aggregation": "max",
+		"range":       "5m",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(60), val, "max aggregation")
+	assert.Contains(t, server.lastForm.Get("q"), `FROM "mem"`)
+	assert.Contains(t, server.lastForm.Get("q"), `now() - 5m`)
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"range": "forever",
+	}, nil)
+	assert.Error(t, err, "invalid range")
+
+	server.body = `{"results":[{"statement_id":0,"series":[
+		{"name":"cpu","tags":{"host":"node-0"},"columns":["time","mean"],"values":[[0,20]]}
+	]}]}`
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "missing series for a host")
+
+	tolerant, err := NewClient(map[string]string{
+		"address":         server.URL,
+		"maxMissingHosts": "1",
+	}, nil, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	val, err = tolerant.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20), val, "missing host tolerated and excluded")
+
+	server.body = `{"results":[{"statement_id":0}]}`
+	_, err = tolerant.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "all hosts missing is never tolerated")
+
+	server.body = `{"results":[{"statement_id":0,"error":"database not found: telegraf"}]}`
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "influxdb statement error")
+
+	server.status = http.StatusUnauthorized
+	server.body = `{"error":"authorization failed"}`
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "non-2xx status")
+}
+
+func TestGetValueAuthentication(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	server.body = twoSeriesResponse
+
+	kubeclientset := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "influxdb-token",
+				Namespace: "monitoring",
+			},
+			Data: map[string][]byte{
+				"token": []byte("secret-token\n"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "influxdb-basic-auth",
+				Namespace: "monitoring",
+			},
+			Data: map[string][]byte{
+				"username": []byte("user"),
+				"password": []byte("pass"),
+			},
+		},
+	)
+	nodeLister := buildNodeLister([]corev1.Node{node0, node1})
+
+	backend, err := NewClient(map[string]string{
+		"address":              server.URL,
+		"tokenSecretName":      "influxdb-token",
+		"tokenSecretNamespace": "monitoring",
+	}, kubeclientset, nodeLister)
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Token secret-token", server.lastHeader.Get("Authorization"))
+
+	backend, err = NewClient(map[string]string{
+		"address":                  server.URL,
+		"basicAuthSecretName":      "influxdb-basic-auth",
+		"basicAuthSecretNamespace": "monitoring",
+	}, kubeclientset, nodeLister)
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.NoError(t, err)
+	req := http.Request{Header: server.lastHeader}
+	username, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+}
+
+func TestGetValueCustom(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	backend, err := NewClient(map[string]string{
+		"address":       server.URL,
+		"org":           "my-org",
+		"hostNodeLabel": "kubernetes.io/hostname",
+	}, nil, buildNodeLister([]corev1.Node{node0}))
+	assert.NoError(t, err)
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{}, nil)
+	assert.Error(t, err, "query is required")
+
+	server.body = `{"results":[{"statement_id":0,"series":[
+		{"name":"queue","columns":["time","last"],"values":[[0,7]]}
+	]}]}`
+	val, err := backend.GetValue(MetricCustom.String(), map[string]string{
+		"query": `SELECT last("depth") FROM "queue" WHERE "{{.HostTag}}" =~ /{{.HostsRegex}}/`,
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(7), val)
+	assert.Equal(t, `SELECT last("depth") FROM "queue" WHERE "host" =~ /^(host-0)$/`, server.lastForm.Get("q"),
+		"host label is used for host tag")
+
+	server.body = twoSeriesResponse
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"query": `SELECT mean("usage_idle") FROM "cpu" GROUP BY "host"`,
+	}, nil)
+	assert.Error(t, err, "custom query must return a single value")
+
+	server.body = fluxResponse
+	val, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"language": "flux",
+		"query":    `from(bucket: "telegraf") |> range(start: -1m) |> last()`,
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 42.5, val)
+	assert.Equal(t, "/api/v2/query", server.lastPath)
+	assert.Equal(t, "flux", server.lastQuery["type"])
+
+	_, err = backend.GetValue(MetricCustom.String(), map[string]string{
+		"language": "sql",
+		"query":    "SELECT 1",
+	}, nil)
+	assert.Error(t, err, "invalid language")
+}
+
+func TestGetHosts(t *testing.T) {
+	backend := Backend{}
+	hosts, err := backend.getHosts([]*corev1.Node{&node0, &node1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-0", "node-1"}, hosts, "node names by default")
+
+	backend.hostNodeLabel = "kubernetes.io/hostname"
+	hosts, err = backend.getHosts([]*corev1.Node{&node0, &node1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host-0", "host-1"}, hosts, "node label if configured")
+
+	hosts, err = backend.getHosts([]*corev1.Node{&node1, &node0})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host-0", "host-1"}, hosts, "sorted regardless of node order")
+
+	backend.hostNodeLabel = "missing"
+	_, err = backend.getHosts([]*corev1.Node{&node0})
+	assert.Error(t, err, "missing host label")
+}
+
+func TestGetValueQueryStable(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	server.body = twoSeriesResponse
+
+	backend, err := NewClient(map[string]string{
+		"address": server.URL,
+	}, nil, buildNodeLister([]corev1.Node{node0, node1}))
+	assert.NoError(t, err)
+
+	var first string
+	for i := 0; i < 20; i++ {
+		_, err := backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+		assert.NoError(t, err)
+
+		query := server.lastForm.Get("q")
+		if i == 0 {
+			first = query
+			continue
+		}
+
+		assert.Equal(t, first, query, "same query for the same nodes on every call")
+	}
+
+	assert.Contains(t, first, `"host" =~ /^(node-0|node-1)$/`)
+}
+
+func TestParseFluxCSV(t *testing.T) {
+	values, err := parseFluxCSV([]byte(fluxResponse))
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{42.5}, values)
+
+	multipleTables := `,result,table,_value
+,,0,1
+,,0,2
+
+,result,table,host,_value
+,,1,a,3
+`
+	values, err = parseFluxCSV([]byte(multipleTables))
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, values, "each table has its own header")
+
+	_, err = parseFluxCSV([]byte(",result,table,_time\n,,0,2019-01-01T00:00:00Z\n"))
+	assert.Error(t, err, "missing _value column")
+}
+
+func TestBuildHostsRegex(t *testing.T) {
+	assert.Equal(t, "^(a)$", buildHostsRegex([]string{"a"}))
+	assert.Equal(t, `^(a|b\.example\.com)$`, buildHostsRegex([]string{"a", "b.example.com"}))
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
@@ -0,0 +1,69 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// Metric is a metric exposed by this backend
+type Metric int
+
+const (
+	// MetricCPUPercentUtilization is used to gather info about the CPU usage of nodes
+	MetricCPUPercentUtilization Metric = iota
+	// MetricMemoryPercentUtilization is used to gather info about the memory usage of nodes
+	MetricMemoryPercentUtilization
+	// MetricCustom is used to perform a custom InfluxQL or Flux query
+	MetricCustom
+)
+
+// String is a stringer for Metric
+func (m Metric) String() string {
+	switch m {
+	case MetricCPUPercentUtilization:
+		return "cpu_percent_utilization"
+	case MetricMemoryPercentUtilization:
+		return "memory_percent_utilization"
+	case MetricCustom:
+		return "custom"
+	}
+
+	return "unknown"
+}
+
+const (
+	languageInfluxQL = "influxql"
+	languageFlux     = "flux"
+)
+
+// See https://docs.influxdata.com/influxdb/v1.7/query_language/spec/#durations
+var validRangeRegex = regexp.MustCompile(`^\d+(u|µ|ms|s|m|h|d|w)$`)
+
+const defaultRange = "1m"
+
+// metricConfiguration is the configuration for the built-in metrics
+type metricConfiguration struct {
+	Aggregation string `json:"aggregation"`
+	Range       string `json:"range"`
+}
+
+// defaults and validates the metricConfiguration. Intended to be called with an
+// empty struct that we'll fill in here using the caller-provided configuration.
+// The aggregation is validated when it is applied.
+func (c *metricConfiguration) defaultAndValidate(configuration map[string]string) error {
+	// Round trip the config through JSON parser to populate our struct
+	j, _ := json.Marshal(configuration)
+	json.Unmarshal(j, c)
+
+	if c.Range == "" {
+		c.Range = defaultRange
+	}
+
+	if !validRangeRegex.MatchString(c.Range) {
+		return errors.Errorf("invalid range %s", c.Range)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
+)
+
+// Backend implements a metrics backend that computes resource allocation
+// from the node and pod caches, i.e. the sum of pod resource requests
+// relative to node allocatable. It requires no external service. Nodes and
+// pods accessed via the listers must not be mutated.
+type Backend struct {
+	nodeLister corelistersv1.NodeLister
+	podLister  corelistersv1.PodLister
+}
+
+// NewClient returns a new Kubernetes allocation Backend, or an error
+func NewClient(nodeLister corelistersv1.NodeLister, podLister corelistersv1.PodLister) (metrics.Backend, error) {
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	if podLister == nil {
+		return nil, errors.New("pod lister must be provided")
+	}
+
+	return Backend{
+		nodeLister: nodeLister,
+		podLister:  podLister,
+	}, nil
+}
+
+// GetValue implements the metrics.Backend interface
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	selector := nodeutil.GetNodesLabelSelector(nodeSelector)
+	nodes, err := b.nodeLister.List(selector)
+	if err != nil {
+		return 0, errors.Wrap(err, "listing nodes")
+	}
+
+	switch metric {
+	case MetricCPURequestsPercent.String():
+		return b.getRequestsPercent(nodes, corev1.ResourceCPU)
+
+	case MetricMemoryRequestsPercent.String():
+		return b.getRequestsPercent(nodes, corev1.ResourceMemory)
+
+	case MetricPodsPercent.String():
+		return b.getRequestsPercent(nodes, corev1.ResourcePods)
+
+	case MetricUnschedulablePods.String():
+		count, err := b.countUnschedulablePods(nodes, nodeSelector)
+		return float64(count), err
+
+	default:
+		return 0, errors.Errorf("unknown metric %q", metric)
+	}
+}
+
+// getRequestsPercent returns the amount of the given resource requested by
+// pods on the given nodes as a percentage of the nodes' allocatable amount.
+// For pods, each pod counts as a request of one.
+func (b Backend) getRequestsPercent(nodes []*corev1.Node, name corev1.ResourceName) (float64, error) {
+	pods, err := b.getActivePodsOnNodes(nodes)
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting pods for resource %s", name)
+	}
+
+	allocatable := sumNodeAllocatable(nodes, name)
+	if allocatable.IsZero() {
+		return 0, errors.Errorf("nodes have no allocatable %s", name)
+	}
+
+	var requested *resource.Quantity
+	if name == corev1.ResourcePods {
+		requested = resource.NewQuantity(int64(len(pods)), resource.DecimalSI)
+	} else {
+		requested = sumPodRequests(pods, name)
+	}
+
+	return percent(requested, allocatable), nil
+}
+
+// getActivePodsOnNodes returns all pods that are bound to one of the given
+// nodes and are not in a terminal phase
+func (b Backend) getActivePodsOnNodes(nodes []*corev1.Node) ([]*corev1.Pod, error) {
+	nodeNames := make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		nodeNames[node.Name] = struct{}{}
+	}
+
+	pods, err := b.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+
+	var active []*corev1.Pod
+	for _, pod := range pods {
+		if _, ok := nodeNames[pod.Spec.NodeName]; !ok {
+			continue
+		}
+
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			// Terminal pods no longer hold their requested resources
+			continue
+		}
+
+		active = append(active, pod)
+	}
+
+	return active, nil
+}
+
+// sumNodeAllocatable returns the total allocatable amount of the given
+// resource across nodes
+func sumNodeAllocatable(nodes []*corev1.Node, name corev1.ResourceName) *resource.Quantity {
+	total := resource.NewQuantity(0, resource.DecimalSI)
+	for _, node := range nodes {
+		if q, ok := node.Status.Allocatable[name]; ok {
+			total.Add(q)
+		}
+	}
+
+	return total
+}
+
+// sumPodRequests returns the total requested amount of the given resource
+// across pods
+func sumPodRequests(pods []*corev1.Pod, name corev1.ResourceName) *resource.Quantity {
+	total := resource.NewQuantity(0, resource.DecimalSI)
+	for _, pod := range pods {
+		total.Add(*podRequest(pod, name))
+	}
+
+	return total
+}
+
+// podRequest returns the effective request of a pod for the given resource
+// the same way the scheduler computes it: the sum of container requests, or
+// the largest init container request if that is greater
+func podRequest(pod *corev1.Pod, name corev1.ResourceName) *resource.Quantity {
+	request := resource.NewQuantity(0, resource.DecimalSI)
+	for _, c := range pod.Spec.Containers {
+		if q, ok := c.Resources.Requests[name]; ok {
+			request.Add(q)
+		}
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		if q, ok := c.Resources.Requests[name]; ok && q.Cmp(*request) > 0 {
+			request = q.Copy()
+		}
+	}
+
+	return request
+}
+
+// percent returns requested as a percentage of allocatable. Milli-units are
+// used so that fractional CPU values are not truncated.
+func percent(requested, allocatable *resource.Quantity) float64 {
+	return 100 * float64(requested.MilliValue()) / float64(allocatable.MilliValue())
+}
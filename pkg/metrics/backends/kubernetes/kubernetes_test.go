@@ -0,0 +1,181 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+)
+
+var poolLabels = map[string]string{
+	"pool": "workers",
+}
+
+func buildNode(name string, labels map[string]string, cpu, memory, pods string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+				corev1.ResourcePods:   resource.MustParse(pods),
+			},
+		},
+	}
+}
+
+func buildPod(name, nodeName string, phase corev1.PodPhase, cpu, memory string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse(cpu),
+							corev1.ResourceMemory: resource.MustParse(memory),
+						},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+var (
+	node0     = buildNode("node-0", poolLabels, "2", "4Gi", "10")
+	node1     = buildNode("node-1", poolLabels, "2", "4Gi", "10")
+	otherNode = buildNode("other-0", nil, "4", "8Gi", "10")
+
+	podOnNode0       = buildPod("pod-0", "node-0", corev1.PodRunning, "500m", "1Gi")
+	podOnNode1       = buildPod("pod-1", "node-1", corev1.PodRunning, "1500m", "2Gi")
+	succeededOnNode1 = buildPod("pod-2", "node-1", corev1.PodSucceeded, "1", "1Gi")
+	podOnOtherNode   = buildPod("pod-3", "other-0", corev1.PodRunning, "4", "8Gi")
+	pendingPod       = buildPod("pod-4", "", corev1.PodPending, "4", "8Gi")
+)
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = NewClient(nil, corelistersv1.NewPodLister(nil))
+	assert.Error(t, err, "error on nil NodeLister")
+
+	_, err = NewClient(corelistersv1.NewNodeLister(nil), nil)
+	assert.Error(t, err, "error on nil PodLister")
+}
+
+func TestGetValue(t *testing.T) {
+	backend := Backend{
+		nodeLister: buildNodeLister([]corev1.Node{node0, node1, otherNode}),
+		podLister: buildPodLister([]corev1.Pod{
+			podOnNode0, podOnNode1, succeededOnNode1, podOnOtherNode, pendingPod,
+		}),
+	}
+
+	_, err := backend.GetValue("not a valid metric", nil, poolLabels)
+	assert.Error(t, err, "unknown metric requested")
+
+	val, err := backend.GetValue(MetricCPURequestsPercent.String(), nil, poolLabels)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(50), val, "cpu requests exclude terminal pods and other nodes")
+
+	val, err = backend.GetValue(MetricMemoryRequestsPercent.String(), nil, poolLabels)
+	assert.NoError(t, err)
+	assert.Equal(t, 37.5, val, "memory requests exclude terminal pods and other nodes")
+
+	val, err = backend.GetValue(MetricPodsPercent.String(), nil, poolLabels)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), val, "pods exclude terminal pods and other nodes")
+
+	val, err = backend.GetValue(MetricCPURequestsPercent.String(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 75.0, val, "empty node selector selects all nodes")
+
+	_, err = backend.GetValue(MetricCPURequestsPercent.String(), nil, map[string]string{"no": "match"})
+	assert.Error(t, err, "error when no allocatable resources")
+}
+
+func TestPodRequest(t *testing.T) {
+	pod := buildPod("pod", "node", corev1.PodRunning, "500m", "1Gi")
+	pod.Spec.Containers = append(pod.Spec.Containers, pod.Spec.Containers[0])
+
+	q := podRequest(&pod, corev1.ResourceCPU)
+	assert.Equal(t, int64(1000), q.MilliValue(), "container requests are summed")
+
+	q = podRequest(&pod, corev1.ResourceEphemeralStorage)
+	assert.True(t, q.IsZero(), "no requests for resource is zero")
+
+	pod.Spec.InitContainers = []corev1.Container{
+		{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("2"),
+				},
+			},
+		},
+	}
+
+	q = podRequest(&pod, corev1.ResourceCPU)
+	assert.Equal(t, int64(2000), q.MilliValue(), "larger init container request wins")
+
+	q = podRequest(&pod, corev1.ResourceMemory)
+	expected := resource.MustParse("2Gi")
+	assert.Equal(t, expected.Value(), q.Value(), "init container without request is ignored")
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
+
+// Get a pod lister. Copies of the pods are added to the cache; not the pods themselves.
+func buildPodLister(pods []corev1.Pod) corelistersv1.PodLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Pods()
+
+	for _, pod := range pods {
+		err := informer.Informer().GetStore().Add(pod.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
@@ -0,0 +1,36 @@
+package kubernetes
+
+// Metric is a metric exposed by this backend
+type Metric int
+
+const (
+	// MetricCPURequestsPercent is the sum of pod CPU requests as a percentage
+	// of allocatable CPU across nodes
+	MetricCPURequestsPercent Metric = iota
+	// MetricMemoryRequestsPercent is the sum of pod memory requests as a
+	// percentage of allocatable memory across nodes
+	MetricMemoryRequestsPercent
+	// MetricPodsPercent is the number of pods as a percentage of allocatable
+	// pods across nodes
+	MetricPodsPercent
+	// MetricUnschedulablePods is the number of pods that the scheduler failed
+	// to schedule but that could be scheduled onto the nodes if there were
+	// enough of them
+	MetricUnschedulablePods
+)
+
+// String is a stringer for Metric
+func (m Metric) String() string {
+	switch m {
+	case MetricCPURequestsPercent:
+		return "cpu_requests_percent"
+	case MetricMemoryRequestsPercent:
+		return "memory_requests_percent"
+	case MetricPodsPercent:
+		return "pods_percent"
+	case MetricUnschedulablePods:
+		return "unschedulable_pods"
+	}
+
+	return "unknown"
+}
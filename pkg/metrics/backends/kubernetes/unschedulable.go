@@ -0,0 +1,218 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// nodeNameField is the only node field supported by the scheduler in a node
+// selector term's matchFields
+const nodeNameField = "metadata.name"
+
+// countUnschedulablePods returns the number of pods that are pending because
+// the scheduler could not place them and that would fit on at least one of
+// the given nodes if it had room. If there are no nodes (e.g. the node pool
+// has been scaled to zero), a template node carrying only the node selector
+// labels is used instead so that the pool can still be scaled up.
+func (b Backend) countUnschedulablePods(nodes []*corev1.Node, nodeSelector map[string]string) (int, error) {
+	candidates := nodes
+	if len(candidates) == 0 {
+		candidates = []*corev1.Node{templateNode(nodeSelector)}
+	}
+
+	pods, err := b.podLister.List(labels.Everything())
+	if err != nil {
+		return 0, errors.Wrap(err, "listing pods")
+	}
+
+	count := 0
+	for _, pod := range pods {
+		if !isUnschedulable(pod) {
+			continue
+		}
+
+		for _, node := range candidates {
+			if podFitsNode(pod, node) {
+				count++
+				break
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// templateNode returns a node that only has the given labels
+func templateNode(nodeLabels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: nodeLabels,
+		},
+	}
+}
+
+// isUnschedulable returns true if the pod is pending and the scheduler has
+// marked it as unschedulable
+func isUnschedulable(pod *corev1.Pod) bool {
+	if pod.Spec.NodeName != "" || pod.Status.Phase != corev1.PodPending {
+		return false
+	}
+
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled &&
+			c.Status == corev1.ConditionFalse &&
+			c.Reason == corev1.PodReasonUnschedulable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// podFitsNode returns true if the pod's node selector, required node
+// affinity and tolerations allow it to run on the given node, and if the
+// node would have enough allocatable resources were it empty
+func podFitsNode(pod *corev1.Pod, node *corev1.Node) bool {
+	nodeLabels := labels.Set(node.Labels)
+
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(nodeLabels) {
+		return false
+	}
+
+	if !matchesRequiredNodeAffinity(pod, node) {
+		return false
+	}
+
+	if !toleratesNodeTaints(pod, node) {
+		return false
+	}
+
+	return fitsNodeAllocatable(pod, node)
+}
+
+// matchesRequiredNodeAffinity returns true if the pod has no required node
+// affinity or if the node matches at least one of its terms
+func matchesRequiredNodeAffinity(pod *corev1.Pod, node *corev1.Node) bool {
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil ||
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for _, term := range terms {
+		if matchesNodeSelectorTerm(term, node) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesNodeSelectorTerm returns true if all requirements of the term match
+// the node. As with the scheduler, an empty term matches nothing.
+func matchesNodeSelectorTerm(term corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+
+	if len(term.MatchExpressions) > 0 {
+		selector, err := nodeSelectorRequirementsAsSelector(term.MatchExpressions)
+		if err != nil || !selector.Matches(labels.Set(node.Labels)) {
+			return false
+		}
+	}
+
+	for _, req := range term.MatchFields {
+		if req.Key != nodeNameField {
+			return false
+		}
+
+		selector, err := nodeSelectorRequirementsAsSelector([]corev1.NodeSelectorRequirement{req})
+		if err != nil || !selector.Matches(labels.Set{nodeNameField: node.Name}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodeSelectorRequirementsAsSelector converts node selector requirements to
+// a label selector
+func nodeSelectorRequirementsAsSelector(reqs []corev1.NodeSelectorRequirement) (labels.Selector, error) {
+	selector := labels.NewSelector()
+	for _, req := range reqs {
+		var op selection.Operator
+		switch req.Operator {
+		case corev1.NodeSelectorOpIn:
+			op = selection.In
+		case corev1.NodeSelectorOpNotIn:
+			op = selection.NotIn
+		case corev1.NodeSelectorOpExists:
+			op = selection.Exists
+		case corev1.NodeSelectorOpDoesNotExist:
+			op = selection.DoesNotExist
+		case corev1.NodeSelectorOpGt:
+			op = selection.GreaterThan
+		case corev1.NodeSelectorOpLt:
+			op = selection.LessThan
+		default:
+			return nil, errors.Errorf("invalid node selector operator %q", req.Operator)
+		}
+
+		r, err := labels.NewRequirement(req.Key, op, req.Values)
+		if err != nil {
+			return nil, err
+		}
+
+		selector = selector.Add(*r)
+	}
+
+	return selector, nil
+}
+
+// toleratesNodeTaints returns true if the pod tolerates all of the node's
+// taints that affect scheduling
+func toleratesNodeTaints(pod *corev1.Pod, node *corev1.Node) bool {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+
+		if !tolerated {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fitsNodeAllocatable returns true if each of the pod's resource requests
+// fits within the node's allocatable resources. Resources the node does not
+// report (e.g. for a template node) are not checked.
+func fitsNodeAllocatable(pod *corev1.Pod, node *corev1.Node) bool {
+	for name, allocatable := range node.Status.Allocatable {
+		if name == corev1.ResourcePods {
+			continue
+		}
+
+		if podRequest(pod, name).Cmp(allocatable) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
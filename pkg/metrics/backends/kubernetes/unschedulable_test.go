@@ -0,0 +1,157 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func buildUnschedulablePod(name string, cpu string) corev1.Pod {
+	pod := buildPod(name, "", corev1.PodPending, cpu, "1Gi")
+	pod.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionFalse,
+			Reason: corev1.PodReasonUnschedulable,
+		},
+	}
+
+	return pod
+}
+
+func requireNodeAffinity(pod *corev1.Pod, terms ...corev1.NodeSelectorTerm) {
+	pod.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: terms,
+			},
+		},
+	}
+}
+
+func TestIsUnschedulable(t *testing.T) {
+	pod := buildUnschedulablePod("pod", "1")
+	assert.True(t, isUnschedulable(&pod))
+
+	pod.Spec.NodeName = "node-0"
+	assert.False(t, isUnschedulable(&pod), "bound pod is not unschedulable")
+
+	assert.False(t, isUnschedulable(&pendingPod), "pending pod without condition is not unschedulable")
+}
+
+func TestPodFitsNode(t *testing.T) {
+	node := buildNode("node-0", poolLabels, "2", "4Gi", "10")
+
+	pod := buildUnschedulablePod("pod", "1")
+	assert.True(t, podFitsNode(&pod, &node), "pod without constraints fits")
+
+	pod = buildUnschedulablePod("pod", "3")
+	assert.False(t, podFitsNode(&pod, &node), "pod requesting more than allocatable does not fit")
+
+	pod = buildUnschedulablePod("pod", "1")
+	pod.Spec.NodeSelector = map[string]string{"pool": "other"}
+	assert.False(t, podFitsNode(&pod, &node), "mismatched node selector")
+
+	pod.Spec.NodeSelector = poolLabels
+	assert.True(t, podFitsNode(&pod, &node), "matching node selector")
+
+	requireNodeAffinity(&pod, corev1.NodeSelectorTerm{
+		MatchExpressions: []corev1.NodeSelectorRequirement{
+			{
+				Key:      "pool",
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"other"},
+			},
+		},
+	})
+	assert.False(t, podFitsNode(&pod, &node), "mismatched required node affinity")
+
+	requireNodeAffinity(&pod,
+		corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{
+					Key:      "pool",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{"other"},
+				},
+			},
+		},
+		corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{
+					Key:      "pool",
+					Operator: corev1.NodeSelectorOpExists,
+				},
+			},
+		})
+	assert.True(t, podFitsNode(&pod, &node), "node affinity terms are ORed")
+
+	requireNodeAffinity(&pod, corev1.NodeSelectorTerm{
+		MatchFields: []corev1.NodeSelectorRequirement{
+			{
+				Key:      nodeNameField,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"node-1"},
+			},
+		},
+	})
+	assert.False(t, podFitsNode(&pod, &node), "mismatched node name field")
+
+	pod.Spec.Affinity = nil
+	node.Spec.Taints = []corev1.Taint{
+		{
+			Key:    "dedicated",
+			Value:  "gpu",
+			Effect: corev1.TaintEffectNoSchedule,
+		},
+	}
+	assert.False(t, podFitsNode(&pod, &node), "untolerated taint")
+
+	pod.Spec.Tolerations = []corev1.Toleration{
+		{
+			Key:      "dedicated",
+			Operator: corev1.TolerationOpEqual,
+			Value:    "gpu",
+			Effect:   corev1.TaintEffectNoSchedule,
+		},
+	}
+	assert.True(t, podFitsNode(&pod, &node), "tolerated taint")
+
+	node.Spec.Taints[0].Effect = corev1.TaintEffectPreferNoSchedule
+	pod.Spec.Tolerations = nil
+	assert.True(t, podFitsNode(&pod, &node), "PreferNoSchedule taints are ignored")
+}
+
+func TestGetValueUnschedulablePods(t *testing.T) {
+	fits := buildUnschedulablePod("fits", "1")
+	tooBig := buildUnschedulablePod("too-big", "3")
+	otherPool := buildUnschedulablePod("other-pool", "1")
+	otherPool.Spec.NodeSelector = map[string]string{"pool": "other"}
+
+	backend := Backend{
+		nodeLister: buildNodeLister([]corev1.Node{node0, node1}),
+		podLister:  buildPodLister([]corev1.Pod{fits, tooBig, otherPool, podOnNode0, pendingPod}),
+	}
+
+	val, err := backend.GetValue(MetricUnschedulablePods.String(), nil, poolLabels)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), val, "only pods that would fit on the pool are counted")
+
+	backend.nodeLister = buildNodeLister(nil)
+
+	val, err = backend.GetValue(MetricUnschedulablePods.String(), nil, poolLabels)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), val, "empty pool uses node selector labels without resource checks")
+}
+
+func TestFitsNodeAllocatable(t *testing.T) {
+	pod := buildUnschedulablePod("pod", "1")
+	assert.True(t, fitsNodeAllocatable(&pod, templateNode(nil)), "template node has no allocatable to check")
+
+	node := buildNode("node", nil, "1", "512Mi", "10")
+	node.Status.Allocatable[corev1.ResourcePods] = resource.MustParse("0")
+	assert.False(t, fitsNodeAllocatable(&pod, &node), "memory request too large")
+}
@@ -0,0 +1,24 @@
+package metricsserver
+
+// Metric is a metric exposed by this backend
+type Metric int
+
+const (
+	// MetricCPUPercentUtilization is used to gather info about the CPU usage of nodes
+	MetricCPUPercentUtilization Metric = iota
+	// MetricMemoryPercentUtilization is used to gather info about the memory usage of nodes
+	MetricMemoryPercentUtilization
+)
+
+// String is a stringer for Metric. Names match those of the Prometheus
+// backend so that policies can be moved between backends.
+func (m Metric) String() string {
+	switch m {
+	case MetricCPUPercentUtilization:
+		return "cpu_percent_utilization"
+	case MetricMemoryPercentUtilization:
+		return "memory_percent_utilization"
+	}
+
+	return "unknown"
+}
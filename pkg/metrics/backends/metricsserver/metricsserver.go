@@ -0,0 +1,103 @@
+package metricsserver
+
+import (
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/nodeutil"
+)
+
+// Backend implements a metrics backend for the metrics.k8s.io API, which is
+// typically served by metrics-server. It requires a node lister in order to
+// look up the allocatable resources of nodes. Nodes accessed via the lister
+// must not be mutated.
+type Backend struct {
+	metricsclientset metricsclientset.Interface
+
+	nodeLister corelistersv1.NodeLister
+}
+
+// NewClient returns a new client for talking to the metrics.k8s.io API, or an error
+func NewClient(metricsclientset metricsclientset.Interface, nodeLister corelistersv1.NodeLister) (metrics.Backend, error) {
+	if metricsclientset == nil {
+		return nil, errors.New("metrics clientset must be provided")
+	}
+
+	if nodeLister == nil {
+		return nil, errors.New("node lister must be provided")
+	}
+
+	return Backend{
+		metricsclientset: metricsclientset,
+		nodeLister:       nodeLister,
+	}, nil
+}
+
+// GetValue implements the metrics.Backend interface. The per-node values are
+// aggregated using the `aggregation` and `aggregationParameter`, and
+// aggregations resulting in multiple values are reduced using the
+// `reduction`, as with the Prometheus backend.
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	var resourceName corev1.ResourceName
+	switch metric {
+	case MetricCPUPercentUtilization.String():
+		resourceName = corev1.ResourceCPU
+
+	case MetricMemoryPercentUtilization.String():
+		resourceName = corev1.ResourceMemory
+
+	default:
+		return 0, errors.Errorf("unknown metric %q", metric)
+	}
+
+	aggregation := configuration["aggregation"]
+	reduction, ok := configuration["reduction"]
+	if !ok {
+		reduction = metrics.DefaultReductions[aggregation]
+	} else if !metrics.IsValidAggregation(reduction) {
+		return 0, errors.Errorf("invalid reduction %s", reduction)
+	}
+
+	values, err := b.getNodeUtilizationPercents(resourceName, nodeSelector)
+	if err != nil {
+		return 0, errors.Wrapf(err, "getting node utilization for metric %s", metric)
+	}
+
+	return metrics.AggregateWithParameter(aggregation, configuration["aggregationParameter"], reduction, values)
+}
+
+// getNodeUtilizationPercents returns the usage of the given resource as a
+// percentage of allocatable for each node matching the node selector
+func (b Backend) getNodeUtilizationPercents(name corev1.ResourceName, nodeSelector map[string]string) ([]float64, error) {
+	selector := nodeutil.GetNodesLabelSelector(nodeSelector)
+
+	nodeMetrics, err := b.metricsclientset.MetricsV1beta1().NodeMetricses().List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing node metrics")
+	}
+
+	var values []float64
+	for _, m := range nodeMetrics.Items {
+		node, err := b.nodeLister.Get(m.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting node %s", m.Name)
+		}
+
+		allocatable, ok := node.Status.Allocatable[name]
+		if !ok || allocatable.IsZero() {
+			return nil, errors.Errorf("node %s has no allocatable %s", m.Name, name)
+		}
+
+		usage := m.Usage[name]
+		values = append(values, 100*float64(usage.MilliValue())/float64(allocatable.MilliValue()))
+	}
+
+	return values, nil
+}
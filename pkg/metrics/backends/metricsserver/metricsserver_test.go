@@ -0,0 +1,164 @@
+package metricsserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+	ktesting "k8s.io/client-go/testing"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func buildNode(name, cpu, memory string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
+
+func buildNodeMetrics(name, cpu, memory string) metricsv1beta1.NodeMetrics {
+	return metricsv1beta1.NodeMetrics{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Usage: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+// buildMetricsClientset returns a fake clientset that lists the given node
+// metrics, or returns the given error
+func buildMetricsClientset(items []metricsv1beta1.NodeMetrics, err error) *metricsfake.Clientset {
+	client := &metricsfake.Clientset{}
+	client.AddReactor("list", "*", func(action ktesting.Action) (bool, runtime.Object, error) {
+		if err != nil {
+			return true, nil, err
+		}
+
+		return true, &metricsv1beta1.NodeMetricsList{Items: items}, nil
+	})
+
+	return client
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(&metricsfake.Clientset{}, corelistersv1.NewNodeLister(nil))
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = NewClient(nil, corelistersv1.NewNodeLister(nil))
+	assert.Error(t, err, "error on nil metrics clientset")
+
+	_, err = NewClient(&metricsfake.Clientset{}, nil)
+	assert.Error(t, err, "error on nil NodeLister")
+}
+
+func TestGetValue(t *testing.T) {
+	backend := Backend{
+		metricsclientset: buildMetricsClientset([]metricsv1beta1.NodeMetrics{
+			buildNodeMetrics("node-0", "500m", "1Gi"),
+			buildNodeMetrics("node-1", "1500m", "3Gi"),
+		}, nil),
+		nodeLister: buildNodeLister([]corev1.Node{
+			buildNode("node-0", "2", "4Gi"),
+			buildNode("node-1", "2", "4Gi"),
+		}),
+	}
+
+	_, err := backend.GetValue("not a valid metric", nil, nil)
+	assert.Error(t, err, "unknown metric requested")
+
+	val, err := backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(50), val, "cpu defaults to avg")
+
+	val, err = backend.GetValue(MetricMemoryPercentUtilization.String(), map[string]string{
+		"aggregation": "max",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(75), val, "memory with max aggregation")
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"aggregation": "invalid-aggregation",
+	}, nil)
+	assert.Error(t, err, "invalid aggregation")
+
+	val, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "1",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(75), val, "topk aggregation")
+
+	val, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"aggregation":          "bottomk",
+		"aggregationParameter": "2",
+		"reduction":            "sum",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), val, "bottomk aggregation with reduction")
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "1",
+		"reduction":            "median",
+	}, nil)
+	assert.Error(t, err, "invalid reduction")
+
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), map[string]string{
+		"aggregation": "quantile",
+	}, nil)
+	assert.Error(t, err, "quantile requires a parameter")
+
+	backend.nodeLister = buildNodeLister([]corev1.Node{
+		buildNode("node-0", "2", "4Gi"),
+	})
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "error when node is not in cache")
+
+	backend.metricsclientset = buildMetricsClientset(nil, nil)
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "error when no node metrics are available")
+
+	backend.metricsclientset = buildMetricsClientset(nil, errors.New("metrics API unavailable"))
+	_, err = backend.GetValue(MetricCPUPercentUtilization.String(), nil, nil)
+	assert.Error(t, err, "error when metrics API errors")
+}
+
+// Get a node lister. Copies of the nodes are added to the cache; not the nodes themselves.
+func buildNodeLister(nodes []corev1.Node) corelistersv1.NodeLister {
+	// We don't need anything related to the client or informer; we're simply
+	// using this as an easy way to build a cache
+	client := &fake.Clientset{}
+	kubeInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := kubeInformerFactory.Core().V1().Nodes()
+
+	for _, node := range nodes {
+		err := informer.Informer().GetStore().Add(node.DeepCopy())
+		if err != nil {
+			// Should be a programming error
+			panic(err)
+		}
+	}
+
+	return informer.Lister()
+}
@@ -0,0 +1,87 @@
+// Package conformance provides a test suite that MetricsBackend plugins can
+// run in order to verify that they implement the plugin protocol as Cerebral
+// expects.
+//
+// A plugin author would typically start their plugin in a test and call Run
+// with the configuration used to reach it and the metrics it supports:
+//
+//	conformance.Run(t, map[string]string{"socket": socket}, []conformance.Case{
+//		{Metric: "my_metric"},
+//	})
+package conformance
+
+import (
+	"io"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin"
+)
+
+// unknownMetric is a metric name no plugin is expected to support
+const unknownMetric = "cerebral-conformance-unknown-metric"
+
+// concurrency is the number of concurrent requests made per Case
+const concurrency = 10
+
+// Case is a metric that the plugin under test is expected to support with
+// the given configuration
+type Case struct {
+	Metric        string
+	Configuration map[string]string
+	NodeSelector  map[string]string
+}
+
+// Run runs the conformance suite against the plugin reachable using the given
+// MetricsBackend configuration. At least one Case should be provided.
+func Run(t *testing.T, configuration map[string]string, cases []Case) {
+	backend, err := plugin.NewClient(configuration)
+	if err != nil {
+		t.Fatalf("plugin must be reachable and report healthy: %s", err)
+	}
+
+	if closer, ok := backend.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	t.Run("UnknownMetricErrors", func(t *testing.T) {
+		if _, err := backend.GetValue(unknownMetric, nil, nil); err == nil {
+			t.Errorf("expected an error for unknown metric %q", unknownMetric)
+		}
+	})
+
+	for _, c := range cases {
+		c := c
+		t.Run("Metric/"+c.Metric, func(t *testing.T) {
+			checkValue(t, backend, c)
+		})
+
+		t.Run("Concurrent/"+c.Metric, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < concurrency; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					checkValue(t, backend, c)
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// checkValue verifies that a value can be retrieved for the Case and that it
+// is a real number
+func checkValue(t *testing.T, backend metrics.Backend, c Case) {
+	val, err := backend.GetValue(c.Metric, c.Configuration, c.NodeSelector)
+	if err != nil {
+		t.Errorf("getting value for metric %q: %s", c.Metric, err)
+		return
+	}
+
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		t.Errorf("metric %q returned non-finite value %f", c.Metric, val)
+	}
+}
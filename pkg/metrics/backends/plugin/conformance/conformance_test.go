@@ -0,0 +1,40 @@
+package conformance
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin"
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin/sample"
+)
+
+// The sample plugin must always pass the conformance suite
+func TestSamplePluginConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := plugin.NewServer(sample.Backend{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	Run(t, map[string]string{"socket": socket}, []Case{
+		{
+			Metric: sample.MetricConstant,
+			Configuration: map[string]string{
+				"value": "0.5",
+			},
+		},
+	})
+}
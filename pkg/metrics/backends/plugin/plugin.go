@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"google.golang.org/grpc"
+
+	"github.com/containership/cerebral/pkg/metrics"
+	"github.com/containership/cerebral/pkg/pluginutil"
+)
+
+// Backend implements a metrics backend that delegates to an out-of-process
+// plugin implementing the MetricsBackend gRPC service defined in backend.proto
+type Backend struct {
+	config *pluginutil.Config
+	conn   *grpc.ClientConn
+}
+
+// NewClient returns a new client for talking to a plugin backend described by
+// the given MetricsBackend configuration, or an error if the plugin is not
+// reachable and healthy
+func NewClient(configuration map[string]string) (metrics.Backend, error) {
+	config, err := pluginutil.ConfigFromMap(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := config.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pluginutil.CheckHealth(conn, ServiceName, config.Timeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Backend{
+		config: config,
+		conn:   conn,
+	}, nil
+}
+
+// GetValue implements the metrics.Backend interface
+func (b *Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+	defer cancel()
+
+	resp := &GetValueResponse{}
+	err := b.conn.Invoke(ctx, fullMethod("GetValue"), &GetValueRequest{
+		Metric:        metric,
+		Configuration: configuration,
+		NodeSelector:  nodeSelector,
+	}, resp)
+	if err != nil {
+		return 0, errors.Wrapf(err, "plugin failed to get value for metric %s", metric)
+	}
+
+	return resp.Value, nil
+}
+
+// Close closes the connection to the plugin
+func (b *Backend) Close() error {
+	return b.conn.Close()
+}
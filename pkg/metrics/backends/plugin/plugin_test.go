@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containership/cerebral/pkg/metrics/backends/plugin/sample"
+)
+
+// startPlugin serves the sample backend on a Unix socket in a temporary
+// directory, returning the socket path and a cleanup function
+func startPlugin(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "metrics-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(sample.Backend{})
+	go server.Serve(lis)
+
+	return socket, func() {
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient(map[string]string{})
+	assert.Error(t, err, "address or socket is required")
+
+	_, err = NewClient(map[string]string{"socket": "/does/not/exist.sock"})
+	assert.Error(t, err, "plugin must be reachable")
+
+	socket, cleanup := startPlugin(t)
+	defer cleanup()
+
+	client, err := NewClient(map[string]string{"socket": socket})
+	assert.NoError(t, err)
+	assert.NotNil(t, client)
+	client.(*Backend).Close()
+}
+
+func TestGetValue(t *testing.T) {
+	socket, cleanup := startPlugin(t)
+	defer cleanup()
+
+	client, err := NewClient(map[string]string{"socket": socket})
+	assert.NoError(t, err)
+	defer client.(*Backend).Close()
+
+	_, err = client.GetValue("not-a-metric", nil, nil)
+	assert.Error(t, err, "plugin errors are propagated")
+
+	val, err := client.GetValue(sample.MetricConstant, map[string]string{"value": "12.25"}, map[string]string{"pool": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 12.25, val, "value round trips through plugin")
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/grpc"
+)
+
+// The types in this file mirror backend.proto. They are maintained by hand
+// (rather than generated by protoc) so that building Cerebral does not
+// require a protobuf toolchain; any change here must be reflected in
+// backend.proto and vice versa.
+
+// ServiceName is the fully qualified name of the plugin gRPC service. It is
+// also the service name plugins must report as serving via the gRPC health
+// checking protocol.
+const ServiceName = "cerebral.metrics.v1.MetricsBackend"
+
+// GetValueRequest is the request for MetricsBackend.GetValue
+type GetValueRequest struct {
+	Metric        string            `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	Configuration map[string]string `protobuf:"bytes,2,rep,name=configuration,proto3" json:"configuration,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	NodeSelector  map[string]string `protobuf:"bytes,3,rep,name=node_selector,json=nodeSelector,proto3" json:"node_selector,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+// Reset implements proto.Message
+func (m *GetValueRequest) Reset() { *m = GetValueRequest{} }
+
+// String implements proto.Message
+func (m *GetValueRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*GetValueRequest) ProtoMessage() {}
+
+// GetValueResponse is the response for MetricsBackend.GetValue
+type GetValueResponse struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+// Reset implements proto.Message
+func (m *GetValueResponse) Reset() { *m = GetValueResponse{} }
+
+// String implements proto.Message
+func (m *GetValueResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*GetValueResponse) ProtoMessage() {}
+
+// MetricsBackendServer is the server API for the plugin service
+type MetricsBackendServer interface {
+	GetValue(context.Context, *GetValueRequest) (*GetValueResponse, error)
+}
+
+// RegisterMetricsBackendServer registers the plugin service with a gRPC server
+func RegisterMetricsBackendServer(s *grpc.Server, srv MetricsBackendServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*MetricsBackendServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetValue",
+			Handler:    getValueHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "backend.proto",
+}
+
+func fullMethod(method string) string {
+	return "/" + ServiceName + "/" + method
+}
+
+func getValueHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetValueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(MetricsBackendServer).GetValue(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod("GetValue"),
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsBackendServer).GetValue(ctx, req.(*GetValueRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
@@ -0,0 +1,37 @@
+// Package sample provides a sample metrics backend for serving as an
+// out-of-process plugin. It is intended for testing and as an example for
+// plugin authors.
+package sample
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MetricConstant returns the float value of the "value" configuration key
+const MetricConstant = "constant"
+
+// Backend is a metrics backend exposing static metrics
+type Backend struct{}
+
+// GetValue implements the metrics.Backend interface
+func (b Backend) GetValue(metric string, configuration map[string]string, nodeSelector map[string]string) (float64, error) {
+	switch metric {
+	case MetricConstant:
+		s, ok := configuration["value"]
+		if !ok {
+			return 0, errors.New("configuration key \"value\" must be provided for the constant metric")
+		}
+
+		val, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parsing value %q", s)
+		}
+
+		return val, nil
+
+	default:
+		return 0, errors.Errorf("unknown metric %q", metric)
+	}
+}
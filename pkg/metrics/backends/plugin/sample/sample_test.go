@@ -0,0 +1,24 @@
+package sample
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetValue(t *testing.T) {
+	b := Backend{}
+
+	_, err := b.GetValue("not-a-metric", nil, nil)
+	assert.Error(t, err, "unknown metric")
+
+	_, err = b.GetValue(MetricConstant, nil, nil)
+	assert.Error(t, err, "value is required")
+
+	_, err = b.GetValue(MetricConstant, map[string]string{"value": "NaN-ish"}, nil)
+	assert.Error(t, err, "value must be a float")
+
+	val, err := b.GetValue(MetricConstant, map[string]string{"value": "42.5"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 42.5, val)
+}
@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/containership/cerebral/pkg/metrics"
+)
+
+// NewServer returns a gRPC server serving the given backend using the plugin
+// protocol, along with the gRPC health checking service. This is intended for
+// use by plugin authors writing plugins in Go.
+func NewServer(backend metrics.Backend, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+
+	RegisterMetricsBackendServer(s, &backendServer{
+		backend: backend,
+	})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	return s
+}
+
+// backendServer adapts a metrics.Backend to the plugin service
+type backendServer struct {
+	backend metrics.Backend
+}
+
+func (s *backendServer) GetValue(ctx context.Context, req *GetValueRequest) (*GetValueResponse, error) {
+	val, err := s.backend.GetValue(req.Metric, req.Configuration, req.NodeSelector)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	return &GetValueResponse{
+		Value: val,
+	}, nil
+}
@@ -0,0 +1,306 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/containership/cluster-manager/pkg/log"
+)
+
+// Node exporter discovery modes, i.e. how the node exporter series for a set
+// of nodes are identified
+const (
+	// discoveryModePods finds node exporter pods on the nodes and matches on
+	// the instance label by pod IP
+	discoveryModePods = "pods"
+	// discoveryModeTargets looks up the node exporter targets in Prometheus
+	// by node name or IP and matches on their instance label
+	discoveryModeTargets = "targets"
+	// discoveryModeLabel matches on a series label whose value is the node
+	// name, e.g. as set by relabeling
+	discoveryModeLabel = "label"
+)
+
+const (
+	defaultDiscoveryMode    = discoveryModePods
+	defaultPodSelector      = "prom-exporter=node"
+	defaultNodeLabel        = "node"
+	instanceLabel           = "instance"
+	targetsDiscoveryTimeout = 10 * time.Second
+)
+
+// discoveryConfiguration configures node exporter discovery. The zero value
+// is valid and uses the defaults.
+type discoveryConfiguration struct {
+	mode string
+
+	// -- Pods
+	podSelector  labels.Selector
+	podNamespace string
+
+	// -- Targets
+	// job optionally restricts targets to those with this job label
+	job string
+
+	// -- Label
+	nodeLabel string
+
+	// maxMissing is the number of nodes that may be missing a node exporter
+	// before discovery fails. Nodes without an exporter are excluded from
+	// queries.
+	maxMissing int
+}
+
+// exporterSelector selects the node exporter series for a set of nodes
+type exporterSelector struct {
+	// Label is the series label to match on
+	Label string
+	// Regex matches the values of Label for the selected nodes
+	Regex string
+}
+
+// discoveryConfigurationFromMap builds a discoveryConfiguration from the
+// MetricsBackend configuration
+func discoveryConfigurationFromMap(configuration map[string]string) (discoveryConfiguration, error) {
+	c := discoveryConfiguration{
+		mode:         configuration["nodeExporterDiscovery"],
+		podNamespace: configuration["nodeExporterNamespace"],
+		job:          configuration["nodeExporterJob"],
+		nodeLabel:    configuration["nodeExporterNodeLabel"],
+	}
+
+	switch c.mode {
+	case "":
+		c.mode = defaultDiscoveryMode
+	case discoveryModePods, discoveryModeTargets, discoveryModeLabel:
+	default:
+		return c, errors.Errorf("invalid nodeExporterDiscovery %q (must be %s, %s or %s)",
+			c.mode, discoveryModePods, discoveryModeTargets, discoveryModeLabel)
+	}
+
+	if s, ok := configuration["nodeExporterPodSelector"]; ok {
+		selector, err := labels.Parse(s)
+		if err != nil {
+			return c, errors.Wrap(err, "parsing nodeExporterPodSelector")
+		}
+		c.podSelector = selector
+	}
+
+	if s, ok := configuration["maxMissingNodeExporters"]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return c, errors.Errorf("maxMissingNodeExporters must be a non-negative integer but is %q", s)
+		}
+		c.maxMissing = n
+	}
+
+	return c, nil
+}
+
+// getExporterSelector returns a selector for the node exporter series of the
+// given nodes using the configured discovery mode
+func (b Backend) getExporterSelector(nodes []*corev1.Node) (exporterSelector, error) {
+	switch b.discovery.mode {
+	case discoveryModeTargets:
+		instances, err := b.getNodeExporterTargetInstancesOnNodes(nodes)
+		if err != nil {
+			return exporterSelector{}, err
+		}
+
+		return exporterSelector{
+			Label: instanceLabel,
+			Regex: buildExactRegex(instances),
+		}, nil
+
+	case discoveryModeLabel:
+		label := b.discovery.nodeLabel
+		if label == "" {
+			label = defaultNodeLabel
+		}
+
+		var names []string
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+
+		return exporterSelector{
+			Label: label,
+			Regex: buildExactRegex(names),
+		}, nil
+
+	default:
+		podIPs, err := b.getNodeExporterPodIPsOnNodes(nodes)
+		if err != nil {
+			return exporterSelector{}, err
+		}
+
+		return podIPsSelector(podIPs), nil
+	}
+}
+
+func (b Backend) getNodeExporterPodIPsOnNodes(nodes []*corev1.Node) ([]string, error) {
+	selector := b.discovery.podSelector
+	if selector == nil {
+		// Always valid
+		selector, _ = labels.Parse(defaultPodSelector)
+	}
+
+	var pods []*corev1.Pod
+	var err error
+	if b.discovery.podNamespace != "" {
+		pods, err = b.podLister.Pods(b.discovery.podNamespace).List(selector)
+	} else {
+		pods, err = b.podLister.List(selector)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "listing node exporter pods")
+	}
+
+	// Index pods by node so that matching is linear. If there are multiple
+	// exporter pods on a node (e.g. during a rollout), the first one with an
+	// IP is used.
+	podIPsByNode := make(map[string]string)
+	for _, pod := range pods {
+		if pod.Status.PodIP == "" {
+			continue
+		}
+
+		if _, ok := podIPsByNode[pod.Spec.NodeName]; !ok {
+			podIPsByNode[pod.Spec.NodeName] = pod.Status.PodIP
+		}
+	}
+
+	var podIPs []string
+	var missing []string
+	for _, node := range nodes {
+		ip, ok := podIPsByNode[node.Name]
+		if !ok {
+			missing = append(missing, node.Name)
+			continue
+		}
+
+		podIPs = append(podIPs, ip)
+	}
+
+	if err := b.checkMissing("node exporter pods", len(nodes), missing); err != nil {
+		return nil, err
+	}
+
+	return podIPs, nil
+}
+
+// getNodeExporterTargetInstancesOnNodes returns the instance label of each
+// active Prometheus target whose host is the name or an address of one of the
+// given nodes
+func (b Backend) getNodeExporterTargetInstancesOnNodes(nodes []*corev1.Node) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), targetsDiscoveryTimeout)
+	defer cancel()
+
+	targets, err := b.prometheus.Targets(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting prometheus targets")
+	}
+
+	nodeNamesByHost := make(map[string]string)
+	for _, node := range nodes {
+		nodeNamesByHost[node.Name] = node.Name
+		for _, a := range node.Status.Addresses {
+			nodeNamesByHost[a.Address] = node.Name
+		}
+	}
+
+	instancesByNode := make(map[string][]string)
+	for _, target := range targets.Active {
+		if b.discovery.job != "" && string(target.Labels["job"]) != b.discovery.job {
+			continue
+		}
+
+		instance := string(target.Labels[instanceLabel])
+		host, _, err := net.SplitHostPort(instance)
+		if err != nil {
+			// No port
+			host = instance
+		}
+
+		if name, ok := nodeNamesByHost[host]; ok {
+			instancesByNode[name] = append(instancesByNode[name], instance)
+		}
+	}
+
+	var instances []string
+	var missing []string
+	for _, node := range nodes {
+		nodeInstances, ok := instancesByNode[node.Name]
+		if !ok {
+			missing = append(missing, node.Name)
+			continue
+		}
+
+		instances = append(instances, nodeInstances...)
+	}
+
+	if err := b.checkMissing("node exporter targets", len(nodes), missing); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+// checkMissing returns an error if more nodes are missing an exporter than
+// tolerated, or if all nodes are missing one. Otherwise, missing exporters are
+// logged and tolerated.
+func (b Backend) checkMissing(what string, numNodes int, missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(missing) > b.discovery.maxMissing || len(missing) == numNodes {
+		return errors.Errorf("found %s for %d of %d nodes (missing on %s)",
+			what, numNodes-len(missing), numNodes, strings.Join(missing, ", "))
+	}
+
+	log.Infof("Prometheus backend: ignoring %d nodes missing %s: %s",
+		len(missing), what, strings.Join(missing, ", "))
+
+	return nil
+}
+
+func podIPsSelector(podIPs []string) exporterSelector {
+	return exporterSelector{
+		Label: instanceLabel,
+		Regex: buildPodIPsRegex(podIPs),
+	}
+}
+
+func buildPodIPsRegex(podIPs []string) string {
+	var regex string
+	for i, ip := range podIPs {
+		regex += fmt.Sprintf("%s:.*", ip)
+		if i != len(podIPs)-1 {
+			regex += "|"
+		}
+	}
+
+	return regex
+}
+
+// buildExactRegex returns a regex matching exactly any of the given values.
+// Backslashes are escaped so that the regex can be used in a PromQL string.
+func buildExactRegex(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quotePromQL(regexp.QuoteMeta(v))
+	}
+
+	return strings.Join(quoted, "|")
+}
@@ -0,0 +1,181 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	prometheus "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/containership/cerebral/pkg/metrics/backends/prometheus/mocks"
+)
+
+func TestDiscoveryConfigurationFromMap(t *testing.T) {
+	c, err := discoveryConfigurationFromMap(nil)
+	assert.NoError(t, err, "nil config is ok")
+	assert.Equal(t, discoveryModePods, c.mode, "mode defaulted")
+	assert.Equal(t, 0, c.maxMissing, "no missing exporters tolerated by default")
+
+	c, err = discoveryConfigurationFromMap(map[string]string{
+		"nodeExporterDiscovery":   "targets",
+		"nodeExporterJob":         "node-exporter",
+		"maxMissingNodeExporters": "2",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, discoveryModeTargets, c.mode)
+	assert.Equal(t, "node-exporter", c.job)
+	assert.Equal(t, 2, c.maxMissing)
+
+	c, err = discoveryConfigurationFromMap(map[string]string{
+		"nodeExporterPodSelector": "app=node-exporter",
+		"nodeExporterNamespace":   "monitoring",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "app=node-exporter", c.podSelector.String())
+	assert.Equal(t, "monitoring", c.podNamespace)
+
+	_, err = discoveryConfigurationFromMap(map[string]string{
+		"nodeExporterDiscovery": "dns",
+	})
+	assert.Error(t, err, "invalid mode")
+
+	_, err = discoveryConfigurationFromMap(map[string]string{
+		"nodeExporterPodSelector": "app in (",
+	})
+	assert.Error(t, err, "invalid pod selector")
+
+	_, err = discoveryConfigurationFromMap(map[string]string{
+		"maxMissingNodeExporters": "-1",
+	})
+	assert.Error(t, err, "invalid tolerance")
+}
+
+func TestGetNodeExporterPodIPsOnNodesConfigured(t *testing.T) {
+	podOnNode0 := promPodOnNode0.DeepCopy()
+	podOnNode0.Labels = map[string]string{"app": "node-exporter"}
+	podOnNode0.Namespace = "monitoring"
+
+	duplicatePodOnNode0 := podOnNode0.DeepCopy()
+	duplicatePodOnNode0.Name = "node-exporter-duplicate"
+	duplicatePodOnNode0.Status.PodIP = ""
+
+	podOnNode1 := promPodOnNode1.DeepCopy()
+	podOnNode1.Labels = map[string]string{"app": "node-exporter"}
+
+	discovery, _ := discoveryConfigurationFromMap(map[string]string{
+		"nodeExporterPodSelector": "app=node-exporter",
+		"nodeExporterNamespace":   "monitoring",
+	})
+
+	backend := Backend{
+		prometheus: &mocks.API{},
+		discovery:  discovery,
+		podLister:  buildPodLister([]corev1.Pod{*podOnNode0, *duplicatePodOnNode0, *podOnNode1}),
+	}
+
+	ips, err := backend.getNodeExporterPodIPsOnNodes([]*corev1.Node{&promNode0})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{podIP0}, ips, "configured selector and namespace, pods without IPs ignored")
+
+	_, err = backend.getNodeExporterPodIPsOnNodes([]*corev1.Node{&promNode0, &promNode1})
+	assert.Error(t, err, "pod in other namespace is not found")
+
+	backend.discovery.maxMissing = 1
+	ips, err = backend.getNodeExporterPodIPsOnNodes([]*corev1.Node{&promNode0, &promNode1})
+	assert.NoError(t, err, "missing exporter is tolerated")
+	assert.Equal(t, []string{podIP0}, ips)
+
+	_, err = backend.getNodeExporterPodIPsOnNodes([]*corev1.Node{&promNode1})
+	assert.Error(t, err, "all exporters missing is never tolerated")
+}
+
+func TestGetExporterSelectorTargets(t *testing.T) {
+	node0 := promNode0.DeepCopy()
+	node0.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+	}
+
+	mockProm := mocks.API{}
+	mockProm.On("Targets", mock.Anything).Return(prometheus.TargetsResult{
+		Active: []prometheus.ActiveTarget{
+			{
+				Labels: model.LabelSet{
+					"instance": "10.0.0.1:9100",
+					"job":      "node-exporter",
+				},
+			},
+			{
+				Labels: model.LabelSet{
+					"instance": "10.0.0.1:8080",
+					"job":      "kubelet",
+				},
+			},
+			{
+				Labels: model.LabelSet{
+					"instance": "prom-1",
+					"job":      "node-exporter",
+				},
+			},
+		},
+	}, nil)
+
+	backend := Backend{
+		prometheus: &mockProm,
+		discovery: discoveryConfiguration{
+			mode: discoveryModeTargets,
+			job:  "node-exporter",
+		},
+	}
+
+	selector, err := backend.getExporterSelector([]*corev1.Node{node0, &promNode1})
+	assert.NoError(t, err)
+	assert.Equal(t, instanceLabel, selector.Label)
+	assert.Equal(t, `10\\.0\\.0\\.1:9100|prom-1`, selector.Regex, "matched by node IP and name")
+
+	_, err = backend.getExporterSelector([]*corev1.Node{node0, &otherNode0})
+	assert.Error(t, err, "node without a target")
+
+	backend.discovery.job = ""
+	selector, err = backend.getExporterSelector([]*corev1.Node{node0})
+	assert.NoError(t, err)
+	assert.Equal(t, `10\\.0\\.0\\.1:9100|10\\.0\\.0\\.1:8080`, selector.Regex, "all jobs if none configured")
+}
+
+func TestGetExporterSelectorLabel(t *testing.T) {
+	backend := Backend{
+		discovery: discoveryConfiguration{
+			mode: discoveryModeLabel,
+		},
+	}
+
+	selector, err := backend.getExporterSelector([]*corev1.Node{&promNode0, &promNode1})
+	assert.NoError(t, err)
+	assert.Equal(t, exporterSelector{Label: "node", Regex: "prom-0|prom-1"}, selector, "node label by default")
+
+	backend.discovery.nodeLabel = "kubernetes_node"
+	selector, err = backend.getExporterSelector([]*corev1.Node{&promNode0})
+	assert.NoError(t, err)
+	assert.Equal(t, exporterSelector{Label: "kubernetes_node", Regex: "prom-0"}, selector)
+
+	query, err := buildQuery(cpuQueryTemplate, selector, nil, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err)
+	assert.Contains(t, query, "{mode='idle',kubernetes_node=~'prom-0'}", "selector is used in query")
+	assert.Contains(t, query, "100 - (\n\tavg(", "non-parameterized aggregation over all cores")
+
+	query, err = buildQuery(cpuQueryTemplate, selector, nil, defaultMetricNames, map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "2",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "topk(2, \n\t100 - (\n\t\tavg by (kubernetes_node) (", "parameterized aggregation over per-node utilization")
+}
+
+func TestBuildExactRegex(t *testing.T) {
+	assert.Empty(t, buildExactRegex(nil))
+	assert.Equal(t, "a|b", buildExactRegex([]string{"a", "b"}))
+	assert.Equal(t, `node\\.example\\.com`, buildExactRegex([]string{"node.example.com"}), "escaped for PromQL strings")
+}
@@ -0,0 +1,274 @@
+package prometheus
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/containership/cluster-manager/pkg/log"
+)
+
+const (
+	// How long detected metric names are used before detecting them again,
+	// e.g. in case the node exporter is upgraded
+	metricNamesRedetectInterval = 10 * time.Minute
+	// How long to wait before detecting again after detection fails
+	metricNamesRetryInterval = time.Minute
+	// How far back to look for series when detecting metric names
+	metricNamesLookback = 5 * time.Minute
+	metricNamesTimeout  = 10 * time.Second
+)
+
+// Status detail keys reported for the detected metric names
+const (
+	statusMetricNamesDetectedAt = "metricNamesDetectedAt"
+	statusMetricNamesError      = "metricNamesDetectionError"
+)
+
+// metricNames are the node exporter metric names used by the built-in
+// queries
+type metricNames struct {
+	NodeCPU             string
+	NodeMemoryAvailable string
+	NodeMemoryTotal     string
+	NodeFilesystemAvail string
+	NodeFilesystemSize  string
+	NodeNetworkReceive  string
+	NodeNetworkTransmit string
+}
+
+// metricNameCandidates are the candidate names for one of the metricNames in
+// order of preference. Most names changed in node exporter 0.16.0 to include
+// base units.
+type metricNameCandidates struct {
+	// statusKey is the status detail key reporting the detected name
+	statusKey string
+	names     []string
+	// matchers are extra label matchers used to limit the number of series
+	// returned when detecting the name
+	matchers string
+	field    func(*metricNames) *string
+}
+
+var metricNameCandidatesList = []metricNameCandidates{
+	{
+		statusKey: "nodeCPUMetricName",
+		names:     validNodeCPUMetricNames,
+		matchers:  "{mode='idle'}",
+		field:     func(n *metricNames) *string { return &n.NodeCPU },
+	},
+	{
+		statusKey: "nodeMemoryAvailableMetricName",
+		names:     []string{"node_memory_MemAvailable_bytes", "node_memory_MemAvailable"},
+		field:     func(n *metricNames) *string { return &n.NodeMemoryAvailable },
+	},
+	{
+		statusKey: "nodeMemoryTotalMetricName",
+		names:     []string{"node_memory_MemTotal_bytes", "node_memory_MemTotal"},
+		field:     func(n *metricNames) *string { return &n.NodeMemoryTotal },
+	},
+	{
+		statusKey: "nodeFilesystemAvailMetricName",
+		names:     []string{"node_filesystem_avail_bytes", "node_filesystem_avail"},
+		matchers:  "{mountpoint='/'}",
+		field:     func(n *metricNames) *string { return &n.NodeFilesystemAvail },
+	},
+	{
+		statusKey: "nodeFilesystemSizeMetricName",
+		names:     []string{"node_filesystem_size_bytes", "node_filesystem_size"},
+		matchers:  "{mountpoint='/'}",
+		field:     func(n *metricNames) *string { return &n.NodeFilesystemSize },
+	},
+	{
+		statusKey: "nodeNetworkReceiveMetricName",
+		names:     []string{"node_network_receive_bytes_total", "node_network_receive_bytes"},
+		matchers:  "{device='lo'}",
+		field:     func(n *metricNames) *string { return &n.NodeNetworkReceive },
+	},
+	{
+		statusKey: "nodeNetworkTransmitMetricName",
+		names:     []string{"node_network_transmit_bytes_total", "node_network_transmit_bytes"},
+		matchers:  "{device='lo'}",
+		field:     func(n *metricNames) *string { return &n.NodeNetworkTransmit },
+	},
+}
+
+// Default to the older metric names until detection succeeds, since that's
+// what was historically assumed
+var defaultMetricNames = func() metricNames {
+	var names metricNames
+	for _, c := range metricNameCandidatesList {
+		*c.field(&names) = c.names[len(c.names)-1]
+	}
+
+	return names
+}()
+
+// metricNameDetector detects which node exporter metric names exist in
+// Prometheus in the background. It's safe for concurrent use.
+type metricNameDetector struct {
+	sync.Mutex
+
+	names      metricNames
+	detectedAt time.Time
+	err        error
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// newMetricNameDetector returns a detector that reports the default names
+// until run detects them
+func newMetricNameDetector() *metricNameDetector {
+	return &metricNameDetector{
+		names:  defaultMetricNames,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// run detects the metric names immediately and then periodically until stop
+// is called. Detection is retried sooner if it fails.
+func (d *metricNameDetector) run(b Backend) {
+	for {
+		interval := metricNamesRedetectInterval
+		if err := d.detect(b); err != nil {
+			interval = metricNamesRetryInterval
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-d.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// stop stops run. It's safe to call more than once.
+func (d *metricNameDetector) stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+}
+
+// get returns the most recently detected metric names without blocking on
+// Prometheus. If detection has not succeeded then the last known names
+// (initially the defaults) are returned. A nil detector always returns the
+// defaults.
+func (d *metricNameDetector) get() metricNames {
+	if d == nil {
+		return defaultMetricNames
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	return d.names
+}
+
+// detect detects the metric names and records the result, returning any
+// detection error. The lock is not held while querying Prometheus.
+func (d *metricNameDetector) detect(b Backend) error {
+	d.Lock()
+	current := d.names
+	d.Unlock()
+
+	names, err := detectMetricNames(b, current)
+	if err != nil {
+		log.Errorf("Prometheus backend failed to detect some node exporter metric names, using %+v: %s", names, err)
+	}
+
+	if names != current {
+		log.Infof("Prometheus backend detected node exporter metric names %+v", names)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.detectedAt = time.Now()
+	d.err = err
+	d.names = names
+
+	return err
+}
+
+// status returns the status details describing the detected metric names
+func (d *metricNameDetector) status() map[string]string {
+	d.Lock()
+	defer d.Unlock()
+
+	status := make(map[string]string)
+	for _, c := range metricNameCandidatesList {
+		status[c.statusKey] = *c.field(&d.names)
+	}
+
+	if !d.detectedAt.IsZero() {
+		status[statusMetricNamesDetectedAt] = d.detectedAt.UTC().Format(time.RFC3339)
+	}
+
+	if d.err != nil {
+		status[statusMetricNamesError] = d.err.Error()
+	}
+
+	return status
+}
+
+// detectMetricNames uses the series API to find which of the candidate
+// metric names have recent series. Names that aren't found keep their
+// current value and are reported in the returned error.
+func detectMetricNames(b Backend, current metricNames) (metricNames, error) {
+	var matches []string
+	for _, c := range metricNameCandidatesList {
+		for _, name := range c.names {
+			matches = append(matches, name+c.matchers)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricNamesTimeout)
+	defer cancel()
+
+	end := time.Now()
+	series, err := b.prometheus.Series(ctx, matches, end.Add(-metricNamesLookback), end)
+	if err != nil {
+		return current, errors.Wrap(err, "querying series")
+	}
+
+	found := make(map[string]bool)
+	for _, s := range series {
+		found[string(s[model.MetricNameLabel])] = true
+	}
+
+	names := current
+	var missing []string
+	for _, c := range metricNameCandidatesList {
+		name, ok := firstFound(c.names, found)
+		if !ok {
+			missing = append(missing, strings.Join(c.names, " or "))
+			continue
+		}
+
+		*c.field(&names) = name
+	}
+
+	if len(missing) > 0 {
+		return names, errors.Errorf("no series found for %s", strings.Join(missing, ", "))
+	}
+
+	return names, nil
+}
+
+func firstFound(names []string, found map[string]bool) (string, bool) {
+	for _, name := range names {
+		if found[name] {
+			return name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,168 @@
+package prometheus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/containership/cerebral/pkg/metrics/backends/prometheus/mocks"
+)
+
+func buildSeries(names ...string) []model.LabelSet {
+	var series []model.LabelSet
+	for _, name := range names {
+		series = append(series, model.LabelSet{
+			model.MetricNameLabel: model.LabelValue(name),
+			"instance":            "10.0.0.1:9100",
+		})
+	}
+
+	return series
+}
+
+var (
+	newMetricNames = []string{
+		"node_cpu_seconds_total",
+		"node_memory_MemAvailable_bytes",
+		"node_memory_MemTotal_bytes",
+		"node_filesystem_avail_bytes",
+		"node_filesystem_size_bytes",
+		"node_network_receive_bytes_total",
+		"node_network_transmit_bytes_total",
+	}
+
+	oldMetricNames = []string{
+		"node_cpu",
+		"node_memory_MemAvailable",
+		"node_memory_MemTotal",
+		"node_filesystem_avail",
+		"node_filesystem_size",
+		"node_network_receive_bytes",
+		"node_network_transmit_bytes",
+	}
+)
+
+func TestDetectMetricNames(t *testing.T) {
+	mockProm := mocks.API{}
+	backend := Backend{
+		prometheus: &mockProm,
+	}
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("some prometheus error")).Once()
+	names, err := detectMetricNames(backend, defaultMetricNames)
+	assert.Error(t, err, "error when prometheus errors")
+	assert.Equal(t, defaultMetricNames, names, "current names kept on error")
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries("node_cpu_seconds_total", "node_memory_MemAvailable_bytes"), nil).Once()
+	names, err = detectMetricNames(backend, defaultMetricNames)
+	assert.Error(t, err, "error when a metric is missing")
+	assert.Contains(t, err.Error(), "node_memory_MemTotal_bytes or node_memory_MemTotal", "missing metric reported")
+	assert.Equal(t, "node_cpu_seconds_total", names.NodeCPU, "found metric names are still detected")
+	assert.Equal(t, defaultMetricNames.NodeMemoryTotal, names.NodeMemoryTotal, "current name kept for missing metric")
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries(newMetricNames...), nil).Once()
+	names, err = detectMetricNames(backend, defaultMetricNames)
+	assert.NoError(t, err)
+	assert.Equal(t, metricNames{
+		NodeCPU:             "node_cpu_seconds_total",
+		NodeMemoryAvailable: "node_memory_MemAvailable_bytes",
+		NodeMemoryTotal:     "node_memory_MemTotal_bytes",
+		NodeFilesystemAvail: "node_filesystem_avail_bytes",
+		NodeFilesystemSize:  "node_filesystem_size_bytes",
+		NodeNetworkReceive:  "node_network_receive_bytes_total",
+		NodeNetworkTransmit: "node_network_transmit_bytes_total",
+	}, names, "new metric names detected")
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries(append(oldMetricNames, "node_cpu")...), nil).Once()
+	names, err = detectMetricNames(backend, metricNames{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultMetricNames, names, "old metric names detected")
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries(append(oldMetricNames, newMetricNames...)...), nil).Once()
+	names, err = detectMetricNames(backend, defaultMetricNames)
+	assert.NoError(t, err)
+	assert.Equal(t, "node_cpu_seconds_total", names.NodeCPU, "newer metric names preferred")
+}
+
+func TestMetricNameDetector(t *testing.T) {
+	var nilDetector *metricNameDetector
+	assert.Equal(t, defaultMetricNames, nilDetector.get(), "nil detector uses defaults")
+
+	mockProm := mocks.API{}
+	detector := newMetricNameDetector()
+	backend := Backend{
+		prometheus:  &mockProm,
+		metricNames: detector,
+	}
+
+	assert.Equal(t, defaultMetricNames, detector.get(), "defaults used before detection")
+	assert.NotContains(t, backend.Status(), statusMetricNamesDetectedAt, "no detection time before detection")
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("some prometheus error")).Once()
+	assert.Error(t, detector.detect(backend))
+	assert.Equal(t, defaultMetricNames, detector.get(), "defaults used when detection fails")
+	status := backend.Status()
+	assert.Equal(t, defaultMetricNames.NodeCPU, status["nodeCPUMetricName"])
+	assert.Contains(t, status[statusMetricNamesError], "some prometheus error", "detection error reported")
+
+	// Getting the names never queries Prometheus
+	detector.get()
+	mockProm.AssertNumberOfCalls(t, "Series", 1)
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries(newMetricNames...), nil).Once()
+	assert.NoError(t, detector.detect(backend))
+	names := detector.get()
+	assert.Equal(t, "node_cpu_seconds_total", names.NodeCPU, "detected names used")
+
+	status = backend.Status()
+	assert.Equal(t, "node_cpu_seconds_total", status["nodeCPUMetricName"])
+	assert.Equal(t, "node_memory_MemAvailable_bytes", status["nodeMemoryAvailableMetricName"])
+	assert.Equal(t, "node_network_transmit_bytes_total", status["nodeNetworkTransmitMetricName"])
+	assert.NotEmpty(t, status[statusMetricNamesDetectedAt])
+	assert.NotContains(t, status, statusMetricNamesError, "error cleared after successful detection")
+}
+
+func TestMetricNameDetectorRun(t *testing.T) {
+	mockProm := mocks.API{}
+	detector := newMetricNameDetector()
+	backend := Backend{
+		prometheus:  &mockProm,
+		metricNames: detector,
+	}
+
+	mockProm.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(buildSeries(newMetricNames...), nil)
+
+	done := make(chan struct{})
+	go func() {
+		detector.run(backend)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for detector.get().NodeCPU != "node_cpu_seconds_total" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "node_cpu_seconds_total", detector.get().NodeCPU, "names detected in the background")
+
+	assert.NoError(t, backend.Close())
+	assert.NoError(t, backend.Close(), "closing more than once is allowed")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("run did not return after stop")
+	}
+}
@@ -2,9 +2,17 @@ package prometheus
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/containership/cerebral/pkg/metrics"
 )
 
 // Metric is a metric exposed by this backend
@@ -17,6 +25,18 @@ const (
 	MetricMemoryPercentUtilization
 	// MetricCustom is used to perform a custom Prometheus query
 	MetricCustom
+	// MetricDiskPercentUtilization is used to gather info about the filesystem
+	// usage of nodes
+	MetricDiskPercentUtilization
+	// MetricNetworkBytesPerSecond is used to gather info about the network
+	// throughput of nodes
+	MetricNetworkBytesPerSecond
+	// MetricLoadAveragePerCore is used to gather info about the load average
+	// of nodes relative to their number of cores
+	MetricLoadAveragePerCore
+	// MetricPodsPerNode is used to gather info about the number of pods
+	// running on nodes. It requires kube-state-metrics.
+	MetricPodsPerNode
 )
 
 // String is a stringer for Metric
@@ -28,6 +48,14 @@ func (m Metric) String() string {
 		return "memory_percent_utilization"
 	case MetricCustom:
 		return "custom"
+	case MetricDiskPercentUtilization:
+		return "disk_percent_utilization"
+	case MetricNetworkBytesPerSecond:
+		return "network_bytes_per_second"
+	case MetricLoadAveragePerCore:
+		return "load_average_per_core"
+	case MetricPodsPerNode:
+		return "pods_per_node"
 	}
 
 	return "unknown"
@@ -52,35 +80,119 @@ var validRangeRegex = regexp.MustCompile(`^\d+[smhdwy]$`)
 
 const defaultAggregation = "avg"
 const defaultRange = "1m"
+const defaultMountpoint = "/"
+const defaultNetworkDirection = "total"
+const defaultLoadPeriod = "5"
 
-var validNodeCPUMetricNames = []string{
-	"node_cpu_seconds_total", // For Prometheus 0.16.0+
-	"node_cpu",               // For Prometheus older than 0.16.0
+// By default virtual network devices are excluded so that traffic isn't
+// counted more than once
+const defaultNetworkDeviceExcludeRegex = "lo|veth.*|docker.*|cali.*|flannel.*|cni.*|cilium.*|weave.*|vxlan.*|tun.*|virbr.*"
+
+var validNetworkDirections = []string{
+	"receive",
+	"transmit",
+	"total",
 }
 
-// Default to the older metric name for now, since Containership CKE clusters
-// are still launching with an older version for now.
-// TODO check the node exporter image version, handle this automatically, and
-// remove this config option.
-var defaultNodeCPUMetricName = validNodeCPUMetricNames[1]
+var validLoadPeriods = []string{
+	"1",
+	"5",
+	"15",
+}
+
+// Values substituted into single quoted PromQL strings must not be able to
+// escape the quotes. Backslashes are escaped by quotePromQL.
+var validLabelValueRegex = regexp.MustCompile(`^[^'\n]*$`)
+
+var validNodeCPUMetricNames = []string{
+	"node_cpu_seconds_total", // For node exporter 0.16.0+
+	"node_cpu",               // For node exporter older than 0.16.0
+}
 
 // TODO consider splitting into multiple types instead of overloading this
 // single struct and ignoring irrelevant fields
 type metricConfiguration struct {
 	// -- Generic
 	Aggregation string `json:"aggregation"`
-	Range       string `json:"range"`
+	// AggregationParameter is the parameter of aggregations that require
+	// one: k for topk and bottomk, φ for quantile and the output label for
+	// count_values
+	AggregationParameter string `json:"aggregationParameter"`
+	Range                string `json:"range"`
 
 	// -- CPU
-	// NodeCPUMetricName specifies the underlying Prometheus metric name for the cpu metric
-	// This is mainly required due to the name changing in Prometheus 0.16.0
+	// NodeCPUMetricName specifies the underlying Prometheus metric name for
+	// the cpu metric. It defaults to the detected name and is only required
+	// if detection fails.
 	NodeCPUMetricName string `json:"cpuMetricName"`
 
+	// -- Disk
+	// Mountpoint is the filesystem mountpoint to query
+	Mountpoint string `json:"mountpoint"`
+
+	// -- Network
+	// Direction is the direction of traffic to query: receive, transmit or
+	// total
+	Direction string `json:"direction"`
+	// DeviceRegex selects the network devices to query. If empty, all
+	// devices except those matching the default exclude regex are queried.
+	DeviceRegex string `json:"deviceRegex"`
+
+	// -- Load
+	// LoadPeriod is the load average period in minutes: 1, 5 or 15
+	LoadPeriod string `json:"loadPeriod"`
+
 	// -- Not user-specifiable
 	// Unfortunately we're required to export these fields for use in templates
+	// AggregationArg is the rendered AggregationParameter, including the
+	// trailing separator, to be placed immediately after the opening
+	// parenthesis of the aggregation
+	AggregationArg string `json:"-"`
+	// NodeMemoryAvailableMetricName and NodeMemoryTotalMetricName specify the
+	// underlying Prometheus metric names for the memory metric
+	NodeMemoryAvailableMetricName string `json:"-"`
+	NodeMemoryTotalMetricName     string `json:"-"`
+	// The underlying Prometheus metric names for the disk and network metrics
+	NodeFilesystemAvailMetricName string `json:"-"`
+	NodeFilesystemSizeMetricName  string `json:"-"`
+	NodeNetworkReceiveMetricName  string `json:"-"`
+	NodeNetworkTransmitMetricName string `json:"-"`
+	// NetworkDeviceMatcher is the label matcher selecting network devices
+	NetworkDeviceMatcher string `json:"-"`
+	// NodeNamesRegex matches the names of the nodes being queried, e.g. for
+	// kube-state-metrics series
+	NodeNamesRegex string `json:"-"`
+	// InstanceLabel and InstanceRegex select the node exporter series for
+	// the nodes being queried
+	InstanceLabel string
+	InstanceRegex string
+	// PodIPsRegex is the same as InstanceRegex. It is kept so that existing
+	// custom queries continue to work.
 	PodIPsRegex string
 }
 
+// setMetricNames sets the metric names to use unless overridden by the
+// configuration. It must be called before defaultAndValidate.
+func (c *metricConfiguration) setMetricNames(names metricNames) {
+	c.NodeCPUMetricName = names.NodeCPU
+	c.NodeMemoryAvailableMetricName = names.NodeMemoryAvailable
+	c.NodeMemoryTotalMetricName = names.NodeMemoryTotal
+	c.NodeFilesystemAvailMetricName = names.NodeFilesystemAvail
+	c.NodeFilesystemSizeMetricName = names.NodeFilesystemSize
+	c.NodeNetworkReceiveMetricName = names.NodeNetworkReceive
+	c.NodeNetworkTransmitMetricName = names.NodeNetworkTransmit
+}
+
+func (c *metricConfiguration) setNodeNames(nodeNames []string) {
+	c.NodeNamesRegex = buildExactRegex(nodeNames)
+}
+
+func (c *metricConfiguration) setExporterSelector(exporters exporterSelector) {
+	c.InstanceLabel = exporters.Label
+	c.InstanceRegex = exporters.Regex
+	c.PodIPsRegex = exporters.Regex
+}
+
 // defaults and validates the metricConfiguration. Intended to be called with an
 // empty struct that we'll fill in here using the caller-provided configuration.
 func (c *metricConfiguration) defaultAndValidate(configuration map[string]string) error {
@@ -100,6 +212,20 @@ func (c *metricConfiguration) defaultAndValidate(configuration map[string]string
 		return err
 	}
 
+	c.defaultNodeMetricNames()
+
+	if err := c.defaultAndValidateMountpoint(); err != nil {
+		return err
+	}
+
+	if err := c.defaultAndValidateNetwork(); err != nil {
+		return err
+	}
+
+	if err := c.defaultAndValidateLoadPeriod(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -108,13 +234,98 @@ func (c *metricConfiguration) defaultAndValidateAggregation() error {
 		c.Aggregation = defaultAggregation
 	}
 
-	for _, a := range validAggregations {
-		if a == c.Aggregation {
-			return nil
+	if !contains(validAggregations, c.Aggregation) {
+		return errors.Errorf("invalid aggregation %s", c.Aggregation)
+	}
+
+	return c.validateAggregationParameter()
+}
+
+// validateAggregationParameter validates the AggregationParameter for the
+// Aggregation and renders it into AggregationArg
+func (c *metricConfiguration) validateAggregationParameter() error {
+	p := c.AggregationParameter
+	if err := metrics.ValidateAggregationParameter(c.Aggregation, p); err != nil {
+		return err
+	}
+
+	switch c.Aggregation {
+	case metrics.AggregationTopK, metrics.AggregationBottomK:
+		k, _ := strconv.Atoi(p)
+		c.AggregationArg = fmt.Sprintf("%d, ", k)
+
+	case metrics.AggregationQuantile:
+		phi, _ := strconv.ParseFloat(p, 64)
+		c.AggregationArg = fmt.Sprintf("%s, ", strconv.FormatFloat(phi, 'f', -1, 64))
+
+	case metrics.AggregationCountValues:
+		c.AggregationArg = fmt.Sprintf("'%s', ", p)
+	}
+
+	return nil
+}
+
+const defaultTimeReduction = "avg"
+const defaultQueryStep = time.Minute
+
+// resultConfiguration configures how a query is performed and how its result
+// is reduced to a single value
+type resultConfiguration struct {
+	// reduction reduces results with multiple series to a single value. If
+	// empty, such results are an error.
+	reduction string
+	// timeReduction reduces the values of each series of a matrix over time
+	timeReduction string
+
+	// If queryRange is set, a range query is performed over this duration
+	// ending now, returning a matrix
+	queryRange time.Duration
+	queryStep  time.Duration
+}
+
+// resultConfigurationFromMap builds a resultConfiguration from the metric
+// configuration. Reductions are one of the client-side aggregations supported
+// by the metrics package.
+func resultConfigurationFromMap(configuration map[string]string) (resultConfiguration, error) {
+	c := resultConfiguration{
+		reduction:     configuration["reduction"],
+		timeReduction: configuration["timeReduction"],
+		queryStep:     defaultQueryStep,
+	}
+
+	if _, ok := configuration["reduction"]; !ok {
+		c.reduction = metrics.DefaultReductions[configuration["aggregation"]]
+	} else if !metrics.IsValidAggregation(c.reduction) {
+		return c, errors.Errorf("invalid reduction %s", c.reduction)
+	}
+
+	if c.timeReduction == "" {
+		c.timeReduction = defaultTimeReduction
+	}
+
+	if !metrics.IsValidAggregation(c.timeReduction) {
+		return c, errors.Errorf("invalid time reduction %s", c.timeReduction)
+	}
+
+	if s, ok := configuration["queryRange"]; ok {
+		d, err := model.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return c, errors.Errorf("invalid query range %s", s)
 		}
+
+		c.queryRange = time.Duration(d)
 	}
 
-	return errors.Errorf("invalid aggregation %s", c.Aggregation)
+	if s, ok := configuration["queryStep"]; ok {
+		d, err := model.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return c, errors.Errorf("invalid query step %s", s)
+		}
+
+		c.queryStep = time.Duration(d)
+	}
+
+	return c, nil
 }
 
 func (c *metricConfiguration) defaultAndValidateRange() error {
@@ -131,7 +342,7 @@ func (c *metricConfiguration) defaultAndValidateRange() error {
 
 func (c *metricConfiguration) defaultAndValidateNodeCPUMetricName() error {
 	if c.NodeCPUMetricName == "" {
-		c.NodeCPUMetricName = defaultNodeCPUMetricName
+		c.NodeCPUMetricName = defaultMetricNames.NodeCPU
 	}
 
 	for _, n := range validNodeCPUMetricNames {
@@ -142,3 +353,83 @@ func (c *metricConfiguration) defaultAndValidateNodeCPUMetricName() error {
 
 	return errors.Errorf("invalid node cpu metric name %s", c.NodeCPUMetricName)
 }
+
+func (c *metricConfiguration) defaultNodeMetricNames() {
+	defaultString(&c.NodeMemoryAvailableMetricName, defaultMetricNames.NodeMemoryAvailable)
+	defaultString(&c.NodeMemoryTotalMetricName, defaultMetricNames.NodeMemoryTotal)
+	defaultString(&c.NodeFilesystemAvailMetricName, defaultMetricNames.NodeFilesystemAvail)
+	defaultString(&c.NodeFilesystemSizeMetricName, defaultMetricNames.NodeFilesystemSize)
+	defaultString(&c.NodeNetworkReceiveMetricName, defaultMetricNames.NodeNetworkReceive)
+	defaultString(&c.NodeNetworkTransmitMetricName, defaultMetricNames.NodeNetworkTransmit)
+}
+
+func (c *metricConfiguration) defaultAndValidateMountpoint() error {
+	if c.Mountpoint == "" {
+		c.Mountpoint = defaultMountpoint
+	}
+
+	if !validLabelValueRegex.MatchString(c.Mountpoint) {
+		return errors.Errorf("invalid mountpoint %s", c.Mountpoint)
+	}
+
+	c.Mountpoint = quotePromQL(c.Mountpoint)
+
+	return nil
+}
+
+func (c *metricConfiguration) defaultAndValidateNetwork() error {
+	if c.Direction == "" {
+		c.Direction = defaultNetworkDirection
+	}
+
+	if !contains(validNetworkDirections, c.Direction) {
+		return errors.Errorf("invalid direction %s", c.Direction)
+	}
+
+	if c.DeviceRegex == "" {
+		c.NetworkDeviceMatcher = fmt.Sprintf("device!~'%s'", quotePromQL(defaultNetworkDeviceExcludeRegex))
+		return nil
+	}
+
+	if _, err := regexp.Compile(c.DeviceRegex); err != nil || !validLabelValueRegex.MatchString(c.DeviceRegex) {
+		return errors.Errorf("invalid device regex %s", c.DeviceRegex)
+	}
+
+	c.NetworkDeviceMatcher = fmt.Sprintf("device=~'%s'", quotePromQL(c.DeviceRegex))
+
+	return nil
+}
+
+func (c *metricConfiguration) defaultAndValidateLoadPeriod() error {
+	if c.LoadPeriod == "" {
+		c.LoadPeriod = defaultLoadPeriod
+	}
+
+	if !contains(validLoadPeriods, c.LoadPeriod) {
+		return errors.Errorf("invalid load period %s", c.LoadPeriod)
+	}
+
+	return nil
+}
+
+// quotePromQL escapes backslashes so that s can be used in a quoted PromQL
+// string
+func quotePromQL(s string) string {
+	return strings.Replace(s, `\`, `\\`, -1)
+}
+
+func defaultString(s *string, def string) {
+	if *s == "" {
+		*s = def
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
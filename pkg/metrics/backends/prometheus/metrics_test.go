@@ -2,6 +2,7 @@ package prometheus
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,6 +13,10 @@ func TestDefaultAndValidate(t *testing.T) {
 	assert.NoError(t, err, "nil config provided is ok")
 	assert.Equal(t, defaultAggregation, c.Aggregation, "aggregation defaulted")
 	assert.Equal(t, defaultRange, c.Range, "range defaulted")
+	assert.Equal(t, defaultMountpoint, c.Mountpoint, "mountpoint defaulted")
+	assert.Equal(t, defaultNetworkDirection, c.Direction, "direction defaulted")
+	assert.Equal(t, defaultLoadPeriod, c.LoadPeriod, "load period defaulted")
+	assert.Equal(t, defaultMetricNames.NodeFilesystemAvail, c.NodeFilesystemAvailMetricName, "filesystem metric name defaulted")
 
 	c = metricConfiguration{}
 	err = c.defaultAndValidate(map[string]string{})
@@ -27,7 +32,7 @@ func TestDefaultAndValidate(t *testing.T) {
 	assert.NoError(t, err, "good config")
 	assert.Equal(t, "max", c.Aggregation, "aggregation not defaulted if provided")
 	assert.Equal(t, "5m", c.Range, "range not defaulted if provided")
-	assert.Equal(t, defaultNodeCPUMetricName, c.NodeCPUMetricName, "cpu metric name defaulted if not provided")
+	assert.Equal(t, defaultMetricNames.NodeCPU, c.NodeCPUMetricName, "cpu metric name defaulted if not provided")
 
 	c = metricConfiguration{}
 	err = c.defaultAndValidate(map[string]string{
@@ -36,6 +41,20 @@ func TestDefaultAndValidate(t *testing.T) {
 	assert.NoError(t, err, "good config")
 	assert.Equal(t, "node_cpu", c.NodeCPUMetricName, "cpu metric name not defaulted if provided")
 
+	c = metricConfiguration{}
+	c.setMetricNames(metricNames{NodeCPU: "node_cpu_seconds_total"})
+	err = c.defaultAndValidate(map[string]string{})
+	assert.NoError(t, err, "good config")
+	assert.Equal(t, "node_cpu_seconds_total", c.NodeCPUMetricName, "detected cpu metric name used if not provided")
+
+	c = metricConfiguration{}
+	c.setMetricNames(metricNames{NodeCPU: "node_cpu_seconds_total"})
+	err = c.defaultAndValidate(map[string]string{
+		"cpuMetricName": "node_cpu",
+	})
+	assert.NoError(t, err, "good config")
+	assert.Equal(t, "node_cpu", c.NodeCPUMetricName, "provided cpu metric name overrides detected name")
+
 	c = metricConfiguration{}
 	err = c.defaultAndValidate(map[string]string{
 		"aggregation": "not-valid",
@@ -65,4 +84,113 @@ func TestDefaultAndValidate(t *testing.T) {
 		"cpuMetricName": "bad",
 	})
 	assert.Error(t, err, "bad CPU metric name")
+
+	c = metricConfiguration{}
+	err = c.defaultAndValidate(map[string]string{
+		"mountpoint": "/data",
+		"direction":  "transmit",
+		"loadPeriod": "1",
+	})
+	assert.NoError(t, err, "good disk, network and load config")
+	assert.Equal(t, "/data", c.Mountpoint, "mountpoint not defaulted if provided")
+	assert.Equal(t, "transmit", c.Direction, "direction not defaulted if provided")
+	assert.Equal(t, "1", c.LoadPeriod, "load period not defaulted if provided")
+
+	c = metricConfiguration{}
+	err = c.defaultAndValidate(map[string]string{
+		"direction": "up",
+	})
+	assert.Error(t, err, "bad direction")
+
+	c = metricConfiguration{}
+	err = c.defaultAndValidate(map[string]string{
+		"loadPeriod": "2",
+	})
+	assert.Error(t, err, "bad load period")
+
+	c = metricConfiguration{}
+	err = c.defaultAndValidate(map[string]string{
+		"aggregation":          "avg",
+		"aggregationParameter": "3",
+	})
+	assert.Error(t, err, "aggregation does not take a parameter")
+
+	for _, aggregation := range []string{"topk", "bottomk", "quantile", "count_values"} {
+		c = metricConfiguration{}
+		err = c.defaultAndValidate(map[string]string{
+			"aggregation": aggregation,
+		})
+		assert.Error(t, err, "%s requires a parameter", aggregation)
+	}
+
+	badParameters := map[string]string{
+		"topk":         "0",
+		"bottomk":      "1.5",
+		"quantile":     "1.1",
+		"count_values": "not a label",
+	}
+
+	for aggregation, parameter := range badParameters {
+		c = metricConfiguration{}
+		err = c.defaultAndValidate(map[string]string{
+			"aggregation":          aggregation,
+			"aggregationParameter": parameter,
+		})
+		assert.Error(t, err, "%s with bad parameter %s", aggregation, parameter)
+	}
+
+	c = metricConfiguration{}
+	err = c.defaultAndValidate(map[string]string{
+		"aggregation":          "bottomk",
+		"aggregationParameter": "2",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "2, ", c.AggregationArg, "parameter rendered")
+}
+
+func TestResultConfigurationFromMap(t *testing.T) {
+	c, err := resultConfigurationFromMap(map[string]string{})
+	assert.NoError(t, err)
+	assert.Empty(t, c.reduction, "no reduction by default")
+	assert.Equal(t, defaultTimeReduction, c.timeReduction, "time reduction defaulted")
+	assert.Zero(t, c.queryRange, "instant query by default")
+
+	c, err = resultConfigurationFromMap(map[string]string{
+		"aggregation": "topk",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "avg", c.reduction, "topk reduced by default")
+
+	c, err = resultConfigurationFromMap(map[string]string{
+		"aggregation":   "bottomk",
+		"reduction":     "min",
+		"timeReduction": "max",
+		"queryRange":    "1h",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "min", c.reduction, "reduction not defaulted if provided")
+	assert.Equal(t, "max", c.timeReduction, "time reduction not defaulted if provided")
+	assert.Equal(t, time.Hour, c.queryRange)
+	assert.Equal(t, defaultQueryStep, c.queryStep, "query step defaulted")
+
+	_, err = resultConfigurationFromMap(map[string]string{
+		"reduction": "quantile",
+	})
+	assert.Error(t, err, "invalid reduction")
+
+	_, err = resultConfigurationFromMap(map[string]string{
+		"timeReduction": "last",
+	})
+	assert.Error(t, err, "invalid time reduction")
+
+	_, err = resultConfigurationFromMap(map[string]string{
+		"queryRange": "forever",
+	})
+	assert.Error(t, err, "invalid query range")
+
+	_, err = resultConfigurationFromMap(map[string]string{
+		"queryRange": "1h",
+		"queryStep":  "0s",
+	})
+	assert.Error(t, err, "invalid query step")
 }
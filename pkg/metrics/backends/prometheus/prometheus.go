@@ -3,7 +3,6 @@ package prometheus
 import (
 	"bytes"
 	"context"
-	"fmt"
 	"text/template"
 	"time"
 
@@ -14,8 +13,7 @@ import (
 	"github.com/prometheus/common/model"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/kubernetes"
 	corelistersv1 "k8s.io/client-go/listers/core/v1"
 
 	"github.com/containership/cerebral/pkg/metrics"
@@ -24,36 +22,121 @@ import (
 )
 
 // Backend implements a metrics backend for Prometheus. It requires a pod
-// lister so that it can gather info about the prom-exporter pods. Pods
-// accessed via the lister must not be mutated.
+// lister so that it can gather info about the node exporter pods when using
+// pod discovery. Pods accessed via the lister must not be mutated.
 type Backend struct {
 	prometheus prometheus.API
 
+	discovery discoveryConfiguration
+
+	// metricNames detects the node exporter metric names in the background.
+	// The pointer is shared by copies of the Backend.
+	metricNames *metricNameDetector
+
 	nodeLister corelistersv1.NodeLister
 	podLister  corelistersv1.PodLister
 }
 
-// Average CPU usage across the given nodes for the given range
+// CPU usage across the given nodes for the given range. Aggregations without
+// a parameter aggregate the idle rate of every core, so e.g. max selects the
+// least utilized core, as they always have. Parameterized aggregations such as
+// topk select or group nodes and so aggregate the utilization of each node.
 const cpuQueryTemplateString = `
+{{- if .AggregationArg}}
+{{.Aggregation}}({{.AggregationArg}}
+	100 - (
+		avg by ({{.InstanceLabel}}) (
+			irate({{.NodeCPUMetricName}}{mode='idle',{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}])
+		) * 100
+	)
+)
+{{- else}}
 100 - (
 	{{.Aggregation}}(
-		irate({{.NodeCPUMetricName}}{mode='idle',instance=~'{{.PodIPsRegex}}'}[{{.Range}}])
+		irate({{.NodeCPUMetricName}}{mode='idle',{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}])
 	) * 100
-)`
+)
+{{- end}}`
 
 var cpuQueryTemplate = template.Must(template.New("cpu").Parse(cpuQueryTemplateString))
 
-// Average memory usage across the given nodes for the given range
+// Memory usage of each of the given nodes for the given range, aggregated
+// across nodes
 const memoryQueryTemplateString = `
-100 * {{.Aggregation}}(
-	1 - (avg_over_time(node_memory_MemAvailable{instance=~'{{.PodIPsRegex}}'}[{{.Range}}])
-		  / avg_over_time(node_memory_MemTotal{instance=~'{{.PodIPsRegex}}'}[{{.Range}}]))
+100 * {{.Aggregation}}({{.AggregationArg}}
+	1 - (avg_over_time({{.NodeMemoryAvailableMetricName}}{ {{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}])
+		  / avg_over_time({{.NodeMemoryTotalMetricName}}{ {{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}]))
 )`
 
 var memoryQueryTemplate = template.Must(template.New("mem").Parse(memoryQueryTemplateString))
 
-// NewClient returns a new client for talking to a Prometheus Backend, or an error
-func NewClient(address string, nodeLister corelistersv1.NodeLister, podLister corelistersv1.PodLister) (metrics.Backend, error) {
+// Filesystem usage of the given mountpoint on each of the given nodes for the
+// given range, aggregated across nodes
+const diskQueryTemplateString = `
+100 * {{.Aggregation}}({{.AggregationArg}}
+	1 - (avg_over_time({{.NodeFilesystemAvailMetricName}}{mountpoint='{{.Mountpoint}}',{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}])
+		  / avg_over_time({{.NodeFilesystemSizeMetricName}}{mountpoint='{{.Mountpoint}}',{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}]))
+)`
+
+var diskQueryTemplate = template.Must(template.New("disk").Parse(diskQueryTemplateString))
+
+// Network throughput in bytes per second of each of the given nodes for the
+// given range, aggregated across nodes
+const networkQueryTemplateString = `
+{{.Aggregation}}({{.AggregationArg}}
+{{- if ne .Direction "transmit"}}
+	sum by ({{.InstanceLabel}}) (rate({{.NodeNetworkReceiveMetricName}}{ {{.NetworkDeviceMatcher}},{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}]))
+{{- end}}
+{{- if eq .Direction "total"}}
+	+
+{{- end}}
+{{- if ne .Direction "receive"}}
+	sum by ({{.InstanceLabel}}) (rate({{.NodeNetworkTransmitMetricName}}{ {{.NetworkDeviceMatcher}},{{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}]))
+{{- end}}
+)`
+
+var networkQueryTemplate = template.Must(template.New("network").Parse(networkQueryTemplateString))
+
+// Load average of each of the given nodes divided by its number of cores,
+// averaged over the given range and aggregated across nodes
+const loadQueryTemplateString = `
+{{.Aggregation}}({{.AggregationArg}}
+	avg_over_time(node_load{{.LoadPeriod}}{ {{.InstanceLabel}}=~'{{.InstanceRegex}}'}[{{.Range}}])
+	/ on ({{.InstanceLabel}})
+	count by ({{.InstanceLabel}}) ({{.NodeCPUMetricName}}{mode='idle',{{.InstanceLabel}}=~'{{.InstanceRegex}}'})
+)`
+
+var loadQueryTemplate = template.Must(template.New("load").Parse(loadQueryTemplateString))
+
+// Number of pending or running pods on each of the given nodes, aggregated
+// across nodes. This uses kube-state-metrics series rather than node exporter
+// series. Nodes without any pods are not included in the aggregation.
+const podsQueryTemplateString = `
+{{.Aggregation}}({{.AggregationArg}}
+	count by (node) (
+		kube_pod_info{node=~'{{.NodeNamesRegex}}'}
+		* on (namespace, pod) group_left()
+		(kube_pod_status_phase{phase=~'Pending|Running'} == 1)
+	)
+)`
+
+var podsQueryTemplate = template.Must(template.New("pods").Parse(podsQueryTemplateString))
+
+// queryTemplates are the templates for the built-in metrics
+var queryTemplates = map[string]*template.Template{
+	MetricCPUPercentUtilization.String():    cpuQueryTemplate,
+	MetricMemoryPercentUtilization.String(): memoryQueryTemplate,
+	MetricDiskPercentUtilization.String():   diskQueryTemplate,
+	MetricNetworkBytesPerSecond.String():    networkQueryTemplate,
+	MetricLoadAveragePerCore.String():       loadQueryTemplate,
+	MetricPodsPerNode.String():              podsQueryTemplate,
+}
+
+// NewClient returns a new client for talking to a Prometheus Backend described
+// by the given MetricsBackend configuration, or an error. The kube clientset
+// is used to read any Secrets referenced by the configuration.
+func NewClient(configuration map[string]string, kubeclientset kubernetes.Interface, nodeLister corelistersv1.NodeLister, podLister corelistersv1.PodLister) (metrics.Backend, error) {
+	address := configuration["address"]
 	if address == "" {
 		// Under the hood, prometheusclient uses url.Parse() which allows
 		// relative URLs, etc. Empty would be allowed, so disallow it
@@ -61,6 +144,10 @@ func NewClient(address string, nodeLister corelistersv1.NodeLister, podLister co
 		return nil, errors.New("address must not be empty")
 	}
 
+	if kubeclientset == nil {
+		return nil, errors.New("kube clientset must be provided")
+	}
+
 	if nodeLister == nil {
 		return nil, errors.New("node lister must be provided")
 	}
@@ -69,8 +156,19 @@ func NewClient(address string, nodeLister corelistersv1.NodeLister, podLister co
 		return nil, errors.New("pod lister must be provided")
 	}
 
+	discovery, err := discoveryConfigurationFromMap(configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "validating node exporter discovery configuration")
+	}
+
+	roundTripper, err := buildRoundTripper(configuration, kubeclientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "validating authentication and TLS configuration")
+	}
+
 	client, err := prometheusclient.NewClient(prometheusclient.Config{
-		Address: address,
+		Address:      address,
+		RoundTripper: roundTripper,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "instantiating prometheus client")
@@ -78,11 +176,38 @@ func NewClient(address string, nodeLister corelistersv1.NodeLister, podLister co
 
 	api := prometheus.NewAPI(client)
 
-	return Backend{
-		prometheus: api,
-		nodeLister: nodeLister,
-		podLister:  podLister,
-	}, nil
+	backend := Backend{
+		prometheus:  api,
+		discovery:   discovery,
+		metricNames: newMetricNameDetector(),
+		nodeLister:  nodeLister,
+		podLister:   podLister,
+	}
+
+	// The default metric names are used until detection succeeds, so that
+	// neither creating the client nor querying blocks on detection
+	go backend.metricNames.run(backend)
+
+	return backend, nil
+}
+
+// Close implements io.Closer. It stops detecting metric names.
+func (b Backend) Close() error {
+	if b.metricNames != nil {
+		b.metricNames.stop()
+	}
+
+	return nil
+}
+
+// Status implements the metrics.StatusReporter interface. It reports the
+// detected node exporter metric names.
+func (b Backend) Status() map[string]string {
+	if b.metricNames == nil {
+		return nil
+	}
+
+	return b.metricNames.status()
 }
 
 // GetValue implements the metrics.Backend interface
@@ -93,132 +218,168 @@ func (b Backend) GetValue(metric string, configuration map[string]string, nodeSe
 		return 0, errors.Wrap(err, "listing nodes")
 	}
 
-	podIPs, err := b.getNodeExporterPodIPsOnNodes(nodes)
-	if err != nil {
-		return 0, errors.Wrapf(err, "getting Prometheus node exporter pod IPs for metric %s", metric)
+	// Built-in queries select series by node. An empty regex would match
+	// series without the label, e.g. pods that are not scheduled to any node,
+	// rather than matching nothing.
+	if metric != MetricCustom.String() && len(nodes) == 0 {
+		return 0, errors.Errorf("no nodes match node selector %q for metric %s", selector.String(), metric)
 	}
 
-	switch metric {
-	case MetricCPUPercentUtilization.String():
-		query, _ := buildCPUQuery(podIPs, configuration)
-		return b.performQuery(query)
-
-	case MetricMemoryPercentUtilization.String():
-		query, _ := buildMemoryQuery(podIPs, configuration)
-		return b.performQuery(query)
-
-	case MetricCustom.String():
-		query, _ := buildCustomQuery(podIPs, configuration)
-		return b.performQuery(query)
-
-	default:
-		return 0, errors.Errorf("unknown metric %q", metric)
+	// Only pods_per_node doesn't query node exporter series
+	var exporters exporterSelector
+	if metric != MetricPodsPerNode.String() {
+		exporters, err = b.getExporterSelector(nodes)
+		if err != nil {
+			return 0, errors.Wrapf(err, "discovering Prometheus node exporters for metric %s", metric)
+		}
 	}
-}
-
-func (b Backend) getNodeExporterPodIPsOnNodes(nodes []*corev1.Node) ([]string, error) {
-	var podIPs []string
 
-	// TODO we should rethink how we do all of the below, potentially using b.Targets()
-	// to help. See https://github.com/containership/cerebral/issues/15.
-	selector := labels.NewSelector()
-	l, _ := labels.NewRequirement("prom-exporter", selection.Equals, []string{"node"})
-	selector = selector.Add(*l)
-
-	// TODO if we could use a FieldSelector including Pod.Spec.NodeName then we
-	// could avoid the gross O(n^2) below. Is there a way to do that without
-	// having to query the API directly?
-	pods, err := b.podLister.List(selector)
+	result, err := resultConfigurationFromMap(configuration)
 	if err != nil {
-		return nil, errors.Wrap(err, "listing node exporter pods")
+		return 0, errors.Wrap(err, "validating configuration")
 	}
-	// Only filter in prom-exporter pods that belong to a node we care about
-	for _, pod := range pods {
-		for _, node := range nodes {
-			if pod.Spec.NodeName == node.ObjectMeta.Name {
-				podIPs = append(podIPs, pod.Status.PodIP)
-			}
+
+	if metric == MetricCustom.String() {
+		query, err := buildCustomQuery(exporters, getNodeNames(nodes), configuration)
+		if err != nil {
+			return 0, errors.Wrap(err, "building custom query")
 		}
+
+		return b.performQuery(query, result)
+	}
+
+	tmpl, ok := queryTemplates[metric]
+	if !ok {
+		return 0, errors.Errorf("unknown metric %q", metric)
 	}
 
-	if len(podIPs) != len(nodes) {
-		return nil, errors.Errorf("found %d node exporter pods for %d nodes", len(podIPs), len(nodes))
+	query, err := buildQuery(tmpl, exporters, getNodeNames(nodes), b.metricNames.get(), configuration)
+	if err != nil {
+		return 0, errors.Wrapf(err, "building query for metric %s", metric)
 	}
 
-	return podIPs, nil
+	return b.performQuery(query, result)
 }
 
-func (b Backend) performQuery(query string) (float64, error) {
+// performQuery performs the query and reduces its result to a single value
+func (b Backend) performQuery(query string, config resultConfiguration) (float64, error) {
 	log.Debugf("Performing prometheus query: %s", query)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	val, err := b.prometheus.Query(ctx, query, time.Time{})
+	var val model.Value
+	var err error
+	if config.queryRange > 0 {
+		end := time.Now()
+		val, err = b.prometheus.QueryRange(ctx, query, prometheus.Range{
+			Start: end.Add(-config.queryRange),
+			End:   end,
+			Step:  config.queryStep,
+		})
+	} else {
+		val, err = b.prometheus.Query(ctx, query, time.Time{})
+	}
+
 	if err != nil {
 		return 0, errors.Wrapf(err, "querying prometheus with string %q", query)
 	}
 
-	var result float64
+	return reduceValue(val, config)
+}
+
+// reduceValue reduces a query result to a single value. Scalars are used as
+// is. Each series of a matrix is first reduced over time using the time
+// reduction. Results with multiple series are then reduced using the
+// reduction, or are an error if there is none.
+func reduceValue(val model.Value, config resultConfiguration) (float64, error) {
+	var values []float64
 	switch v := val.(type) {
+	case *model.Scalar:
+		return float64(v.Value), nil
+
 	case model.Vector:
-		if len(v) != 1 {
-			return 0, errors.Errorf("expected vector to have a single element but it has %d", len(v))
+		for _, sample := range v {
+			values = append(values, float64(sample.Value))
 		}
 
-		result = float64(v[0].Value)
+	case model.Matrix:
+		for _, stream := range v {
+			if len(stream.Values) == 0 {
+				continue
+			}
+
+			samples := make([]float64, len(stream.Values))
+			for i, pair := range stream.Values {
+				samples[i] = float64(pair.Value)
+			}
+
+			value, err := metrics.Aggregate(config.timeReduction, samples)
+			if err != nil {
+				return 0, errors.Wrapf(err, "reducing series %s over time", stream.Metric)
+			}
+
+			values = append(values, value)
+		}
 
 	default:
 		return 0, errors.Errorf("unexpected prometheus value type %T: %#v", v, v)
 	}
 
-	return result, nil
-}
+	switch {
+	case len(values) == 0:
+		return 0, errors.Errorf("expected %s to have a single element but it is empty", val.Type())
 
-func buildCPUQuery(podIPs []string, configuration map[string]string) (string, error) {
-	config := metricConfiguration{}
-	if err := config.defaultAndValidate(configuration); err != nil {
-		return "", errors.Wrap(err, "validating configuration")
-	}
+	case len(values) == 1:
+		return values[0], nil
 
-	config.PodIPsRegex = buildPodIPsRegex(podIPs)
+	case config.reduction == "":
+		return 0, errors.Errorf("expected %s to have a single element but it has %d and no reduction is configured", val.Type(), len(values))
+	}
 
-	var out bytes.Buffer
-	if err := cpuQueryTemplate.Execute(&out, config); err != nil {
-		return "", err
+	result, err := metrics.Aggregate(config.reduction, values)
+	if err != nil {
+		return 0, errors.Wrapf(err, "reducing %s", val.Type())
 	}
 
-	return out.String(), nil
+	return result, nil
 }
 
-func buildMemoryQuery(podIPs []string, configuration map[string]string) (string, error) {
+// buildQuery builds the query for a built-in metric from its template
+func buildQuery(tmpl *template.Template, exporters exporterSelector, nodeNames []string, names metricNames, configuration map[string]string) (string, error) {
 	config := metricConfiguration{}
+	config.setMetricNames(names)
 	if err := config.defaultAndValidate(configuration); err != nil {
 		return "", errors.Wrap(err, "validating configuration")
 	}
 
-	config.PodIPsRegex = buildPodIPsRegex(podIPs)
+	config.setExporterSelector(exporters)
+	config.setNodeNames(nodeNames)
 
 	var out bytes.Buffer
-	if err := memoryQueryTemplate.Execute(&out, config); err != nil {
+	if err := tmpl.Execute(&out, config); err != nil {
 		return "", err
 	}
 
 	return out.String(), nil
 }
 
-// For a custom query, there should be a single `query` key provided in the
-// configuration map. No further configuration keys are currently supported.
-func buildCustomQuery(podIPs []string, configuration map[string]string) (string, error) {
+// For a custom query, a `query` key must be provided in the configuration
+// map. Results with multiple series are reduced to a single value using the
+// `reduction`. If `queryRange` is set, a range query with a resolution of
+// `queryStep` is performed and the values of each series are first reduced
+// over time using the `timeReduction`.
+func buildCustomQuery(exporters exporterSelector, nodeNames []string, configuration map[string]string) (string, error) {
 	var query string
 	var ok bool
 	query, ok = configuration["query"]
 	if !ok {
-		return "", errors.New("single configuration key \"query\" must be provided for a custom query")
+		return "", errors.New("configuration key \"query\" must be provided for a custom query")
 	}
 
 	config := metricConfiguration{}
-	config.PodIPsRegex = buildPodIPsRegex(podIPs)
+	config.setExporterSelector(exporters)
+	config.setNodeNames(nodeNames)
 
 	template, err := template.New("query").Parse(query)
 	if err != nil {
@@ -233,14 +394,11 @@ func buildCustomQuery(podIPs []string, configuration map[string]string) (string,
 	return out.String(), nil
 }
 
-func buildPodIPsRegex(podIPs []string) string {
-	var regex string
-	for i, ip := range podIPs {
-		regex += fmt.Sprintf("%s:.*", ip)
-		if i != len(podIPs)-1 {
-			regex += "|"
-		}
+func getNodeNames(nodes []*corev1.Node) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
 	}
 
-	return regex
+	return names
 }
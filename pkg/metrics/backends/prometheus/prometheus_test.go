@@ -2,12 +2,14 @@ package prometheus
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 
 	corev1 "k8s.io/api/core/v1"
@@ -22,6 +24,10 @@ import (
 var (
 	validURL = "http://localhost:9000"
 
+	validConfiguration = map[string]string{
+		"address": validURL,
+	}
+
 	duration = "5m"
 
 	goodConfiguration = map[string]string{
@@ -95,22 +101,38 @@ var (
 func TestNewClient(t *testing.T) {
 	// Should never fail with any valid URL because it's only constructing an
 	// http.Client under the hood
-	client, err := NewClient(validURL, corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	client, err := NewClient(validConfiguration, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
 	assert.NotNil(t, client)
 	assert.NoError(t, err, "any valid URL is ok")
+	assert.NoError(t, client.(Backend).Close())
 
-	client, err = NewClient("", corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	client, err = NewClient(map[string]string{}, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
 	assert.Error(t, err, "error on empty URL")
 
-	_, err = NewClient(validURL, nil, corelistersv1.NewPodLister(nil))
+	_, err = NewClient(validConfiguration, fake.NewSimpleClientset(), nil, corelistersv1.NewPodLister(nil))
 	assert.Error(t, err, "error on nil NodeLister")
 
-	_, err = NewClient(validURL, corelistersv1.NewNodeLister(nil), nil)
+	_, err = NewClient(validConfiguration, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil), nil)
 	assert.Error(t, err, "error on nil PodLister")
+
+	_, err = NewClient(map[string]string{
+		"address":               validURL,
+		"nodeExporterDiscovery": "dns",
+	}, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	assert.Error(t, err, "error on invalid discovery configuration")
+
+	_, err = NewClient(validConfiguration, nil, corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	assert.Error(t, err, "error on nil kube clientset")
+
+	_, err = NewClient(map[string]string{
+		"address":               validURL,
+		"bearerTokenSecretName": "missing",
+	}, fake.NewSimpleClientset(), corelistersv1.NewNodeLister(nil), corelistersv1.NewPodLister(nil))
+	assert.Error(t, err, "error on invalid authentication configuration")
 }
 
 func TestGetValue(t *testing.T) {
-	nodeLister := buildNodeLister(nil)
+	nodeLister := buildNodeLister([]corev1.Node{promNode0})
 	podLister := buildPodLister(nil)
 
 	mockProm := mocks.API{}
@@ -120,6 +142,9 @@ func TestGetValue(t *testing.T) {
 
 	backend := Backend{
 		prometheus: &mockProm,
+		discovery: discoveryConfiguration{
+			mode: discoveryModeLabel,
+		},
 		nodeLister: nodeLister,
 		podLister:  podLister,
 	}
@@ -134,12 +159,22 @@ func TestGetValue(t *testing.T) {
 	_, err = backend.GetValue("cpu_percent_utilization", goodConfiguration, nil)
 	assert.Error(t, err, "error on nil result")
 
-	// Return unexpected non-Vector type
+	// Return unexpected type
 	mockProm.On("Query", mock.Anything, mock.Anything, mock.Anything).
-		Return(&model.Scalar{}, nil).Once()
+		Return(&model.String{}, nil).Once()
 
 	_, err = backend.GetValue("cpu_percent_utilization", goodConfiguration, nil)
-	assert.Error(t, err, "error on non-vector result")
+	assert.Error(t, err, "error on string result")
+
+	// Return scalar
+	mockProm.On("Query", mock.Anything, mock.Anything, mock.Anything).
+		Return(&model.Scalar{Value: 0.25}, nil).Once()
+
+	val, err := backend.GetValue("custom", map[string]string{
+		"query": "scalar(up)",
+	}, nil)
+	assert.NoError(t, err, "scalar is ok")
+	assert.Equal(t, 0.25, val)
 
 	// Return single element vector as expected
 	mockProm.On("Query", mock.Anything, mock.Anything, mock.Anything).
@@ -172,6 +207,50 @@ func TestGetValue(t *testing.T) {
 	_, err = backend.GetValue("cpu_percent_utilization", goodConfiguration, nil)
 	assert.Error(t, err, "multiple element vector errors")
 
+	// Return multiple element vector, reduced as configured
+	mockProm.On("Query", mock.Anything, mock.Anything, mock.Anything).
+		Return(model.Vector{
+			{
+				Metric:    model.Metric{},
+				Value:     0.5,
+				Timestamp: 1234,
+			},
+			{
+				Metric:    model.Metric{},
+				Value:     1.75,
+				Timestamp: 1234,
+			},
+		}, nil).Twice()
+
+	val, err = backend.GetValue("cpu_percent_utilization", map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "2",
+	}, nil)
+	assert.NoError(t, err, "topk result reduced by default")
+	assert.Equal(t, 1.125, val, "topk result averaged by default")
+
+	val, err = backend.GetValue("cpu_percent_utilization", map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "2",
+		"reduction":            "max",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.75, val, "configured reduction used")
+
+	_, err = backend.GetValue("cpu_percent_utilization", map[string]string{
+		"reduction": "topk",
+	}, nil)
+	assert.Error(t, err, "invalid reduction")
+
+	// Return empty vector
+	mockProm.On("Query", mock.Anything, mock.Anything, mock.Anything).
+		Return(model.Vector{}, nil).Once()
+
+	_, err = backend.GetValue("cpu_percent_utilization", map[string]string{
+		"reduction": "sum",
+	}, nil)
+	assert.Error(t, err, "empty vector errors even with a reduction")
+
 	_, err = backend.GetValue("not a valid metric", goodConfiguration, nil)
 	assert.Error(t, err, "unknown metric requested")
 }
@@ -218,27 +297,247 @@ func TestGetNodeExporterPodIPsOnNodes(t *testing.T) {
 }
 
 func TestBuildCPUQuery(t *testing.T) {
-	_, err := buildCPUQuery(oneIP, goodConfiguration)
+	_, err := buildQuery(cpuQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, goodConfiguration)
 	assert.NoError(t, err, "good configuration is ok")
 
-	_, err = buildCPUQuery(oneIP, emptyConfiguration)
+	_, err = buildQuery(cpuQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, emptyConfiguration)
 	assert.NoError(t, err, "empty configuration is ok (defaults)")
 
-	_, err = buildCPUQuery(oneIP, badAggregationConfiguration)
+	_, err = buildQuery(cpuQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, badAggregationConfiguration)
 	assert.Error(t, err, "invalid aggregation errors")
 }
 
+func TestBuildQueryAggregationParameter(t *testing.T) {
+	exporters := podIPsSelector(oneIP)
+
+	query, err := buildQuery(cpuQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"aggregation":          "topk",
+		"aggregationParameter": "3",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "topk(3, ", "k rendered")
+
+	query, err = buildQuery(memoryQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"aggregation":          "quantile",
+		"aggregationParameter": "0.9",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "quantile(0.9, ", "φ rendered")
+
+	query, err = buildQuery(diskQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"aggregation":          "count_values",
+		"aggregationParameter": "usage",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "count_values('usage', ", "label rendered")
+
+	query, err = buildQuery(cpuQueryTemplate, exporters, nil, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err)
+	assert.Contains(t, query, "avg(\n", "no parameter rendered by default")
+}
+
 func TestBuildMemoryQuery(t *testing.T) {
-	_, err := buildMemoryQuery(oneIP, goodConfiguration)
+	_, err := buildQuery(memoryQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, goodConfiguration)
 	assert.NoError(t, err, "good configuration is ok")
 
-	_, err = buildCPUQuery(oneIP, emptyConfiguration)
+	query, err := buildQuery(memoryQueryTemplate, podIPsSelector(oneIP), nil, metricNames{
+		NodeMemoryAvailable: "node_memory_MemAvailable_bytes",
+		NodeMemoryTotal:     "node_memory_MemTotal_bytes",
+	}, goodConfiguration)
+	assert.NoError(t, err)
+	assert.Contains(t, query, "node_memory_MemAvailable_bytes{", "detected available memory metric name used")
+	assert.Contains(t, query, "node_memory_MemTotal_bytes{", "detected total memory metric name used")
+
+	_, err = buildQuery(cpuQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, emptyConfiguration)
 	assert.NoError(t, err, "empty configuration is ok (defaults)")
 
-	_, err = buildCPUQuery(oneIP, badAggregationConfiguration)
+	_, err = buildQuery(cpuQueryTemplate, podIPsSelector(oneIP), nil, defaultMetricNames, badAggregationConfiguration)
 	assert.Error(t, err, "invalid aggregation errors")
 }
 
+func TestBuildQuery(t *testing.T) {
+	exporters := podIPsSelector(oneIP)
+
+	query, err := buildQuery(diskQueryTemplate, exporters, nil, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err, "empty configuration is ok (defaults)")
+	assert.Contains(t, query, "mountpoint='/'", "mountpoint defaulted")
+
+	query, err = buildQuery(diskQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"mountpoint": "/var/lib/docker",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "mountpoint='/var/lib/docker'")
+
+	_, err = buildQuery(diskQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"mountpoint": "/'}[1m]) or vector(0)",
+	})
+	assert.Error(t, err, "mountpoint cannot escape quotes")
+
+	query, err = buildQuery(networkQueryTemplate, exporters, nil, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err, "empty configuration is ok (defaults)")
+	assert.Contains(t, query, defaultMetricNames.NodeNetworkReceive, "total includes received bytes")
+	assert.Contains(t, query, defaultMetricNames.NodeNetworkTransmit, "total includes transmitted bytes")
+	assert.Contains(t, query, "device!~", "virtual devices excluded by default")
+
+	query, err = buildQuery(networkQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"direction":   "receive",
+		"deviceRegex": "eth0|ens\\d+",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, defaultMetricNames.NodeNetworkReceive)
+	assert.NotContains(t, query, defaultMetricNames.NodeNetworkTransmit, "only received bytes")
+	assert.Contains(t, query, `device=~'eth0|ens\\d+'`, "device regex backslashes escaped")
+
+	_, err = buildQuery(networkQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"direction": "sideways",
+	})
+	assert.Error(t, err, "invalid direction")
+
+	_, err = buildQuery(networkQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"deviceRegex": "eth(",
+	})
+	assert.Error(t, err, "invalid device regex")
+
+	query, err = buildQuery(loadQueryTemplate, exporters, nil, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err, "empty configuration is ok (defaults)")
+	assert.Contains(t, query, "node_load5{", "load period defaulted")
+
+	query, err = buildQuery(loadQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"loadPeriod": "15",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "node_load15{")
+
+	_, err = buildQuery(loadQueryTemplate, exporters, nil, defaultMetricNames, map[string]string{
+		"loadPeriod": "10",
+	})
+	assert.Error(t, err, "invalid load period")
+
+	query, err = buildQuery(podsQueryTemplate, exporterSelector{}, []string{"node-0", "node-1"}, defaultMetricNames, emptyConfiguration)
+	assert.NoError(t, err, "empty configuration is ok (defaults)")
+	assert.Contains(t, query, "node=~'node-0|node-1'", "pods selected by node name")
+
+	_, err = buildQuery(podsQueryTemplate, exporterSelector{}, nil, defaultMetricNames, badAggregationConfiguration)
+	assert.Error(t, err, "invalid aggregation errors")
+}
+
+func TestGetValueQueryRange(t *testing.T) {
+	mockProm := mocks.API{}
+	mockProm.On("QueryRange", mock.Anything, "up", mock.MatchedBy(func(r promv1.Range) bool {
+		return r.End.Sub(r.Start) == 10*time.Minute && r.Step == 30*time.Second
+	})).Return(model.Matrix{
+		{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 1},
+				{Timestamp: 2000, Value: 3},
+			},
+		},
+	}, nil).Once()
+
+	backend := Backend{
+		prometheus: &mockProm,
+		nodeLister: buildNodeLister(nil),
+		podLister:  buildPodLister(nil),
+	}
+
+	val, err := backend.GetValue("custom", map[string]string{
+		"query":      "up",
+		"queryRange": "10m",
+		"queryStep":  "30s",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, val, "matrix averaged over time by default")
+	mockProm.AssertExpectations(t)
+}
+
+func TestReduceValue(t *testing.T) {
+	config := resultConfiguration{
+		timeReduction: "avg",
+	}
+
+	val, err := reduceValue(&model.Scalar{Value: 3}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, val, "scalar used as is")
+
+	vector := model.Vector{
+		{Value: 1},
+		{Value: 4},
+	}
+
+	_, err = reduceValue(vector, config)
+	assert.Error(t, err, "multiple element vector without reduction")
+
+	config.reduction = "max"
+	val, err = reduceValue(vector, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, val, "vector reduced")
+
+	_, err = reduceValue(model.Vector{}, config)
+	assert.Error(t, err, "empty vector")
+
+	matrix := model.Matrix{
+		{
+			Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 1},
+				{Timestamp: 2000, Value: 2},
+			},
+		},
+		{
+			Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 5},
+				{Timestamp: 2000, Value: 7},
+			},
+		},
+		{
+			// Series without values are ignored
+		},
+	}
+
+	val, err = reduceValue(matrix, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, val, "max of series averaged over time")
+
+	config.timeReduction = "min"
+	config.reduction = "sum"
+	val, err = reduceValue(matrix, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, val, "sum of series minimums")
+
+	_, err = reduceValue(model.Matrix{}, config)
+	assert.Error(t, err, "empty matrix")
+
+	_, err = reduceValue(nil, config)
+	assert.Error(t, err, "nil value")
+}
+
+func TestGetValuePodsPerNode(t *testing.T) {
+	mockProm := mocks.API{}
+	mockProm.On("Query", mock.Anything, mock.MatchedBy(func(query string) bool {
+		return strings.Contains(query, "node=~'other-0'")
+	}), mock.Anything).Return(model.Vector{
+		{
+			Metric: model.Metric{},
+			Value:  12,
+		},
+	}, nil).Once()
+
+	// No node exporters are running, which doesn't matter for this metric
+	backend := Backend{
+		prometheus: &mockProm,
+		nodeLister: buildNodeLister([]corev1.Node{otherNode0}),
+		podLister:  buildPodLister(nil),
+	}
+
+	value, err := backend.GetValue("pods_per_node", emptyConfiguration, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.0, value)
+
+	_, err = backend.GetValue("pods_per_node", emptyConfiguration, map[string]string{"pool": "nonexistent"})
+	assert.Error(t, err, "no nodes match")
+	mockProm.AssertNumberOfCalls(t, "Query", 1)
+}
+
 func TestBuildPodIPsRegex(t *testing.T) {
 	regex := buildPodIPsRegex(nil)
 	assert.Empty(t, regex, "nil pod IPs results in empty regex")
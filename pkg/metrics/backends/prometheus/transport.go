@@ -0,0 +1,232 @@
+package prometheus
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/containership/cerebral/pkg/secretutil"
+)
+
+const (
+	defaultBearerTokenSecretKey = "token"
+
+	// Keys of the basic auth Secret data, matching kubernetes.io/basic-auth
+	// Secrets
+	basicAuthUsernameKey = "username"
+	basicAuthPasswordKey = "password"
+
+	// Each extra header is configured as a separate key with this prefix,
+	// e.g. header.X-Scope-OrgID
+	headerConfigPrefix = "header."
+)
+
+// authRoundTripper adds authentication and extra headers to requests before
+// delegating to the underlying RoundTripper
+type authRoundTripper struct {
+	rt http.RoundTripper
+
+	headers map[string]string
+
+	// At most one of the following is set. Secrets and the token file are
+	// read again as needed so that rotated credentials are picked up.
+	bearerTokenSecret    *secretutil.Reference
+	bearerTokenSecretKey string
+	bearerTokenFile      string
+	basicAuthSecret      *secretutil.Reference
+}
+
+// buildRoundTripper returns a RoundTripper implementing the authentication,
+// TLS and header options in the MetricsBackend configuration. Authentication
+// Secrets are read using the given clientset when building the RoundTripper
+// and again periodically; TLS Secrets are read once.
+func buildRoundTripper(configuration map[string]string, kubeclientset kubernetes.Interface) (http.RoundTripper, error) {
+	tlsConfig, err := buildTLSConfig(configuration, kubeclientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "building TLS configuration")
+	}
+
+	rt := &authRoundTripper{
+		rt: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     tlsConfig,
+		},
+		headers:         make(map[string]string),
+		bearerTokenFile: configuration["bearerTokenFile"],
+	}
+
+	for k, v := range configuration {
+		if strings.HasPrefix(k, headerConfigPrefix) {
+			rt.headers[strings.TrimPrefix(k, headerConfigPrefix)] = v
+		}
+	}
+
+	if name := configuration["bearerTokenSecretName"]; name != "" {
+		rt.bearerTokenSecretKey = configuration["bearerTokenSecretKey"]
+		if rt.bearerTokenSecretKey == "" {
+			rt.bearerTokenSecretKey = defaultBearerTokenSecretKey
+		}
+
+		rt.bearerTokenSecret, err = secretutil.NewReference(kubeclientset, configuration["bearerTokenSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := rt.bearerToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	if name := configuration["basicAuthSecretName"]; name != "" {
+		rt.basicAuthSecret, err = secretutil.NewReference(kubeclientset, configuration["basicAuthSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, _, err := rt.basicAuth(); err != nil {
+			return nil, err
+		}
+	}
+
+	numAuthMethods := 0
+	for _, set := range []bool{rt.bearerTokenSecret != nil, rt.bearerTokenFile != "", rt.basicAuthSecret != nil} {
+		if set {
+			numAuthMethods++
+		}
+	}
+
+	if numAuthMethods > 1 {
+		return nil, errors.New("at most one of bearerTokenSecretName, bearerTokenFile or basicAuthSecretName may be provided")
+	}
+
+	return rt, nil
+}
+
+// buildTLSConfig builds a TLS configuration from either a Secret or files
+// containing the CA and client certificate, along with insecureSkipVerify
+func buildTLSConfig(configuration map[string]string, kubeclientset kubernetes.Interface) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if name := configuration["tlsSecretName"]; name != "" {
+		if configuration["caFile"] != "" || configuration["certFile"] != "" || configuration["keyFile"] != "" {
+			return nil, errors.New("tlsSecretName is mutually exclusive with caFile, certFile and keyFile")
+		}
+
+		secret, err := secretutil.Get(kubeclientset, configuration["tlsSecretNamespace"], name)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err = secretutil.TLSConfig(secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "from Secret %s", name)
+		}
+	} else {
+		var pems [3][]byte
+		for i, key := range []string{"caFile", "certFile", "keyFile"} {
+			if path := configuration[key]; path != "" {
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return nil, errors.Wrapf(err, "reading %s", key)
+				}
+				pems[i] = data
+			}
+		}
+
+		var err error
+		tlsConfig, err = secretutil.TLSConfigFromPEM(pems[0], pems[1], pems[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s, ok := configuration["insecureSkipVerify"]; ok {
+		skip, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.Errorf("insecureSkipVerify must be a boolean but is %q", s)
+		}
+		tlsConfig.InsecureSkipVerify = skip
+	}
+
+	return tlsConfig, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request, so work on a copy
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+
+	for k, v := range rt.headers {
+		r.Header.Set(k, v)
+	}
+
+	switch {
+	case rt.bearerTokenSecret != nil:
+		token, err := rt.bearerToken()
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+
+	case rt.bearerTokenFile != "":
+		// The file is read on every request so that rotated tokens (e.g.
+		// projected service account tokens) are picked up
+		token, err := ioutil.ReadFile(rt.bearerTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading bearer token file")
+		}
+		r.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	case rt.basicAuthSecret != nil:
+		username, password, err := rt.basicAuth()
+		if err != nil {
+			return nil, err
+		}
+		r.SetBasicAuth(username, password)
+	}
+
+	return rt.rt.RoundTrip(r)
+}
+
+// bearerToken returns the bearer token from the bearer token Secret
+func (rt *authRoundTripper) bearerToken() (string, error) {
+	token, err := rt.bearerTokenSecret.Value(rt.bearerTokenSecretKey)
+	if err != nil {
+		return "", errors.Wrap(err, "reading bearer token")
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+// basicAuth returns the username and password from the basic auth Secret
+func (rt *authRoundTripper) basicAuth() (string, string, error) {
+	data, err := rt.basicAuthSecret.Data()
+	if err != nil {
+		return "", "", errors.Wrap(err, "reading basic auth credentials")
+	}
+
+	username := string(data[basicAuthUsernameKey])
+	if username == "" {
+		return "", "", errors.Errorf("basic auth Secret %s does not contain key %q", rt.basicAuthSecret.Name(), basicAuthUsernameKey)
+	}
+
+	return username, string(data[basicAuthPasswordKey]), nil
+}
@@ -0,0 +1,203 @@
+package prometheus
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	bearerTokenSecret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-token",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			defaultBearerTokenSecretKey: []byte("secret-token\n"),
+		},
+	}
+
+	basicAuthSecret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-basic-auth",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			basicAuthUsernameKey: []byte("cerebral"),
+			basicAuthPasswordKey: []byte("hunter2"),
+		},
+	}
+)
+
+// Send a request to the given URL using the RoundTripper, returning any error
+func roundTrip(rt http.RoundTripper, url string) error {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// Send a request to a test server using the RoundTripper and return the
+// request as received by the server
+func receive(t *testing.T, rt http.RoundTripper) *http.Request {
+	var received *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := rt.RoundTrip(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	assert.Empty(t, req.Header, "original request is not modified")
+
+	return received
+}
+
+func TestBuildRoundTripper(t *testing.T) {
+	kubeclientset := fake.NewSimpleClientset(&bearerTokenSecret, &basicAuthSecret)
+
+	rt, err := buildRoundTripper(map[string]string{}, kubeclientset)
+	assert.NoError(t, err, "empty configuration is ok")
+	received := receive(t, rt)
+	assert.Empty(t, received.Header.Get("Authorization"), "no authorization by default")
+
+	rt, err = buildRoundTripper(map[string]string{
+		"header.X-Scope-OrgID":       "tenant-1",
+		"bearerTokenSecretName":      bearerTokenSecret.Name,
+		"bearerTokenSecretNamespace": bearerTokenSecret.Namespace,
+	}, kubeclientset)
+	assert.NoError(t, err)
+	received = receive(t, rt)
+	assert.Equal(t, "tenant-1", received.Header.Get("X-Scope-OrgID"), "extra header is set")
+	assert.Equal(t, "Bearer secret-token", received.Header.Get("Authorization"), "bearer token from Secret")
+
+	rt, err = buildRoundTripper(map[string]string{
+		"basicAuthSecretName":      basicAuthSecret.Name,
+		"basicAuthSecretNamespace": basicAuthSecret.Namespace,
+	}, kubeclientset)
+	assert.NoError(t, err)
+	received = receive(t, rt)
+	username, password, ok := received.BasicAuth()
+	assert.True(t, ok, "basic auth is set")
+	assert.Equal(t, "cerebral", username)
+	assert.Equal(t, "hunter2", password)
+
+	tokenFile, err := ioutil.TempFile("", "token")
+	assert.NoError(t, err)
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("file-token")
+	tokenFile.Close()
+
+	rt, err = buildRoundTripper(map[string]string{
+		"bearerTokenFile": tokenFile.Name(),
+	}, kubeclientset)
+	assert.NoError(t, err)
+	received = receive(t, rt)
+	assert.Equal(t, "Bearer file-token", received.Header.Get("Authorization"), "bearer token from file")
+
+	ioutil.WriteFile(tokenFile.Name(), []byte("rotated-token"), 0600)
+	received = receive(t, rt)
+	assert.Equal(t, "Bearer rotated-token", received.Header.Get("Authorization"), "bearer token file is re-read")
+
+	_, err = buildRoundTripper(map[string]string{
+		"bearerTokenFile":          tokenFile.Name(),
+		"basicAuthSecretName":      basicAuthSecret.Name,
+		"basicAuthSecretNamespace": basicAuthSecret.Namespace,
+	}, kubeclientset)
+	assert.Error(t, err, "multiple authentication methods")
+
+	_, err = buildRoundTripper(map[string]string{
+		"bearerTokenSecretName":      bearerTokenSecret.Name,
+		"bearerTokenSecretNamespace": bearerTokenSecret.Namespace,
+		"bearerTokenSecretKey":       "missing",
+	}, kubeclientset)
+	assert.Error(t, err, "bearer token Secret key must exist")
+
+	_, err = buildRoundTripper(map[string]string{
+		"basicAuthSecretName": "missing",
+	}, kubeclientset)
+	assert.Error(t, err, "basic auth Secret must exist")
+
+	_, err = buildRoundTripper(map[string]string{
+		"insecureSkipVerify": "maybe",
+	}, kubeclientset)
+	assert.Error(t, err, "invalid insecureSkipVerify")
+
+	_, err = buildRoundTripper(map[string]string{
+		"caFile": "/does/not/exist",
+	}, kubeclientset)
+	assert.Error(t, err, "CA file must exist")
+}
+
+func TestBuildRoundTripperTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	tlsSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-tls",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			"ca.crt": ca,
+		},
+	}
+	kubeclientset := fake.NewSimpleClientset(&tlsSecret)
+
+	rt, err := buildRoundTripper(map[string]string{}, kubeclientset)
+	assert.NoError(t, err)
+	assert.Error(t, roundTrip(rt, server.URL), "untrusted server certificate")
+
+	rt, err = buildRoundTripper(map[string]string{
+		"insecureSkipVerify": "true",
+	}, kubeclientset)
+	assert.NoError(t, err)
+	assert.NoError(t, roundTrip(rt, server.URL), "insecureSkipVerify skips verification")
+
+	rt, err = buildRoundTripper(map[string]string{
+		"tlsSecretName":      tlsSecret.Name,
+		"tlsSecretNamespace": tlsSecret.Namespace,
+	}, kubeclientset)
+	assert.NoError(t, err)
+	assert.NoError(t, roundTrip(rt, server.URL), "server trusted using CA from Secret")
+
+	caFile, err := ioutil.TempFile("", "ca.crt")
+	assert.NoError(t, err)
+	defer os.Remove(caFile.Name())
+	caFile.Write(ca)
+	caFile.Close()
+
+	rt, err = buildRoundTripper(map[string]string{
+		"caFile": caFile.Name(),
+	}, kubeclientset)
+	assert.NoError(t, err)
+	assert.NoError(t, roundTrip(rt, server.URL), "server trusted using CA file")
+
+	_, err = buildRoundTripper(map[string]string{
+		"tlsSecretName":      tlsSecret.Name,
+		"tlsSecretNamespace": tlsSecret.Namespace,
+		"caFile":             caFile.Name(),
+	}, kubeclientset)
+	assert.Error(t, err, "Secret and files are mutually exclusive")
+}
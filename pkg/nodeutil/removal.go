@@ -0,0 +1,58 @@
+package nodeutil
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// RemovalStrategyRandom selects random nodes for removal
+	RemovalStrategyRandom = "random"
+	// RemovalStrategyOldest selects the oldest nodes for removal
+	RemovalStrategyOldest = "oldest"
+	// RemovalStrategyNewest selects the newest nodes for removal
+	RemovalStrategyNewest = "newest"
+)
+
+// shuffleFunc is overridden in tests in order to make the random strategy
+// deterministic
+var shuffleFunc = rand.Shuffle
+
+// SelectNodesForRemoval returns count nodes chosen from the given nodes
+// according to the removal strategy. An empty strategy is treated as random.
+// The nodes passed in are not mutated, but the order of the slice may be.
+func SelectNodesForRemoval(nodes []*corev1.Node, count int, strategy string) ([]*corev1.Node, error) {
+	if count < 0 {
+		return nil, errors.New("cannot select a negative number of nodes")
+	}
+
+	if count > len(nodes) {
+		return nil, errors.Errorf("cannot select %d nodes for removal from %d nodes", count, len(nodes))
+	}
+
+	switch strategy {
+	case RemovalStrategyRandom, "":
+		shuffleFunc(len(nodes), func(i, j int) {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		})
+
+	case RemovalStrategyOldest:
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].CreationTimestamp.Before(&nodes[j].CreationTimestamp)
+		})
+
+	case RemovalStrategyNewest:
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[j].CreationTimestamp.Before(&nodes[i].CreationTimestamp)
+		})
+
+	default:
+		return nil, errors.Errorf("unknown node removal strategy %q", strategy)
+	}
+
+	return nodes[:count], nil
+}
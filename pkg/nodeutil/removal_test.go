@@ -0,0 +1,68 @@
+package nodeutil
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func buildNode(name string, created int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+		},
+	}
+}
+
+func buildNodes() []*corev1.Node {
+	return []*corev1.Node{
+		buildNode("middle", 200),
+		buildNode("oldest", 100),
+		buildNode("newest", 300),
+	}
+}
+
+func TestSelectNodesForRemoval(t *testing.T) {
+	_, err := SelectNodesForRemoval(buildNodes(), -1, RemovalStrategyOldest)
+	assert.Error(t, err, "negative count errors")
+
+	_, err = SelectNodesForRemoval(buildNodes(), 4, RemovalStrategyOldest)
+	assert.Error(t, err, "count larger than number of nodes errors")
+
+	_, err = SelectNodesForRemoval(buildNodes(), 1, "not-a-strategy")
+	assert.Error(t, err, "unknown strategy errors")
+
+	selected, err := SelectNodesForRemoval(buildNodes(), 0, RemovalStrategyOldest)
+	assert.NoError(t, err)
+	assert.Empty(t, selected, "zero count selects nothing")
+
+	selected, err = SelectNodesForRemoval(buildNodes(), 2, RemovalStrategyOldest)
+	assert.NoError(t, err)
+	assert.Len(t, selected, 2)
+	assert.Equal(t, "oldest", selected[0].Name, "oldest node selected first")
+	assert.Equal(t, "middle", selected[1].Name, "next oldest node selected second")
+
+	selected, err = SelectNodesForRemoval(buildNodes(), 1, RemovalStrategyNewest)
+	assert.NoError(t, err)
+	assert.Len(t, selected, 1)
+	assert.Equal(t, "newest", selected[0].Name, "newest node selected")
+
+	// Make the shuffle a noop so random is deterministic
+	shuffleFunc = func(n int, swap func(i, j int)) {}
+	defer func() { shuffleFunc = rand.Shuffle }()
+
+	selected, err = SelectNodesForRemoval(buildNodes(), 1, "")
+	assert.NoError(t, err, "empty strategy defaults to random")
+	assert.Len(t, selected, 1)
+	assert.Equal(t, "middle", selected[0].Name)
+
+	selected, err = SelectNodesForRemoval(buildNodes(), 3, RemovalStrategyRandom)
+	assert.NoError(t, err)
+	assert.Len(t, selected, 3, "all nodes can be selected")
+}
@@ -0,0 +1,99 @@
+package pluginutil
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config describes how to reach an out-of-process plugin. Exactly one of
+// Address (TCP host:port) or Socket (Unix socket path) must be provided.
+type Config struct {
+	Address string
+	Socket  string
+
+	// Timeout applies to each call made to the plugin
+	Timeout time.Duration
+}
+
+// ConfigFromMap builds a plugin Config from the configuration map of a custom
+// resource, using the keys address, socket and timeoutSeconds.
+func ConfigFromMap(configuration map[string]string) (*Config, error) {
+	c := &Config{
+		Address: configuration["address"],
+		Socket:  configuration["socket"],
+		Timeout: defaultTimeout,
+	}
+
+	if c.Address == "" && c.Socket == "" {
+		return nil, errors.New("plugin requires address or socket in configuration")
+	}
+
+	if c.Address != "" && c.Socket != "" {
+		return nil, errors.New("plugin configuration must not specify both address and socket")
+	}
+
+	if s, ok := configuration["timeoutSeconds"]; ok {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds <= 0 {
+			return nil, errors.Errorf("invalid timeoutSeconds %q", s)
+		}
+
+		c.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	return c, nil
+}
+
+// Dial returns a connection to the plugin. The connection is established
+// lazily, so callers should use CheckHealth to verify that the plugin is
+// actually reachable.
+func (c *Config) Dial() (*grpc.ClientConn, error) {
+	target := c.Address
+	opts := []grpc.DialOption{
+		// Plugins are expected to run alongside Cerebral, e.g. as a sidecar
+		grpc.WithInsecure(),
+	}
+
+	if c.Socket != "" {
+		target = c.Socket
+		opts = append(opts, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	}
+
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dialing plugin at %s", target)
+	}
+
+	return conn, nil
+}
+
+// CheckHealth uses the standard gRPC health checking protocol to verify that
+// the given service is being served by the plugin
+func CheckHealth(conn *grpc.ClientConn, service string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: service,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "checking health of plugin service %s", service)
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf("plugin service %s is not serving (status %s)", service, resp.Status.String())
+	}
+
+	return nil
+}
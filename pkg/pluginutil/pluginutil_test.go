@@ -0,0 +1,80 @@
+package pluginutil
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestConfigFromMap(t *testing.T) {
+	_, err := ConfigFromMap(map[string]string{})
+	assert.Error(t, err, "address or socket is required")
+
+	_, err = ConfigFromMap(map[string]string{
+		"address": "localhost:9000",
+		"socket":  "/var/run/plugin.sock",
+	})
+	assert.Error(t, err, "address and socket are mutually exclusive")
+
+	_, err = ConfigFromMap(map[string]string{
+		"address":        "localhost:9000",
+		"timeoutSeconds": "-1",
+	})
+	assert.Error(t, err, "invalid timeout")
+
+	c, err := ConfigFromMap(map[string]string{
+		"socket": "/var/run/plugin.sock",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/plugin.sock", c.Socket)
+	assert.Equal(t, defaultTimeout, c.Timeout, "timeout defaulted")
+
+	c, err = ConfigFromMap(map[string]string{
+		"address":        "localhost:9000",
+		"timeoutSeconds": "3",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:9000", c.Address)
+	assert.Equal(t, 3*time.Second, c.Timeout)
+}
+
+func TestDialAndCheckHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pluginutil")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	c := &Config{
+		Socket:  socket,
+		Timeout: 5 * time.Second,
+	}
+
+	conn, err := c.Dial()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, CheckHealth(conn, "serving", c.Timeout), "serving service is healthy")
+	assert.Error(t, CheckHealth(conn, "not-serving", c.Timeout), "not serving service is unhealthy")
+	assert.Error(t, CheckHealth(conn, "unknown", c.Timeout), "unknown service is unhealthy")
+}
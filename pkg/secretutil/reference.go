@@ -0,0 +1,90 @@
+package secretutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// refreshInterval is how long Secret data read through a Reference is used
+// before the Secret is read again
+const refreshInterval = time.Minute
+
+// nowFunc is a var so it can be overridden in tests
+var nowFunc = time.Now
+
+// A Reference is a Secret that is read again when its data is needed and the
+// previously read data is older than refreshInterval, so that rotated
+// credentials are picked up without editing the component using them.
+type Reference struct {
+	kubeclientset kubernetes.Interface
+	namespace     string
+	name          string
+
+	mu     sync.Mutex
+	data   map[string][]byte
+	readAt time.Time
+}
+
+// NewReference returns a Reference to the Secret with the given namespace and
+// name. The Secret is read immediately so that errors are reported early.
+func NewReference(kubeclientset kubernetes.Interface, namespace, name string) (*Reference, error) {
+	r := &Reference{
+		kubeclientset: kubeclientset,
+		namespace:     namespace,
+		name:          name,
+	}
+
+	if _, err := r.Data(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Name returns the name of the Secret
+func (r *Reference) Name() string {
+	return r.name
+}
+
+// Data returns the data of the Secret, reading the Secret again if needed
+func (r *Reference) Data() (map[string][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data != nil && nowFunc().Sub(r.readAt) < refreshInterval {
+		return r.data, nil
+	}
+
+	secret, err := Get(r.kubeclientset, r.namespace, r.name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.data = secret.Data
+	if r.data == nil {
+		r.data = make(map[string][]byte)
+	}
+	r.readAt = nowFunc()
+
+	return r.data, nil
+}
+
+// Value returns the value of the given key of the Secret, or an error if the
+// key is missing or empty
+func (r *Reference) Value(key string) (string, error) {
+	data, err := r.Data()
+	if err != nil {
+		return "", err
+	}
+
+	value := string(data[key])
+	if value == "" {
+		return "", errors.Errorf("Secret %s does not contain key %q", r.name, key)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,76 @@
+// Package secretutil provides helpers for components that read credentials
+// from Kubernetes Secrets.
+package secretutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keys of TLS Secret data, matching those of kubernetes.io/tls Secrets plus
+// an optional CA bundle
+const (
+	CAKey   = "ca.crt"
+	CertKey = corev1.TLSCertKey
+	KeyKey  = corev1.TLSPrivateKeyKey
+)
+
+// Get returns the Secret with the given namespace and name
+func Get(kubeclientset kubernetes.Interface, namespace, name string) (*corev1.Secret, error) {
+	if kubeclientset == nil {
+		return nil, errors.New("kubernetes clientset must be provided to read Secrets")
+	}
+
+	if namespace == "" {
+		return nil, errors.Errorf("namespace must be provided for Secret %s", name)
+	}
+
+	secret, err := kubeclientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting Secret %s/%s", namespace, name)
+	}
+
+	return secret, nil
+}
+
+// TLSConfig builds a TLS configuration from a Secret optionally containing a
+// CA bundle and a client certificate / key pair
+func TLSConfig(secret *corev1.Secret) (*tls.Config, error) {
+	return TLSConfigFromPEM(secret.Data[CAKey], secret.Data[CertKey], secret.Data[KeyKey])
+}
+
+// TLSConfigFromPEM builds a TLS configuration from an optional PEM encoded CA
+// bundle and an optional PEM encoded client certificate / key pair
+func TLSConfigFromPEM(ca, cert, key []byte) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no valid CA certificates found")
+		}
+
+		config.RootCAs = pool
+	}
+
+	if (len(cert) > 0) != (len(key) > 0) {
+		return nil, errors.New("both a certificate and a key must be provided for a client certificate")
+	}
+
+	if len(cert) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,135 @@
+package secretutil
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGet(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "kube-system",
+		},
+	}
+	client := fake.NewSimpleClientset(secret)
+
+	_, err := Get(nil, "kube-system", "creds")
+	assert.Error(t, err, "clientset is required")
+
+	_, err = Get(client, "", "creds")
+	assert.Error(t, err, "namespace is required")
+
+	_, err = Get(client, "default", "creds")
+	assert.Error(t, err, "Secret not found")
+
+	s, err := Get(client, "kube-system", "creds")
+	assert.NoError(t, err)
+	assert.Equal(t, secret, s)
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	})
+
+	_, err := TLSConfig(&corev1.Secret{
+		Data: map[string][]byte{
+			CAKey: []byte("not a certificate"),
+		},
+	})
+	assert.Error(t, err, "invalid CA")
+
+	_, err = TLSConfig(&corev1.Secret{
+		Data: map[string][]byte{
+			CertKey: []byte("cert without key"),
+		},
+	})
+	assert.Error(t, err, "client certificate requires key")
+
+	_, err = TLSConfig(&corev1.Secret{
+		Data: map[string][]byte{
+			CertKey: []byte("not a certificate"),
+			KeyKey:  []byte("not a key"),
+		},
+	})
+	assert.Error(t, err, "invalid client certificate")
+
+	config, err := TLSConfig(&corev1.Secret{})
+	assert.NoError(t, err, "empty Secret is ok")
+	assert.Nil(t, config.RootCAs, "system roots are used by default")
+
+	config, err = TLSConfig(&corev1.Secret{
+		Data: map[string][]byte{
+			CAKey: ca,
+		},
+	})
+	assert.NoError(t, err)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: config,
+		},
+	}
+
+	resp, err := client.Get(server.URL)
+	if assert.NoError(t, err, "server trusted using CA from Secret") {
+		resp.Body.Close()
+	}
+}
+
+func TestReference(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	now := time.Unix(1000, 0)
+	nowFunc = func() time.Time { return now }
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "kube-system",
+		},
+		Data: map[string][]byte{
+			"token": []byte("old"),
+		},
+	}
+	client := fake.NewSimpleClientset(secret)
+
+	_, err := NewReference(client, "kube-system", "missing")
+	assert.Error(t, err, "Secret must exist")
+
+	ref, err := NewReference(client, "kube-system", "creds")
+	assert.NoError(t, err)
+	assert.Equal(t, "creds", ref.Name())
+
+	value, err := ref.Value("token")
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+
+	_, err = ref.Value("missing")
+	assert.Error(t, err, "key must exist")
+
+	rotated := secret.DeepCopy()
+	rotated.Data["token"] = []byte("new")
+	_, err = client.CoreV1().Secrets("kube-system").Update(rotated)
+	assert.NoError(t, err)
+
+	value, _ = ref.Value("token")
+	assert.Equal(t, "old", value, "data is cached")
+
+	now = now.Add(refreshInterval)
+	value, _ = ref.Value("token")
+	assert.Equal(t, "new", value, "rotated data is read after refresh interval")
+}